@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
+)
+
+// runExport implements `trafficsim export [flags] <report.json>`.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := flags.String("config", "", "Scenario config for reports saved without one")
+	svgPath := flags.String("svg", "", "Write the frame of -step as SVG to this path")
+	gifPath := flags.String("gif", "", "Write steps -from..-to as animated GIF to this path")
+	step := flags.Int("step", 0, "Step drawn by -svg (default: last)")
+	from := flags.Int("from", 1, "First step of the GIF")
+	to := flags.Int("to", 0, "Last step of the GIF (default: last)")
+	delayMS := flags.Int("delay", 100, "GIF frame delay in milliseconds")
+	cellSize := flags.Int("cell", 16, "Pixels per grid cell")
+	colorBy := flags.String("color-by", sim.ColorByDirection, "Vehicle colors: direction or wait")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: trafficsim export [flags] <report.json>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("export needs exactly one report path")
+	}
+	if *svgPath == "" && *gifPath == "" {
+		return errors.New("export needs -svg and/or -gif")
+	}
+
+	report, cfg, err := loadTimeline(flags.Arg(0), *configPath)
+	if err != nil {
+		return err
+	}
+	opts := sim.ImageOptions{CellSize: *cellSize, ColorBy: *colorBy}
+	last := report.Timeline[len(report.Timeline)-1].Step
+
+	if *svgPath != "" {
+		if *step == 0 {
+			*step = last
+		}
+		stats := sim.TimelineStats(cfg, report.Timeline)
+		index := -1
+		for i, snap := range report.Timeline {
+			if snap.Step == *step {
+				index = i
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("timeline has no step %d", *step)
+		}
+		err := writeFile(*svgPath, func(f *os.File) error {
+			return sim.WriteSVG(f, cfg, report.Timeline[index], stats[index], opts)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("SVG of step %d written to %s\n", *step, *svgPath)
+	}
+
+	if *gifPath != "" {
+		if *to == 0 {
+			*to = last
+		}
+		delay := time.Duration(*delayMS) * time.Millisecond
+		err := writeFile(*gifPath, func(f *os.File) error {
+			return sim.WriteGIF(f, cfg, report.Timeline, *from, *to, delay, opts)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("GIF of steps %d-%d written to %s\n", *from, *to, *gifPath)
+	}
+	return nil
+}
+
+func writeFile(path string, write func(*os.File) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
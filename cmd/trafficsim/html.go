@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/benchmark"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/htmlreport"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
+)
+
+// runHTML implements `trafficsim html [flags] <report.json>`.
+func runHTML(args []string) error {
+	flags := flag.NewFlagSet("html", flag.ExitOnError)
+	out := flags.String("out", "", "HTML output path (default: the report path with .html)")
+	resultPath := flags.String("benchmark", "", "Benchmark result JSON to include")
+	configPath := flags.String("config", "", "Scenario config for reports saved without one")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: trafficsim html [flags] <report.json>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("html needs exactly one report path")
+	}
+
+	path := flags.Arg(0)
+	report, err := sim.LoadReport(path)
+	if err != nil {
+		return err
+	}
+	if *configPath != "" {
+		cfg, err := sim.LoadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		report.Config = &cfg
+	}
+	var result *benchmark.Result
+	if *resultPath != "" {
+		loaded, err := benchmark.LoadResult(*resultPath)
+		if err != nil {
+			return err
+		}
+		result = &loaded
+	}
+
+	if *out == "" {
+		*out = strings.TrimSuffix(path, ".json") + ".html"
+	}
+	if err := htmlreport.Write(*out, report, result); err != nil {
+		return err
+	}
+	fmt.Printf("HTML report written to %s\n", *out)
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/term"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
+)
+
+// liveRunner drives the engine step by step for the live dashboard and lets
+// the keyboard pause it, change its speed and intervene between steps.
+type liveRunner struct {
+	engine        *sim.Engine
+	lanes         []string
+	intersections []string
+	lane          int
+	intersection  int
+	burst         int
+	paused        bool
+	delay         time.Duration
+	message       string
+}
+
+func newLiveRunner(engine *sim.Engine, delay time.Duration, burst int) *liveRunner {
+	state := engine.State()
+	l := &liveRunner{engine: engine, burst: burst, delay: clampDelay(delay)}
+	for _, lane := range state.Lanes {
+		l.lanes = append(l.lanes, lane.ID)
+	}
+	for _, light := range state.Lights {
+		l.intersections = append(l.intersections, light.Intersection)
+	}
+	return l
+}
+
+// run steps until the engine is done or the user quits. Once input ends the
+// remaining steps play without pausing.
+func (l *liveRunner) run(keys <-chan term.Key) {
+	l.draw()
+	for !l.engine.Done() {
+		var tick <-chan time.Time
+		if !l.paused {
+			tick = time.After(l.delay)
+		}
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				l.paused = false
+				continue
+			}
+			if !l.handle(key) {
+				return
+			}
+		case <-tick:
+			l.step()
+		}
+		l.draw()
+	}
+}
+
+// handle applies one key press and reports whether the run continues.
+func (l *liveRunner) handle(key term.Key) bool {
+	l.message = ""
+	switch key {
+	case "q", term.KeyEscape, term.KeyInterrupt:
+		return false
+	case " ", "p":
+		l.paused = !l.paused
+	case "n", term.KeyRight:
+		l.paused = true
+		l.step()
+	case term.KeyUp, "+", "=":
+		l.delay = clampDelay(l.delay / 2)
+	case term.KeyDown, "-", "_":
+		l.delay = clampDelay(l.delay * 2)
+	case "i":
+		l.intersection = (l.intersection + 1) % len(l.intersections)
+	case "s":
+		l.switchPhase()
+	case "l":
+		if len(l.lanes) > 0 {
+			l.lane = (l.lane + 1) % len(l.lanes)
+		}
+	case "a":
+		l.injectBurst()
+	}
+	return true
+}
+
+func (l *liveRunner) step() {
+	if err := l.engine.Step(); err != nil {
+		l.message = err.Error()
+	}
+}
+
+// switchPhase forces the selected intersection to the green of the axis it
+// served least recently, skipping yellow and all-red.
+func (l *liveRunner) switchPhase() {
+	id := l.intersections[l.intersection]
+	var light sim.TrafficLight
+	for _, candidate := range l.engine.State().Lights {
+		if candidate.Intersection == id {
+			light = candidate
+		}
+	}
+	next := sim.PhaseVerticalGreen
+	if light.LastGreen == sim.PhaseVerticalGreen {
+		next = sim.PhaseHorizontalGreen
+	}
+	if err := l.engine.SetPhase(id, next); err != nil {
+		l.message = err.Error()
+		return
+	}
+	l.message = fmt.Sprintf("forced %s to %s", id, next)
+}
+
+func (l *liveRunner) injectBurst() {
+	if len(l.lanes) == 0 {
+		return
+	}
+	id := l.lanes[l.lane]
+	if err := l.engine.AddArrivals(id, l.burst); err != nil {
+		l.message = err.Error()
+		return
+	}
+	l.message = fmt.Sprintf("queued %d vehicles on lane %s", l.burst, id)
+}
+
+func (l *liveRunner) draw() {
+	l.engine.Render()
+
+	state := "running"
+	if l.paused {
+		state = "paused"
+	}
+	lane := "-"
+	if len(l.lanes) > 0 {
+		lane = l.lanes[l.lane]
+	}
+	fmt.Printf("\nLive %s | %s per step | intersection %s | lane %s\n",
+		state, l.delay, l.intersections[l.intersection], lane)
+	fmt.Println("Keys: space=pause  n=step  +/-=speed  i=next intersection  s=switch phase  l=next lane  a=add burst  q=quit")
+	if l.message != "" {
+		fmt.Println(l.message)
+	}
+}
@@ -7,25 +7,89 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/benchmark"
-	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/sim"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/htmlreport"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/term"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		subcommands := map[string]func([]string) error{"replay": runReplay, "export": runExport, "html": runHTML}
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				exitErr(err)
+			}
+			return
+		}
+	}
+
 	configPath := flag.String("config", "configs/baseline.json", "Path to a simulation config JSON")
 	compare := flag.String("compare", "", "Comma-separated config paths to run and compare")
 	benchmarkPath := flag.String("benchmark", "", "Path to deterministic benchmark spec JSON")
+	suitePath := flag.String("suite", "", "Path to benchmark suite JSON with several scenarios and candidates")
+	junitPath := flag.String("junit", "", "Write benchmark/suite checks as JUnit XML to this path")
+	markdownPath := flag.String("markdown", "", "Write a Markdown benchmark/suite scorecard to this path")
+	updateGolden := flag.Bool("update-golden", false, "Re-simulate baselines and rewrite their golden scorecards before benchmarking")
+	verifyGolden := flag.Bool("verify-golden", false, "Re-simulate baselines and fail if they drifted from their golden scorecards")
 	noRender := flag.Bool("no-render", false, "Disable terminal rendering")
 	captureTimeline := flag.Bool("timeline", false, "Include per-step timeline in report JSON")
 	out := flag.String("out", "", "Optional report output path override for single config mode")
+	tripsPath := flag.String("trips", "", "Write one record per vehicle to this path as CSV (.csv) or JSONL (single config mode)")
+	htmlPath := flag.String("html", "", "Write a self-contained HTML report with charts and playback to this path (single config mode)")
+	seed := flag.Uint64("seed", 0, "Override the random seed of the scenario configs")
+	seriesWindow := flag.Int("series", 0, "Add a time series with one sample per this many steps to the report (single config mode)")
+	checkpointPath := flag.String("checkpoint", "", "Write the engine state to this path after -checkpoint-step steps")
+	checkpointStep := flag.Int("checkpoint-step", 0, "Step after which -checkpoint is written")
+	resumePath := flag.String("resume", "", "Resume a single-config run from a checkpoint file")
+	burst := flag.Int("burst", 5, "Vehicles queued per burst injected from the live dashboard")
 	flag.Parse()
 
+	var seedOverride *uint64
+	var seriesOverride *int
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			seedOverride = seed
+		case "series":
+			seriesOverride = seriesWindow
+		}
+	})
+
 	if *benchmarkPath != "" && *compare != "" {
 		exitErr(errors.New("benchmark mode cannot be used with compare mode"))
 	}
+	if *suitePath != "" && (*benchmarkPath != "" || *compare != "") {
+		exitErr(errors.New("suite mode cannot be used with benchmark or compare mode"))
+	}
+	if (*updateGolden || *verifyGolden) && *benchmarkPath == "" && *suitePath == "" {
+		exitErr(errors.New("-update-golden and -verify-golden require -benchmark or -suite"))
+	}
+	if *updateGolden && *verifyGolden {
+		exitErr(errors.New("-update-golden cannot be combined with -verify-golden"))
+	}
+	golden := goldenMode{update: *updateGolden, verify: *verifyGolden}
+	outputs := outputPaths{junit: *junitPath, markdown: *markdownPath}
+	if (outputs.junit != "" || outputs.markdown != "") && *benchmarkPath == "" && *suitePath == "" {
+		exitErr(errors.New("-junit and -markdown require -benchmark or -suite"))
+	}
+	singleOnly := *checkpointPath != "" || *resumePath != "" || *htmlPath != "" || *tripsPath != "" || seriesOverride != nil
+	if singleOnly && (*benchmarkPath != "" || *suitePath != "" || *compare != "") {
+		exitErr(errors.New("-checkpoint, -resume, -html, -trips and -series only apply to single config mode"))
+	}
+	if (*checkpointPath != "") != (*checkpointStep > 0) {
+		exitErr(errors.New("-checkpoint and -checkpoint-step > 0 must be used together"))
+	}
+	if *suitePath != "" {
+		if err := runSuite(*suitePath, golden, outputs); err != nil {
+			exitErr(err)
+		}
+		return
+	}
 	if *benchmarkPath != "" {
-		if err := runBenchmark(*benchmarkPath); err != nil {
+		if err := runBenchmark(*benchmarkPath, golden, outputs); err != nil {
 			exitErr(err)
 		}
 		return
@@ -36,18 +100,13 @@ func main() {
 		if len(paths) < 2 {
 			exitErr(errors.New("compare mode requires at least two config paths"))
 		}
-		if err := runCompare(paths); err != nil {
+		if err := runCompare(paths, seedOverride); err != nil {
 			exitErr(err)
 		}
 		return
 	}
 
-	cfg, err := sim.LoadConfig(*configPath)
-	if err != nil {
-		exitErr(err)
-	}
-
-	engine, err := sim.NewEngine(cfg)
+	engine, cfg, err := loadEngine(*configPath, *resumePath, seedOverride, seriesOverride)
 	if err != nil {
 		exitErr(err)
 	}
@@ -57,9 +116,39 @@ func main() {
 		render := false
 		override = &render
 	}
-	report := engine.Run(*captureTimeline, override)
+	// The HTML report draws its charts and playback from the timeline.
+	timeline := *captureTimeline || *htmlPath != ""
+	if *tripsPath != "" {
+		engine.CaptureTrips()
+	}
+	if *checkpointPath != "" {
+		if timeline {
+			engine.CaptureTimeline()
+		}
+		if err := runToCheckpoint(engine, *checkpointStep, *checkpointPath); err != nil {
+			exitErr(err)
+		}
+	}
+	report := runEngine(engine, cfg, timeline, override, *burst)
 	printReport(report)
 
+	if *htmlPath != "" {
+		if err := htmlreport.Write(*htmlPath, report, nil); err != nil {
+			exitErr(err)
+		}
+		fmt.Printf("\nHTML report written to %s\n", *htmlPath)
+	}
+	if *tripsPath != "" {
+		if err := sim.WriteTrips(*tripsPath, report.Trips); err != nil {
+			exitErr(err)
+		}
+		fmt.Printf("\nTrip log of %d vehicles written to %s\n", len(report.Trips), *tripsPath)
+		report.Trips = nil
+	}
+	if !*captureTimeline {
+		report.Timeline, report.Config = nil, nil
+	}
+
 	reportPath := cfg.ReportPath
 	if *out != "" {
 		reportPath = *out
@@ -72,11 +161,169 @@ func main() {
 	}
 }
 
-func runBenchmark(path string) error {
+// runEngine runs the remaining steps. A rendered run on a terminal gets
+// keyboard controls; otherwise the engine runs straight through.
+func runEngine(engine *sim.Engine, cfg sim.Config, captureTimeline bool, renderOverride *bool, burst int) sim.Report {
+	render := cfg.Render.Enabled
+	if renderOverride != nil {
+		render = *renderOverride
+	}
+	if !render {
+		return engine.Run(captureTimeline, renderOverride)
+	}
+	restore, err := term.Raw()
+	if err != nil {
+		return engine.Run(captureTimeline, renderOverride)
+	}
+	defer restore()
+
+	if captureTimeline {
+		engine.CaptureTimeline()
+	}
+	delay := time.Duration(cfg.Render.DelayMS) * time.Millisecond
+	newLiveRunner(engine, delay, burst).run(term.Keys(os.Stdin))
+	return engine.Finalize()
+}
+
+// loadEngine builds the engine for single config mode, either fresh from the
+// config or restored from a checkpoint, which carries its own config.
+func loadEngine(configPath, resumePath string, seedOverride *uint64, seriesOverride *int) (*sim.Engine, sim.Config, error) {
+	if resumePath != "" {
+		if seedOverride != nil {
+			return nil, sim.Config{}, errors.New("-seed cannot be changed when resuming from a checkpoint")
+		}
+		if seriesOverride != nil {
+			return nil, sim.Config{}, errors.New("-series cannot be changed when resuming from a checkpoint")
+		}
+		cp, err := sim.LoadCheckpoint(resumePath)
+		if err != nil {
+			return nil, sim.Config{}, err
+		}
+		engine, err := sim.RestoreEngine(cp)
+		if err != nil {
+			return nil, sim.Config{}, err
+		}
+		fmt.Printf("Resumed %s at step %d from %s\n", cp.Config.Name, cp.Step, resumePath)
+		return engine, cp.Config, nil
+	}
+
+	cfg, err := sim.LoadConfig(configPath)
+	if err != nil {
+		return nil, sim.Config{}, err
+	}
+	if seedOverride != nil {
+		cfg.Seed = *seedOverride
+	}
+	if seriesOverride != nil {
+		if *seriesOverride < 0 {
+			return nil, sim.Config{}, errors.New("-series must be >= 0")
+		}
+		cfg.Series.Window = *seriesOverride
+	}
+	engine, err := sim.NewEngine(cfg)
+	if err != nil {
+		return nil, sim.Config{}, err
+	}
+	return engine, cfg, nil
+}
+
+// runToCheckpoint steps the engine up to step and writes its state to path.
+func runToCheckpoint(engine *sim.Engine, step int, path string) error {
+	for n := engine.State().Step; n < step; n++ {
+		if err := engine.Step(); err != nil {
+			return fmt.Errorf("checkpoint step %d is beyond the end of the run", step)
+		}
+	}
+	cp, err := engine.Checkpoint()
+	if err != nil {
+		return err
+	}
+	if err := sim.WriteCheckpoint(path, cp); err != nil {
+		return err
+	}
+	fmt.Printf("Checkpoint at step %d written to %s\n", step, path)
+	return nil
+}
+
+// outputPaths override the junit_path and markdown_path of a spec or suite.
+type outputPaths struct {
+	junit    string
+	markdown string
+}
+
+func (o outputPaths) apply(junit, markdown *string) {
+	if o.junit != "" {
+		*junit = o.junit
+	}
+	if o.markdown != "" {
+		*markdown = o.markdown
+	}
+}
+
+func printOutputs(junit, markdown string) {
+	if junit != "" {
+		fmt.Printf("JUnit report written to %s\n", junit)
+	}
+	if markdown != "" {
+		fmt.Printf("Markdown report written to %s\n", markdown)
+	}
+}
+
+type goldenMode struct {
+	update bool
+	verify bool
+}
+
+// apply updates or verifies the golden scorecards of specs. Verification
+// returns an error when any baseline drifted.
+func (g goldenMode) apply(specs []benchmark.Spec) error {
+	drifted := false
+	for _, spec := range specs {
+		if spec.BaselineScorecard == "" {
+			return fmt.Errorf("%s: no baseline_scorecard configured", spec.Name)
+		}
+		if g.update {
+			if _, err := benchmark.UpdateGolden(spec); err != nil {
+				return fmt.Errorf("%s: %w", spec.Name, err)
+			}
+			fmt.Printf("Golden scorecard updated: %s\n", spec.BaselineScorecard)
+			continue
+		}
+		drift, err := benchmark.VerifyGolden(spec)
+		if err != nil {
+			return fmt.Errorf("%s: %w", spec.Name, err)
+		}
+		if len(drift) == 0 {
+			fmt.Printf("Golden scorecard matches: %s\n", spec.BaselineScorecard)
+			continue
+		}
+		drifted = true
+		fmt.Printf("Golden scorecard drift: %s\n", spec.BaselineScorecard)
+		for _, line := range drift {
+			fmt.Printf("- %s\n", line)
+		}
+	}
+	if drifted {
+		return errors.New("baseline drifted from golden scorecards; rerun with -update-golden if the change is intended")
+	}
+	return nil
+}
+
+func runBenchmark(path string, golden goldenMode, outputs outputPaths) error {
 	spec, err := benchmark.LoadSpec(path)
 	if err != nil {
 		return err
 	}
+	outputs.apply(&spec.JUnitPath, &spec.MarkdownPath)
+	if golden.update || golden.verify {
+		if err := golden.apply([]benchmark.Spec{spec}); err != nil {
+			return err
+		}
+		if golden.verify {
+			return nil
+		}
+		fmt.Println()
+	}
 
 	result, err := benchmark.Run(spec)
 	if err != nil {
@@ -87,19 +334,59 @@ func runBenchmark(path string) error {
 	if spec.ReportPath != "" {
 		fmt.Printf("\nBenchmark report written to %s\n", spec.ReportPath)
 	}
+	printOutputs(spec.JUnitPath, spec.MarkdownPath)
 	if !result.Passed {
 		return errors.New("benchmark failed regression checks")
 	}
 	return nil
 }
 
-func runCompare(paths []string) error {
+func runSuite(path string, golden goldenMode, outputs outputPaths) error {
+	suite, err := benchmark.LoadSuite(path)
+	if err != nil {
+		return err
+	}
+	outputs.apply(&suite.JUnitPath, &suite.MarkdownPath)
+	if golden.update || golden.verify {
+		specs := suite.GoldenSpecs()
+		if len(specs) == 0 {
+			return errors.New("suite has no scenarios with a baseline_scorecard")
+		}
+		if err := golden.apply(specs); err != nil {
+			return err
+		}
+		if golden.verify {
+			return nil
+		}
+		fmt.Println()
+	}
+
+	result, err := benchmark.RunSuite(suite)
+	if err != nil {
+		return err
+	}
+	printSuite(result)
+
+	if suite.ReportPath != "" {
+		fmt.Printf("\nSuite report written to %s\n", suite.ReportPath)
+	}
+	printOutputs(suite.JUnitPath, suite.MarkdownPath)
+	if !result.Passed {
+		return errors.New("benchmark suite failed regression checks")
+	}
+	return nil
+}
+
+func runCompare(paths []string, seed *uint64) error {
 	reports := make([]sim.Report, 0, len(paths))
 	for _, path := range paths {
 		cfg, err := sim.LoadConfig(path)
 		if err != nil {
 			return fmt.Errorf("load %q: %w", path, err)
 		}
+		if seed != nil {
+			cfg.Seed = *seed
+		}
 		engine, err := sim.NewEngine(cfg)
 		if err != nil {
 			return fmt.Errorf("build engine for %q: %w", path, err)
@@ -119,6 +406,9 @@ func runCompare(paths []string) error {
 
 func printBenchmark(result benchmark.Result) {
 	fmt.Printf("Benchmark: %s\n", result.Name)
+	if strings.HasPrefix(result.BaselineSource, "golden:") {
+		fmt.Printf("Baseline: %s\n", result.BaselineSource)
+	}
 	fmt.Println("Scorecard:")
 	fmt.Println("Case | Completed | Throughput/100 | Avg Delay | Collisions | Min TTC | Mean Abs Jerk | Hard Brakes")
 	fmt.Printf("baseline(%s) | %d | %.2f | %.2f | %d | %.2f | %.3f | %d\n",
@@ -141,6 +431,37 @@ func printBenchmark(result benchmark.Result) {
 		result.Candidate.MeanAbsJerk,
 		result.Candidate.HardBrakes,
 	)
+	fmt.Printf("Signal control: baseline switches=%d lost_time=%d | candidate switches=%d lost_time=%d\n",
+		result.Baseline.PhaseSwitches, result.Baseline.LostTimeSteps,
+		result.Candidate.PhaseSwitches, result.Candidate.LostTimeSteps)
+	fmt.Printf("Delay percentiles: baseline p50=%.1f p95=%.1f p99=%.1f | candidate p50=%.1f p95=%.1f p99=%.1f\n",
+		result.Baseline.P50Delay, result.Baseline.P95Delay, result.Baseline.P99Delay,
+		result.Candidate.P50Delay, result.Candidate.P95Delay, result.Candidate.P99Delay)
+	if result.Baseline.Continuous && result.Candidate.Continuous {
+		fmt.Printf("Min TTC (s): baseline=%.2f | candidate=%.2f\n",
+			result.Baseline.MinTTCSeconds, result.Candidate.MinTTCSeconds)
+	}
+	if result.Baseline.Pedestrians || result.Candidate.Pedestrians {
+		fmt.Printf("Pedestrian wait: baseline avg=%.2f p95=%.1f | candidate avg=%.2f p95=%.1f\n",
+			result.Baseline.PedestrianWait, result.Baseline.P95PedestrianWait,
+			result.Candidate.PedestrianWait, result.Candidate.P95PedestrianWait)
+	}
+	if result.Baseline.Replications > 1 || result.Candidate.Replications > 1 {
+		fmt.Printf("\nReplications: baseline=%d candidate=%d (mean, stddev, confidence interval)\n",
+			result.Baseline.Replications, result.Candidate.Replications)
+		metrics := []string{"throughput_per_100_steps", "average_delay_steps", "potential_collisions", "mean_abs_jerk", "min_ttc_steps"}
+		if result.Baseline.Continuous && result.Candidate.Continuous {
+			metrics = append(metrics, "min_ttc_seconds")
+		}
+		if result.Baseline.Pedestrians && result.Candidate.Pedestrians {
+			metrics = append(metrics, "pedestrian_wait_steps")
+		}
+		for _, metric := range metrics {
+			b, c := result.Baseline.Stats[metric], result.Candidate.Stats[metric]
+			fmt.Printf("- %s: baseline %.3f ±%.3f [%.3f, %.3f] | candidate %.3f ±%.3f [%.3f, %.3f]\n",
+				metric, b.Mean, b.StdDev, b.CILow, b.CIHigh, c.Mean, c.StdDev, c.CILow, c.CIHigh)
+		}
+	}
 
 	fmt.Println("\nChecks:")
 	for _, check := range result.Checks {
@@ -148,8 +469,12 @@ func printBenchmark(result benchmark.Result) {
 		if !check.Passed {
 			status = "FAIL"
 		}
-		fmt.Printf("- %s: %s (baseline=%.3f candidate=%.3f) -> %s\n",
-			check.Name, check.Rule, check.Baseline, check.Candidate, status)
+		significance := ""
+		if check.Test != "" {
+			significance = fmt.Sprintf(" %s p=%.4f", check.Test, check.PValue)
+		}
+		fmt.Printf("- %s: %s (baseline=%.3f candidate=%.3f%s) -> %s\n",
+			check.Name, check.Rule, check.Baseline, check.Candidate, significance, status)
 	}
 	final := "PASS"
 	if !result.Passed {
@@ -158,13 +483,77 @@ func printBenchmark(result benchmark.Result) {
 	fmt.Printf("\nOverall: %s\n", final)
 }
 
+func printSuite(result benchmark.SuiteResult) {
+	fmt.Printf("Benchmark suite: %s\n", result.Name)
+	fmt.Printf("Scenario | Candidate | %s | Overall\n", strings.Join(result.Checks, " | "))
+	for _, entry := range result.Entries {
+		passed := make(map[string]bool, len(entry.Result.Checks))
+		for _, check := range entry.Result.Checks {
+			passed[check.Name] = check.Passed
+		}
+		cells := make([]string, 0, len(result.Checks)+1)
+		for _, name := range result.Checks {
+			ok, ran := passed[name]
+			if !ran {
+				cells = append(cells, "-")
+				continue
+			}
+			cells = append(cells, passFail(ok))
+		}
+		cells = append(cells, passFail(entry.Result.Passed))
+		fmt.Printf("%s | %s | %s\n", entry.Scenario, entry.Candidate, strings.Join(cells, " | "))
+	}
+
+	if failures := result.Failures(); len(failures) > 0 {
+		fmt.Println("\nRegressions:")
+		for _, entry := range result.Entries {
+			for _, check := range entry.Result.Checks {
+				if check.Passed {
+					continue
+				}
+				fmt.Printf("- %s/%s/%s: %s (baseline=%.3f candidate=%.3f)\n",
+					entry.Scenario, entry.Candidate, check.Name, check.Rule, check.Baseline, check.Candidate)
+			}
+		}
+	}
+	fmt.Printf("\nOverall: %s\n", passFail(result.Passed))
+}
+
+func passFail(passed bool) string {
+	if passed {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
 func printReport(report sim.Report) {
 	m := report.Metrics
 	fmt.Printf("Scenario: %s\n", m.ScenarioName)
 	fmt.Printf("Spawned: %d | Completed: %d | Active: %d\n", m.VehiclesSpawned, m.VehiclesCompleted, m.ActiveVehicles)
 	fmt.Printf("Avg speed: %.3f | Avg wait: %.2f | Avg trip: %.2f\n", m.AverageNetworkSpeed, m.AverageWaitPerTrip, m.AverageTripDuration)
+	fmt.Printf("Wait p50/p90/p95/p99/max: %s | Trip p50/p90/p95/p99/max: %s\n",
+		formatPercentiles(m.WaitDistribution), formatPercentiles(m.TripDistribution))
 	fmt.Printf("Throughput/100 steps: %.2f | Max queue: %d | Potential collisions: %d\n", m.ThroughputPer100Step, m.MaxQueueOverall, m.PotentialCollisions)
 	fmt.Printf("Blocked by signal: %d | Blocked by traffic: %d\n", m.BlockedBySignal, m.BlockedByTraffic)
+	fmt.Printf("Yellow entries: %d | Clearance conflicts: %d\n", m.YellowEntries, m.ClearanceConflicts)
+	fmt.Printf("Phase switches: %d | Lost time steps: %d\n", m.PhaseSwitches, m.LostTimeSteps)
+	if m.LaneChanges > 0 {
+		fmt.Printf("Lane changes: %d | Mandatory: %d\n", m.LaneChanges, m.MandatoryLaneChanges)
+	}
+	if p := m.Pedestrians; p != nil {
+		fmt.Printf("Pedestrians: arrived=%d crossed=%d waiting=%d | Avg wait: %.2f | Wait p50/p90/p95/p99/max: %s | Vehicles blocked: %d\n",
+			p.Arrived, p.Crossed, p.Waiting, p.AverageWait, formatPercentiles(p.WaitDistribution), p.BlockedVehicles)
+		ids := make([]string, 0, len(p.Crosswalks))
+		for id := range p.Crosswalks {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			s := p.Crosswalks[id]
+			fmt.Printf("  crosswalk %s -> arrived=%d crossed=%d avg_wait=%.2f p95_wait=%d max_waiting=%d\n",
+				id, s.Arrived, s.Crossed, s.AverageWait, s.WaitDistribution.P95, s.MaxWaiting)
+		}
+	}
 
 	dirs := make([]sim.Direction, 0, len(m.DirectionStats))
 	for dir := range m.DirectionStats {
@@ -173,9 +562,54 @@ func printReport(report sim.Report) {
 	sort.Slice(dirs, func(i, j int) bool { return dirs[i] < dirs[j] })
 	for _, dir := range dirs {
 		s := m.DirectionStats[dir]
-		fmt.Printf("  %s -> spawned=%d completed=%d avg_wait=%.2f avg_trip=%.2f max_queue=%d\n",
-			dir, s.Spawned, s.Completed, s.AverageWait, s.AverageDuration, s.MaxQueue)
+		fmt.Printf("  %s -> spawned=%d completed=%d avg_wait=%.2f p95_wait=%d avg_trip=%.2f p95_trip=%d max_queue=%d\n",
+			dir, s.Spawned, s.Completed, s.AverageWait, s.WaitDistribution.P95, s.AverageDuration, s.TripDistribution.P95, s.MaxQueue)
+		for _, lane := range s.RoadLanes {
+			fmt.Printf("    lane %d: served=%d blocked_steps=%d max_queue=%d lane_changes_in=%d\n",
+				lane.Lane, lane.Served, lane.BlockedSteps, lane.MaxQueue, lane.LaneChangesIn)
+		}
+		if _, onlyThrough := s.Movements[sim.Through]; onlyThrough && len(s.Movements) == 1 {
+			continue
+		}
+		for _, movement := range []sim.Movement{sim.Through, sim.TurnRight, sim.TurnLeft} {
+			ms, ok := s.Movements[movement]
+			if !ok {
+				continue
+			}
+			fmt.Printf("    %s: spawned=%d completed=%d avg_wait=%.2f avg_trip=%.2f\n",
+				movement, ms.Spawned, ms.Completed, ms.AverageWait, ms.AverageDuration)
+		}
 	}
+
+	if len(m.ClassStats) > 1 {
+		classes := make([]string, 0, len(m.ClassStats))
+		for class := range m.ClassStats {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			s := m.ClassStats[class]
+			fmt.Printf("  %s -> spawned=%d completed=%d avg_wait=%.2f p95_wait=%d avg_trip=%.2f p95_trip=%d\n",
+				class, s.Spawned, s.Completed, s.AverageWait, s.WaitDistribution.P95, s.AverageDuration, s.TripDistribution.P95)
+		}
+	}
+
+	if len(m.IntersectionStats) > 1 {
+		ids := make([]string, 0, len(m.IntersectionStats))
+		for id := range m.IntersectionStats {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			s := m.IntersectionStats[id]
+			fmt.Printf("  %s (%d,%d) -> served=%d blocked_by_signal=%d max_queue=%d collisions=%d switches=%d lost_time=%d\n",
+				id, s.X, s.Y, s.VehiclesServed, s.BlockedBySignal, s.MaxQueue, s.PotentialCollisions, s.PhaseSwitches, s.LostTimeSteps)
+		}
+	}
+}
+
+func formatPercentiles(d sim.Distribution) string {
+	return fmt.Sprintf("%d/%d/%d/%d/%d", d.P50, d.P90, d.P95, d.P99, d.Max)
 }
 
 func printComparison(reports []sim.Report) {
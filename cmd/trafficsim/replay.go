@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/term"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
+)
+
+const (
+	defaultReplayDelay = 200 * time.Millisecond
+	minFrameDelay      = 10 * time.Millisecond
+	maxFrameDelay      = 5 * time.Second
+)
+
+// runReplay implements `trafficsim replay [flags] <report.json>`.
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := flags.String("config", "", "Scenario config for reports saved without one")
+	delayMS := flags.Int("delay", 0, "Delay between steps in milliseconds (default: the config's render delay or 200)")
+	start := flags.Int("step", 1, "Step to start at")
+	paused := flags.Bool("paused", false, "Start paused")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: trafficsim replay [flags] <report.json>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("replay needs exactly one report path")
+	}
+
+	report, cfg, err := loadTimeline(flags.Arg(0), *configPath)
+	if err != nil {
+		return err
+	}
+
+	delay := time.Duration(*delayMS) * time.Millisecond
+	if delay <= 0 {
+		delay = time.Duration(cfg.Render.DelayMS) * time.Millisecond
+	}
+	if delay <= 0 {
+		delay = defaultReplayDelay
+	}
+	r := &replayer{
+		cfg:     cfg,
+		frames:  report.Timeline,
+		stats:   sim.TimelineStats(cfg, report.Timeline),
+		playing: !*paused,
+		delay:   clampDelay(delay),
+	}
+	r.seekStep(*start)
+
+	restore, err := term.Raw()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v; press Enter after each key\n", err)
+	} else {
+		defer restore()
+	}
+	r.run(term.Keys(os.Stdin))
+	return nil
+}
+
+// loadTimeline reads a report with a timeline and the scenario config it
+// was run with. configPath overrides the config stored in the report.
+func loadTimeline(path, configPath string) (sim.Report, sim.Config, error) {
+	report, err := sim.LoadReport(path)
+	if err != nil {
+		return sim.Report{}, sim.Config{}, err
+	}
+	if len(report.Timeline) == 0 {
+		return sim.Report{}, sim.Config{}, fmt.Errorf("%s has no timeline; rerun the scenario with -timeline", path)
+	}
+	switch {
+	case configPath != "":
+		cfg, err := sim.LoadConfig(configPath)
+		return report, cfg, err
+	case report.Config != nil:
+		return report, *report.Config, nil
+	default:
+		return sim.Report{}, sim.Config{}, fmt.Errorf("%s does not include its scenario config; pass it with -config", path)
+	}
+}
+
+// replayer steps through a saved timeline under keyboard control.
+type replayer struct {
+	cfg     sim.Config
+	frames  []sim.StepSnapshot
+	stats   []sim.RenderStats
+	index   int
+	playing bool
+	delay   time.Duration
+	jumping bool
+	jumpTo  string
+}
+
+func (r *replayer) run(keys <-chan term.Key) {
+	r.draw()
+	for {
+		var tick <-chan time.Time
+		if r.playing {
+			tick = time.After(r.delay)
+		}
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				// Without input the replay plays to the end and exits.
+				keys = nil
+				if !r.playing {
+					return
+				}
+				continue
+			}
+			if !r.handle(key) {
+				return
+			}
+		case <-tick:
+			r.seek(r.index + 1)
+			if r.index == len(r.frames)-1 {
+				r.playing = false
+				if keys == nil {
+					r.draw()
+					return
+				}
+			}
+		}
+		r.draw()
+	}
+}
+
+// handle applies one key press and reports whether the replay continues.
+func (r *replayer) handle(key term.Key) bool {
+	if r.jumping {
+		switch {
+		case key == term.KeyEnter:
+			if step, err := strconv.Atoi(r.jumpTo); err == nil {
+				r.seekStep(step)
+			}
+			r.jumping = false
+		case key == term.KeyEscape:
+			r.jumping = false
+		case key == term.KeyBackspace:
+			if r.jumpTo != "" {
+				r.jumpTo = r.jumpTo[:len(r.jumpTo)-1]
+			}
+		case len(key) == 1 && key[0] >= '0' && key[0] <= '9':
+			r.jumpTo += string(key)
+		}
+		return true
+	}
+
+	switch key {
+	case "q", term.KeyEscape, term.KeyInterrupt:
+		return false
+	case " ", "p":
+		if !r.playing && r.index == len(r.frames)-1 {
+			r.index = 0
+		}
+		r.playing = !r.playing
+	case term.KeyRight, "l", "n":
+		r.playing = false
+		r.seek(r.index + 1)
+	case term.KeyLeft, "h", "b":
+		r.playing = false
+		r.seek(r.index - 1)
+	case term.KeyHome:
+		r.seek(0)
+	case term.KeyEnd:
+		r.seek(len(r.frames) - 1)
+	case term.KeyUp, "+", "=":
+		r.delay = clampDelay(r.delay / 2)
+	case term.KeyDown, "-", "_":
+		r.delay = clampDelay(r.delay * 2)
+	case "g":
+		r.playing = false
+		r.jumping = true
+		r.jumpTo = ""
+	}
+	return true
+}
+
+func (r *replayer) seek(index int) {
+	r.index = max(0, min(index, len(r.frames)-1))
+}
+
+// seekStep moves to the snapshot of a 1-based step number.
+func (r *replayer) seekStep(step int) {
+	for i, frame := range r.frames {
+		if frame.Step >= step {
+			r.seek(i)
+			return
+		}
+	}
+	r.seek(len(r.frames) - 1)
+}
+
+func (r *replayer) draw() {
+	frame := r.frames[r.index]
+	lights := frame.Lights
+	if len(lights) == 0 {
+		lights = []sim.TrafficLight{{Phase: frame.Phase, VerticalGreen: frame.LightGreen}}
+	}
+	sim.RenderGrid(r.cfg, frame.Vehicles, lights, r.stats[r.index])
+
+	state := "paused"
+	if r.playing {
+		state = "playing"
+	}
+	fmt.Printf("\nReplay %s | frame %d/%d | %s per step\n", state, r.index+1, len(r.frames), r.delay)
+	if r.jumping {
+		fmt.Printf("Jump to step: %s_  (Enter to jump, Esc to cancel)\n", r.jumpTo)
+		return
+	}
+	fmt.Println("Keys: space=play/pause  left/right=step  home/end=first/last  g=jump  +/-=speed  q=quit")
+}
+
+func clampDelay(d time.Duration) time.Duration {
+	return max(minFrameDelay, min(d, maxFrameDelay))
+}
@@ -9,25 +9,55 @@ import (
 	"sort"
 	"time"
 
-	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/sim"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
 )
 
 const noClosingTTC = 1_000_000.0
 
+// speedEpsilon absorbs rounding in speed differences.
+const speedEpsilon = 1e-9
+
+// Spec compares a candidate config against a baseline. When
+// BaselineScorecard is set the baseline is read from that golden scorecard
+// instead of being simulated.
 type Spec struct {
-	Name            string     `json:"name"`
-	BaselineConfig  string     `json:"baseline_config"`
-	CandidateConfig string     `json:"candidate_config"`
-	Thresholds      Thresholds `json:"thresholds"`
-	ReportPath      string     `json:"report_path"`
+	Name              string       `json:"name"`
+	BaselineConfig    string       `json:"baseline_config"`
+	BaselineScorecard string       `json:"baseline_scorecard"`
+	CandidateConfig   string       `json:"candidate_config"`
+	Replications      int          `json:"replications"`
+	Significance      Significance `json:"significance"`
+	Thresholds        Thresholds   `json:"thresholds"`
+	ReportPath        string       `json:"report_path"`
+	JUnitPath         string       `json:"junit_path"`
+	MarkdownPath      string       `json:"markdown_path"`
+}
+
+// Significance configures how replicated runs are compared. A check passes
+// only when the test shows, at level Alpha, that the candidate stays within
+// its threshold. Confidence intervals in the scorecards use 1 - Alpha.
+type Significance struct {
+	Test             string  `json:"test"`
+	Alpha            float64 `json:"alpha"`
+	BootstrapSamples int     `json:"bootstrap_samples"`
 }
 
+// Thresholds bound how far the candidate may fall behind the baseline. The
+// delay percentile and pedestrian wait thresholds are optional; each adds a
+// check when set.
 type Thresholds struct {
 	MaxCollisionIncrease int     `json:"max_collision_increase"`
 	MaxDelayIncrease     float64 `json:"max_delay_increase"`
 	MinThroughputRatio   float64 `json:"min_throughput_ratio"`
 	MaxJerkIncrease      float64 `json:"max_jerk_increase"`
 	MaxMinTTCDrop        float64 `json:"max_min_ttc_drop"`
+
+	MaxP50DelayIncrease *float64 `json:"max_p50_delay_increase,omitempty"`
+	MaxP90DelayIncrease *float64 `json:"max_p90_delay_increase,omitempty"`
+	MaxP95DelayIncrease *float64 `json:"max_p95_delay_increase,omitempty"`
+	MaxP99DelayIncrease *float64 `json:"max_p99_delay_increase,omitempty"`
+
+	MaxPedestrianWaitIncrease *float64 `json:"max_pedestrian_wait_increase,omitempty"`
 }
 
 type Scorecard struct {
@@ -35,10 +65,35 @@ type Scorecard struct {
 	VehiclesCompleted   int     `json:"vehicles_completed"`
 	ThroughputPer100    float64 `json:"throughput_per_100_steps"`
 	AverageDelay        float64 `json:"average_delay_steps"`
+	P50Delay            float64 `json:"p50_delay_steps"`
+	P90Delay            float64 `json:"p90_delay_steps"`
+	P95Delay            float64 `json:"p95_delay_steps"`
+	P99Delay            float64 `json:"p99_delay_steps"`
 	PotentialCollisions int     `json:"potential_collisions"`
+	ClearanceConflicts  int     `json:"clearance_conflicts"`
+	PhaseSwitches       int     `json:"phase_switches"`
+	LostTimeSteps       int     `json:"lost_time_steps"`
 	MinTTCSteps         float64 `json:"min_ttc_steps"`
 	MeanAbsJerk         float64 `json:"mean_abs_jerk"`
 	HardBrakes          int     `json:"hard_brakes"`
+
+	// Continuous scorecards come from the idm dynamics model. Their TTC is
+	// measured between continuous positions rather than cells and is also
+	// given in seconds, which the min TTC check then compares.
+	Continuous    bool    `json:"continuous,omitempty"`
+	MinTTCSeconds float64 `json:"min_ttc_seconds,omitempty"`
+
+	// Scorecards of scenarios with crosswalks also report how long
+	// pedestrians waited at the curb.
+	Pedestrians       bool    `json:"pedestrians,omitempty"`
+	PedestrianWait    float64 `json:"pedestrian_wait_steps,omitempty"`
+	P95PedestrianWait float64 `json:"p95_pedestrian_wait_steps,omitempty"`
+
+	// Replicated runs report the mean in the fields above, rounded for
+	// counts, plus the per-replication samples and their summary.
+	Replications int                    `json:"replications,omitempty"`
+	Stats        map[string]SampleStats `json:"stats,omitempty"`
+	Samples      map[string][]float64   `json:"samples,omitempty"`
 }
 
 type CheckResult struct {
@@ -46,16 +101,68 @@ type CheckResult struct {
 	Rule      string  `json:"rule"`
 	Baseline  float64 `json:"baseline"`
 	Candidate float64 `json:"candidate"`
+	Test      string  `json:"test,omitempty"`
+	PValue    float64 `json:"p_value,omitempty"`
 	Passed    bool    `json:"passed"`
 }
 
+var scorecardMetrics = []struct {
+	name  string
+	value func(Scorecard) float64
+}{
+	{"vehicles_completed", func(s Scorecard) float64 { return float64(s.VehiclesCompleted) }},
+	{"throughput_per_100_steps", func(s Scorecard) float64 { return s.ThroughputPer100 }},
+	{"average_delay_steps", func(s Scorecard) float64 { return s.AverageDelay }},
+	{"p50_delay_steps", func(s Scorecard) float64 { return s.P50Delay }},
+	{"p90_delay_steps", func(s Scorecard) float64 { return s.P90Delay }},
+	{"p95_delay_steps", func(s Scorecard) float64 { return s.P95Delay }},
+	{"p99_delay_steps", func(s Scorecard) float64 { return s.P99Delay }},
+	{"potential_collisions", func(s Scorecard) float64 { return float64(s.PotentialCollisions) }},
+	{"clearance_conflicts", func(s Scorecard) float64 { return float64(s.ClearanceConflicts) }},
+	{"phase_switches", func(s Scorecard) float64 { return float64(s.PhaseSwitches) }},
+	{"lost_time_steps", func(s Scorecard) float64 { return float64(s.LostTimeSteps) }},
+	{"min_ttc_steps", func(s Scorecard) float64 { return s.MinTTCSteps }},
+	{"mean_abs_jerk", func(s Scorecard) float64 { return s.MeanAbsJerk }},
+	{"hard_brakes", func(s Scorecard) float64 { return float64(s.HardBrakes) }},
+	{"min_ttc_seconds", func(s Scorecard) float64 { return s.MinTTCSeconds }},
+	{"pedestrian_wait_steps", func(s Scorecard) float64 { return s.PedestrianWait }},
+	{"p95_pedestrian_wait_steps", func(s Scorecard) float64 { return s.P95PedestrianWait }},
+}
+
+// measured reports whether a scorecard measures the metric at all. Only
+// continuous scorecards measure TTC in seconds and only scenarios with
+// crosswalks measure pedestrian waits.
+func measured(score Scorecard, name string) bool {
+	switch name {
+	case "min_ttc_seconds":
+		return score.Continuous
+	case "pedestrian_wait_steps", "p95_pedestrian_wait_steps":
+		return score.Pedestrians
+	}
+	return true
+}
+
 type Result struct {
-	Name      string        `json:"name"`
-	Generated time.Time     `json:"generated"`
-	Baseline  Scorecard     `json:"baseline"`
-	Candidate Scorecard     `json:"candidate"`
-	Checks    []CheckResult `json:"checks"`
-	Passed    bool          `json:"passed"`
+	Name           string        `json:"name"`
+	Generated      time.Time     `json:"generated"`
+	BaselineSource string        `json:"baseline_source"`
+	Baseline       Scorecard     `json:"baseline"`
+	Candidate      Scorecard     `json:"candidate"`
+	Checks         []CheckResult `json:"checks"`
+	Passed         bool          `json:"passed"`
+}
+
+// LoadResult reads a benchmark result written to a spec's report_path.
+func LoadResult(path string) (Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("read benchmark result: %w", err)
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return Result{}, fmt.Errorf("parse benchmark result %q: %w", path, err)
+	}
+	return result, nil
 }
 
 func LoadSpec(path string) (Spec, error) {
@@ -96,6 +203,26 @@ func applySpecDefaults(spec *Spec) {
 	if spec.Thresholds.MaxMinTTCDrop < 0 {
 		spec.Thresholds.MaxMinTTCDrop = 0
 	}
+	for _, p := range spec.Thresholds.delayPercentiles() {
+		if p.increase != nil && *p.increase < 0 {
+			*p.increase = 0
+		}
+	}
+	if p := spec.Thresholds.MaxPedestrianWaitIncrease; p != nil && *p < 0 {
+		*p = 0
+	}
+	if spec.Replications <= 0 {
+		spec.Replications = 1
+	}
+	if spec.Significance.Test == "" {
+		spec.Significance.Test = TestWelch
+	}
+	if spec.Significance.Alpha <= 0 {
+		spec.Significance.Alpha = 0.05
+	}
+	if spec.Significance.BootstrapSamples <= 0 {
+		spec.Significance.BootstrapSamples = 2000
+	}
 }
 
 func resolveSpecPaths(spec *Spec, baseDir string) {
@@ -105,17 +232,26 @@ func resolveSpecPaths(spec *Spec, baseDir string) {
 	if spec.BaselineConfig != "" && !filepath.IsAbs(spec.BaselineConfig) {
 		spec.BaselineConfig = filepath.Join(baseDir, spec.BaselineConfig)
 	}
+	if spec.BaselineScorecard != "" && !filepath.IsAbs(spec.BaselineScorecard) {
+		spec.BaselineScorecard = filepath.Join(baseDir, spec.BaselineScorecard)
+	}
 	if spec.CandidateConfig != "" && !filepath.IsAbs(spec.CandidateConfig) {
 		spec.CandidateConfig = filepath.Join(baseDir, spec.CandidateConfig)
 	}
 	if spec.ReportPath != "" && !filepath.IsAbs(spec.ReportPath) {
 		spec.ReportPath = filepath.Join(baseDir, spec.ReportPath)
 	}
+	if spec.JUnitPath != "" && !filepath.IsAbs(spec.JUnitPath) {
+		spec.JUnitPath = filepath.Join(baseDir, spec.JUnitPath)
+	}
+	if spec.MarkdownPath != "" && !filepath.IsAbs(spec.MarkdownPath) {
+		spec.MarkdownPath = filepath.Join(baseDir, spec.MarkdownPath)
+	}
 }
 
 func validateSpec(spec Spec) error {
-	if spec.BaselineConfig == "" {
-		return fmt.Errorf("baseline_config is required")
+	if spec.BaselineConfig == "" && spec.BaselineScorecard == "" {
+		return fmt.Errorf("baseline_config or baseline_scorecard is required")
 	}
 	if spec.CandidateConfig == "" {
 		return fmt.Errorf("candidate_config is required")
@@ -123,34 +259,126 @@ func validateSpec(spec Spec) error {
 	if spec.Thresholds.MinThroughputRatio <= 0 {
 		return fmt.Errorf("threshold min_throughput_ratio must be > 0")
 	}
+	if spec.Significance.Test != TestWelch && spec.Significance.Test != TestBootstrap {
+		return fmt.Errorf("unsupported significance test %q", spec.Significance.Test)
+	}
+	if spec.Significance.Alpha >= 1 {
+		return fmt.Errorf("significance alpha must be < 1")
+	}
 	return nil
 }
 
 func Run(spec Spec) (Result, error) {
-	baseScore, err := runScenario(spec.BaselineConfig)
+	baseScore, source, err := baselineScorecard(spec)
 	if err != nil {
-		return Result{}, fmt.Errorf("run baseline scenario: %w", err)
+		return Result{}, err
 	}
-	candScore, err := runScenario(spec.CandidateConfig)
+	candScore, err := runReplications(spec, spec.CandidateConfig)
 	if err != nil {
 		return Result{}, fmt.Errorf("run candidate scenario: %w", err)
 	}
 
 	result := evaluate(spec, baseScore, candScore)
+	result.BaselineSource = source
 	if spec.ReportPath != "" {
 		if err := writeResult(spec.ReportPath, result); err != nil {
 			return Result{}, err
 		}
 	}
+	if err := writeOutputs(spec.Name, spec.JUnitPath, spec.MarkdownPath, result); err != nil {
+		return Result{}, err
+	}
 	return result, nil
 }
 
-func runScenario(configPath string) (Scorecard, error) {
+// baselineScorecard returns the golden scorecard when the spec pins one and
+// simulates the baseline otherwise, along with where the numbers came from.
+func baselineScorecard(spec Spec) (Scorecard, string, error) {
+	if spec.BaselineScorecard != "" {
+		score, err := goldenBaseline(spec)
+		if err != nil {
+			return Scorecard{}, "", err
+		}
+		return score, "golden:" + spec.BaselineScorecard, nil
+	}
+	score, err := runReplications(spec, spec.BaselineConfig)
+	if err != nil {
+		return Scorecard{}, "", fmt.Errorf("run baseline scenario: %w", err)
+	}
+	return score, "simulated", nil
+}
+
+// runReplications runs a scenario spec.Replications times. Replication i uses
+// the config seed plus i, so baseline and candidate sharing a seed see the
+// same random demand in each replication.
+func runReplications(spec Spec, configPath string) (Scorecard, error) {
 	cfg, err := sim.LoadConfig(configPath)
 	if err != nil {
 		return Scorecard{}, fmt.Errorf("load config %q: %w", configPath, err)
 	}
+	if spec.Replications <= 1 {
+		return runScenario(cfg)
+	}
 
+	runs := make([]Scorecard, 0, spec.Replications)
+	seed := cfg.Seed
+	for i := 0; i < spec.Replications; i++ {
+		cfg.Seed = seed + uint64(i)
+		score, err := runScenario(cfg)
+		if err != nil {
+			return Scorecard{}, fmt.Errorf("replication %d: %w", i+1, err)
+		}
+		// Only the first replication writes the scenario report.
+		cfg.ReportPath = ""
+		runs = append(runs, score)
+	}
+	return aggregate(runs, 1-spec.Significance.Alpha), nil
+}
+
+func aggregate(runs []Scorecard, confidence float64) Scorecard {
+	score := Scorecard{
+		ScenarioName: runs[0].ScenarioName,
+		Continuous:   runs[0].Continuous,
+		Pedestrians:  runs[0].Pedestrians,
+		Replications: len(runs),
+		Stats:        map[string]SampleStats{},
+		Samples:      map[string][]float64{},
+	}
+	for _, metric := range scorecardMetrics {
+		if !measured(score, metric.name) {
+			continue
+		}
+		samples := make([]float64, len(runs))
+		for i, run := range runs {
+			samples[i] = metric.value(run)
+		}
+		score.Samples[metric.name] = samples
+		score.Stats[metric.name] = summarize(samples, confidence)
+	}
+
+	mean := func(name string) float64 { return score.Stats[name].Mean }
+	count := func(name string) int { return int(math.Round(mean(name))) }
+	score.VehiclesCompleted = count("vehicles_completed")
+	score.ThroughputPer100 = mean("throughput_per_100_steps")
+	score.AverageDelay = mean("average_delay_steps")
+	score.P50Delay = mean("p50_delay_steps")
+	score.P90Delay = mean("p90_delay_steps")
+	score.P95Delay = mean("p95_delay_steps")
+	score.P99Delay = mean("p99_delay_steps")
+	score.PotentialCollisions = count("potential_collisions")
+	score.ClearanceConflicts = count("clearance_conflicts")
+	score.PhaseSwitches = count("phase_switches")
+	score.LostTimeSteps = count("lost_time_steps")
+	score.MinTTCSteps = mean("min_ttc_steps")
+	score.MeanAbsJerk = mean("mean_abs_jerk")
+	score.HardBrakes = count("hard_brakes")
+	score.MinTTCSeconds = mean("min_ttc_seconds")
+	score.PedestrianWait = mean("pedestrian_wait_steps")
+	score.P95PedestrianWait = mean("p95_pedestrian_wait_steps")
+	return score
+}
+
+func runScenario(cfg sim.Config) (Scorecard, error) {
 	engine, err := sim.NewEngine(cfg)
 	if err != nil {
 		return Scorecard{}, fmt.Errorf("create engine for %q: %w", cfg.Name, err)
@@ -164,64 +392,189 @@ func runScenario(configPath string) (Scorecard, error) {
 		}
 	}
 
-	minTTC, meanJerk, hardBrakes := analyzeTimeline(report.Timeline)
-	return Scorecard{
+	minTTC, meanJerk, hardBrakes := analyzeTimeline(cfg, report.Timeline)
+	wait := report.Metrics.WaitDistribution
+	score := Scorecard{
 		ScenarioName:        report.Metrics.ScenarioName,
 		VehiclesCompleted:   report.Metrics.VehiclesCompleted,
 		ThroughputPer100:    report.Metrics.ThroughputPer100Step,
 		AverageDelay:        report.Metrics.AverageWaitPerTrip,
+		P50Delay:            float64(wait.P50),
+		P90Delay:            float64(wait.P90),
+		P95Delay:            float64(wait.P95),
+		P99Delay:            float64(wait.P99),
 		PotentialCollisions: report.Metrics.PotentialCollisions,
+		ClearanceConflicts:  report.Metrics.ClearanceConflicts,
+		PhaseSwitches:       report.Metrics.PhaseSwitches,
+		LostTimeSteps:       report.Metrics.LostTimeSteps,
 		MinTTCSteps:         minTTC,
 		MeanAbsJerk:         meanJerk,
 		HardBrakes:          hardBrakes,
-	}, nil
+	}
+	if cfg.DynamicsModel() == sim.DynamicsIDM {
+		_, stepSeconds := cfg.Units()
+		score.Continuous = true
+		score.MinTTCSeconds = min(minTTC*stepSeconds, noClosingTTC)
+	}
+	if p := report.Metrics.Pedestrians; p != nil {
+		score.Pedestrians = true
+		score.PedestrianWait = p.AverageWait
+		score.P95PedestrianWait = float64(p.WaitDistribution.P95)
+	}
+	return score, nil
 }
 
-func evaluate(spec Spec, baseline Scorecard, candidate Scorecard) Result {
-	checks := []CheckResult{
-		{
-			Name:      "throughput",
-			Rule:      fmt.Sprintf("candidate throughput >= baseline * %.3f", spec.Thresholds.MinThroughputRatio),
-			Baseline:  baseline.ThroughputPer100,
-			Candidate: candidate.ThroughputPer100,
-			Passed:    candidate.ThroughputPer100 >= baseline.ThroughputPer100*spec.Thresholds.MinThroughputRatio,
+// scorecardCheck is a non-inferiority check of one scorecard metric: the
+// candidate may be at most margin worse than scale times the baseline.
+type scorecardCheck struct {
+	result       CheckResult
+	metric       string
+	higherBetter bool
+	scale        float64
+	margin       float64
+}
+
+// delayPercentiles lists the optional delay percentile thresholds by
+// percentile name; unset thresholds are nil.
+func (t *Thresholds) delayPercentiles() []struct {
+	name     string
+	increase *float64
+} {
+	return []struct {
+		name     string
+		increase *float64
+	}{
+		{"p50", t.MaxP50DelayIncrease},
+		{"p90", t.MaxP90DelayIncrease},
+		{"p95", t.MaxP95DelayIncrease},
+		{"p99", t.MaxP99DelayIncrease},
+	}
+}
+
+// minTTCCheck compares the min TTC in seconds when both scorecards are
+// continuous and in steps otherwise.
+func minTTCCheck(spec Spec, baseline Scorecard, candidate Scorecard) scorecardCheck {
+	check := scorecardCheck{
+		result: CheckResult{
+			Name:      "min_ttc",
+			Rule:      fmt.Sprintf("candidate min TTC >= baseline - %.3f", spec.Thresholds.MaxMinTTCDrop),
+			Baseline:  baseline.MinTTCSteps,
+			Candidate: candidate.MinTTCSteps,
 		},
+		metric:       "min_ttc_steps",
+		higherBetter: true,
+		scale:        1,
+		margin:       spec.Thresholds.MaxMinTTCDrop,
+	}
+	if baseline.Continuous && candidate.Continuous {
+		check.result.Rule = fmt.Sprintf("candidate min TTC (s) >= baseline - %.3f", spec.Thresholds.MaxMinTTCDrop)
+		check.result.Baseline = baseline.MinTTCSeconds
+		check.result.Candidate = candidate.MinTTCSeconds
+		check.metric = "min_ttc_seconds"
+	}
+	return check
+}
+
+func evaluate(spec Spec, baseline Scorecard, candidate Scorecard) Result {
+	checks := []scorecardCheck{
 		{
-			Name:      "average_delay",
-			Rule:      fmt.Sprintf("candidate delay <= baseline + %.3f", spec.Thresholds.MaxDelayIncrease),
-			Baseline:  baseline.AverageDelay,
-			Candidate: candidate.AverageDelay,
-			Passed:    candidate.AverageDelay <= baseline.AverageDelay+spec.Thresholds.MaxDelayIncrease,
+			result: CheckResult{
+				Name:      "throughput",
+				Rule:      fmt.Sprintf("candidate throughput >= baseline * %.3f", spec.Thresholds.MinThroughputRatio),
+				Baseline:  baseline.ThroughputPer100,
+				Candidate: candidate.ThroughputPer100,
+			},
+			metric:       "throughput_per_100_steps",
+			higherBetter: true,
+			scale:        spec.Thresholds.MinThroughputRatio,
 		},
 		{
-			Name:      "potential_collisions",
-			Rule:      fmt.Sprintf("candidate collisions <= baseline + %d", spec.Thresholds.MaxCollisionIncrease),
-			Baseline:  float64(baseline.PotentialCollisions),
-			Candidate: float64(candidate.PotentialCollisions),
-			Passed:    candidate.PotentialCollisions <= baseline.PotentialCollisions+spec.Thresholds.MaxCollisionIncrease,
+			result: CheckResult{
+				Name:      "average_delay",
+				Rule:      fmt.Sprintf("candidate delay <= baseline + %.3f", spec.Thresholds.MaxDelayIncrease),
+				Baseline:  baseline.AverageDelay,
+				Candidate: candidate.AverageDelay,
+			},
+			metric: "average_delay_steps",
+			scale:  1,
+			margin: spec.Thresholds.MaxDelayIncrease,
 		},
 		{
-			Name:      "mean_abs_jerk",
-			Rule:      fmt.Sprintf("candidate mean abs jerk <= baseline + %.3f", spec.Thresholds.MaxJerkIncrease),
-			Baseline:  baseline.MeanAbsJerk,
-			Candidate: candidate.MeanAbsJerk,
-			Passed:    candidate.MeanAbsJerk <= baseline.MeanAbsJerk+spec.Thresholds.MaxJerkIncrease,
+			result: CheckResult{
+				Name:      "potential_collisions",
+				Rule:      fmt.Sprintf("candidate collisions <= baseline + %d", spec.Thresholds.MaxCollisionIncrease),
+				Baseline:  float64(baseline.PotentialCollisions),
+				Candidate: float64(candidate.PotentialCollisions),
+			},
+			metric: "potential_collisions",
+			scale:  1,
+			margin: float64(spec.Thresholds.MaxCollisionIncrease),
 		},
 		{
-			Name:      "min_ttc",
-			Rule:      fmt.Sprintf("candidate min TTC >= baseline - %.3f", spec.Thresholds.MaxMinTTCDrop),
-			Baseline:  baseline.MinTTCSteps,
-			Candidate: candidate.MinTTCSteps,
-			Passed:    candidate.MinTTCSteps >= baseline.MinTTCSteps-spec.Thresholds.MaxMinTTCDrop,
+			result: CheckResult{
+				Name:      "mean_abs_jerk",
+				Rule:      fmt.Sprintf("candidate mean abs jerk <= baseline + %.3f", spec.Thresholds.MaxJerkIncrease),
+				Baseline:  baseline.MeanAbsJerk,
+				Candidate: candidate.MeanAbsJerk,
+			},
+			metric: "mean_abs_jerk",
+			scale:  1,
+			margin: spec.Thresholds.MaxJerkIncrease,
 		},
+		minTTCCheck(spec, baseline, candidate),
+	}
+	for _, p := range spec.Thresholds.delayPercentiles() {
+		if p.increase == nil {
+			continue
+		}
+		metric := p.name + "_delay_steps"
+		checks = append(checks, scorecardCheck{
+			result: CheckResult{
+				Name:      p.name + "_delay",
+				Rule:      fmt.Sprintf("candidate %s delay <= baseline + %.3f", p.name, *p.increase),
+				Baseline:  metricValue(baseline, metric),
+				Candidate: metricValue(candidate, metric),
+			},
+			metric: metric,
+			scale:  1,
+			margin: *p.increase,
+		})
+	}
+	if increase := spec.Thresholds.MaxPedestrianWaitIncrease; increase != nil {
+		checks = append(checks, scorecardCheck{
+			result: CheckResult{
+				Name:      "pedestrian_wait",
+				Rule:      fmt.Sprintf("candidate pedestrian wait <= baseline + %.3f", *increase),
+				Baseline:  baseline.PedestrianWait,
+				Candidate: candidate.PedestrianWait,
+			},
+			metric: "pedestrian_wait_steps",
+			scale:  1,
+			margin: *increase,
+		})
 	}
 
+	replicated := baseline.Replications > 1 || candidate.Replications > 1
+	results := make([]CheckResult, 0, len(checks))
 	passed := true
 	for _, check := range checks {
-		if !check.Passed {
+		base := metricSamples(baseline, check.metric)
+		cand := metricSamples(candidate, check.metric)
+		if check.higherBetter {
+			base, cand = negate(base), negate(cand)
+		}
+		result := check.result
+		p, ok := nonInferiority(spec.Significance.Test, cand, base, check.scale, check.margin,
+			spec.Significance.Alpha, checkRNG(check.result.Name), spec.Significance.BootstrapSamples)
+		if replicated {
+			result.Test = spec.Significance.Test
+			result.PValue = p
+		}
+		result.Passed = ok
+		if !ok {
 			passed = false
-			break
 		}
+		results = append(results, result)
 	}
 
 	return Result{
@@ -229,12 +582,38 @@ func evaluate(spec Spec, baseline Scorecard, candidate Scorecard) Result {
 		Generated: time.Now().UTC(),
 		Baseline:  baseline,
 		Candidate: candidate,
-		Checks:    checks,
+		Checks:    results,
 		Passed:    passed,
 	}
 }
 
-func writeResult(path string, result Result) error {
+// metricSamples returns the replication samples of a metric, or the single
+// value of an unreplicated scorecard.
+func metricSamples(score Scorecard, name string) []float64 {
+	if samples, ok := score.Samples[name]; ok {
+		return samples
+	}
+	return []float64{metricValue(score, name)}
+}
+
+func metricValue(score Scorecard, name string) float64 {
+	for _, metric := range scorecardMetrics {
+		if metric.name == name {
+			return metric.value(score)
+		}
+	}
+	return 0
+}
+
+func negate(samples []float64) []float64 {
+	out := make([]float64, len(samples))
+	for i, v := range samples {
+		out[i] = -v
+	}
+	return out
+}
+
+func writeResult(path string, result any) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return fmt.Errorf("create benchmark report dir: %w", err)
 	}
@@ -248,16 +627,36 @@ func writeResult(path string, result Result) error {
 	return nil
 }
 
-func analyzeTimeline(timeline []sim.StepSnapshot) (float64, float64, int) {
+// analyzeTimeline derives the comfort and safety metrics from the speeds of
+// the vehicles in every snapshot. A vehicle brakes hard when its speed drops
+// by more than its class decelerates comfortably in one step. Unit dynamics
+// stop vehicles within a step, so there the speed is the number of cells
+// moved since the last snapshot and any drop of a full cell per step is
+// hard.
+func analyzeTimeline(cfg sim.Config, timeline []sim.StepSnapshot) (float64, float64, int) {
 	if len(timeline) == 0 {
 		return noClosingTTC, 0, 0
 	}
 
+	classes := cfg.ResolvedClasses()
+	unit := cfg.DynamicsModel() == sim.DynamicsUnit
+	continuous := cfg.DynamicsModel() == sim.DynamicsIDM
+	hardBrake := func(v sim.Vehicle, decel float64) bool {
+		if unit {
+			return decel >= 1-speedEpsilon
+		}
+		class, ok := classes[v.Class]
+		if !ok {
+			class = classes[sim.DefaultClass]
+		}
+		return decel > class.Deceleration+speedEpsilon
+	}
+
 	type state struct {
 		x, y     int
 		speed    float64
 		accel    float64
-		hasPos   bool
+		hasSpeed bool
 		hasAccel bool
 	}
 
@@ -271,31 +670,35 @@ func analyzeTimeline(timeline []sim.StepSnapshot) (float64, float64, int) {
 		currentSpeeds := map[int]float64{}
 		for _, v := range snap.Vehicles {
 			prev := states[v.ID]
-			speed := 0.0
-			if prev.hasPos {
-				speed = float64(abs(v.X-prev.x) + abs(v.Y-prev.y))
+			speed := v.Speed
+			if unit {
+				// Vehicles enter at a standstill, so their first snapshot
+				// already counts as an acceleration sample.
+				speed = 0
+				if prev.hasSpeed {
+					speed = float64(abs(v.X-prev.x) + abs(v.Y-prev.y))
+				}
 			}
 			currentSpeeds[v.ID] = speed
+			next := state{x: v.X, y: v.Y, speed: speed, hasSpeed: true}
+			if !prev.hasSpeed && !unit {
+				states[v.ID] = next
+				continue
+			}
 
 			accel := speed - prev.speed
-			if prev.hasPos && accel <= -1 {
+			if hardBrake(v, -accel) {
 				hardBrakes++
 			}
 			if prev.hasAccel {
 				jerkSum += math.Abs(accel - prev.accel)
 				jerkSamples++
 			}
-			states[v.ID] = state{
-				x:        v.X,
-				y:        v.Y,
-				speed:    speed,
-				accel:    accel,
-				hasPos:   true,
-				hasAccel: true,
-			}
+			next.accel, next.hasAccel = accel, true
+			states[v.ID] = next
 		}
 
-		if ttc := minTTCStep(snap.Vehicles, currentSpeeds); ttc < minTTC {
+		if ttc := minTTCStep(snap.Vehicles, currentSpeeds, continuous); ttc < minTTC {
 			minTTC = ttc
 		}
 	}
@@ -307,18 +710,26 @@ func analyzeTimeline(timeline []sim.StepSnapshot) (float64, float64, int) {
 	return minTTC, meanJerk, hardBrakes
 }
 
-func minTTCStep(vehicles []sim.Vehicle, speeds map[int]float64) float64 {
+// minTTCStep returns the smallest time to collision, in steps, between
+// vehicles following each other in a lane. On the grid, where a follower may
+// not yet be closing in, it falls back to the headway in cells as a proxy.
+// Continuous positions add each vehicle's progress into its cell to the gap,
+// and with no closing pair there is no collision to time.
+func minTTCStep(vehicles []sim.Vehicle, speeds map[int]float64, continuous bool) float64 {
 	type laneKey struct {
-		dir sim.Direction
-		key int
+		dir      sim.Direction
+		key      int
+		roadLane int
 	}
 	lanes := map[laneKey][]sim.Vehicle{}
 	for _, v := range vehicles {
+		dir := v.CurrentHeading()
 		key := v.Y
-		if v.Direction == sim.Up || v.Direction == sim.Down {
+		if dir == sim.Up || dir == sim.Down {
 			key = v.X
 		}
-		lanes[laneKey{dir: v.Direction, key: key}] = append(lanes[laneKey{dir: v.Direction, key: key}], v)
+		lane := laneKey{dir: dir, key: key, roadLane: v.RoadLane}
+		lanes[lane] = append(lanes[lane], v)
 	}
 
 	minTTC := noClosingTTC
@@ -347,16 +758,27 @@ func minTTCStep(vehicles []sim.Vehicle, speeds map[int]float64) float64 {
 			leader := laneVehicles[i-1]
 			follower := laneVehicles[i]
 
-			gap := 0
-			switch k.dir {
-			case sim.Up:
-				gap = follower.Y - leader.Y - 1
-			case sim.Down:
-				gap = leader.Y - follower.Y - 1
-			case sim.Left:
-				gap = follower.X - leader.X - 1
-			case sim.Right:
-				gap = leader.X - follower.X - 1
+			// The gap ends at the rear of a long leader, at least at the
+			// part of it still in this lane.
+			gap := -1
+			for _, s := range leader.Segments() {
+				if s.Heading != k.dir || s.RoadLane != k.roadLane {
+					break
+				}
+				d := 0
+				switch k.dir {
+				case sim.Up:
+					d = follower.Y - s.Y - 1
+				case sim.Down:
+					d = s.Y - follower.Y - 1
+				case sim.Left:
+					d = follower.X - s.X - 1
+				case sim.Right:
+					d = s.X - follower.X - 1
+				}
+				if gap < 0 || d < gap {
+					gap = d
+				}
 			}
 			if gap < 0 {
 				gap = 0
@@ -371,13 +793,17 @@ func minTTCStep(vehicles []sim.Vehicle, speeds map[int]float64) float64 {
 				continue
 			}
 
-			ttc := float64(gap+1) / relativeSpeed
+			distance := float64(gap + 1)
+			if continuous {
+				distance = max(distance+leader.Progress-follower.Progress, 0)
+			}
+			ttc := distance / relativeSpeed
 			if ttc < minTTC {
 				minTTC = ttc
 			}
 		}
 	}
-	if minTTC == noClosingTTC {
+	if minTTC == noClosingTTC && !continuous {
 		return minHeadwayProxy
 	}
 	return minTTC
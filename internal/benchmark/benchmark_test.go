@@ -5,7 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
-	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/sim"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
 )
 
 func TestAnalyzeTimelineComputesJerkAndHardBrake(t *testing.T) {
@@ -30,7 +30,7 @@ func TestAnalyzeTimelineComputesJerkAndHardBrake(t *testing.T) {
 		},
 	}
 
-	minTTC, meanJerk, hardBrakes := analyzeTimeline(timeline)
+	minTTC, meanJerk, hardBrakes := analyzeTimeline(sim.Config{}, timeline)
 	if minTTC != noClosingTTC {
 		t.Fatalf("minTTC = %.2f, want %.2f when no closing pairs", minTTC, noClosingTTC)
 	}
@@ -42,6 +42,39 @@ func TestAnalyzeTimelineComputesJerkAndHardBrake(t *testing.T) {
 	}
 }
 
+func TestAnalyzeTimelineMeasuresCellularBrakingAgainstClass(t *testing.T) {
+	cfg := sim.Config{Dynamics: sim.DynamicsConfig{Model: sim.DynamicsCellular}}
+	speeds := []float64{3, 2, 0}
+	var timeline []sim.StepSnapshot
+	for i, speed := range speeds {
+		timeline = append(timeline, sim.StepSnapshot{
+			Step:     i + 1,
+			Vehicles: []sim.Vehicle{{ID: 1, X: i, Y: 5, Direction: sim.Right, Class: "car", Speed: speed}},
+		})
+	}
+
+	// A car comfortably drops one cell per step, so only the drop from two
+	// to a stop is hard.
+	if _, _, hardBrakes := analyzeTimeline(cfg, timeline); hardBrakes != 1 {
+		t.Fatalf("hardBrakes = %d, want 1", hardBrakes)
+	}
+}
+
+func TestMinTTCStepMeasuresGapToTheRearOfLongLeaders(t *testing.T) {
+	vehicles := []sim.Vehicle{
+		{ID: 1, X: 6, Y: 5, Direction: sim.Right, Tail: []sim.Segment{
+			{X: 5, Y: 5, Heading: sim.Right},
+			{X: 4, Y: 5, Heading: sim.Right},
+		}},
+		{ID: 2, X: 1, Y: 5, Direction: sim.Right},
+	}
+	speeds := map[int]float64{1: 0, 2: 1}
+
+	if ttc := minTTCStep(vehicles, speeds, false); ttc != 3 {
+		t.Fatalf("ttc = %.2f, want 3.00 to the rear of the truck", ttc)
+	}
+}
+
 func TestMinTTCStepDetectsClosingPair(t *testing.T) {
 	vehicles := []sim.Vehicle{
 		{ID: 1, X: 3, Y: 5, Direction: sim.Right}, // leader
@@ -52,12 +85,58 @@ func TestMinTTCStepDetectsClosingPair(t *testing.T) {
 		2: 1,
 	}
 
-	ttc := minTTCStep(vehicles, speeds)
+	ttc := minTTCStep(vehicles, speeds, false)
 	if ttc != 2 {
 		t.Fatalf("ttc = %.2f, want 2.00", ttc)
 	}
 }
 
+func TestMinTTCStepIgnoresVehiclesInOtherLanes(t *testing.T) {
+	vehicles := []sim.Vehicle{
+		{ID: 1, X: 3, Y: 5, Direction: sim.Right, RoadLane: 1},
+		{ID: 2, X: 1, Y: 5, Direction: sim.Right},
+	}
+	speeds := map[int]float64{1: 0, 2: 1}
+
+	if ttc := minTTCStep(vehicles, speeds, false); ttc != noClosingTTC {
+		t.Fatalf("ttc = %.2f, want no closing pair across lanes", ttc)
+	}
+}
+
+func TestMinTTCStepUsesContinuousPositions(t *testing.T) {
+	vehicles := []sim.Vehicle{
+		{ID: 1, X: 3, Y: 5, Direction: sim.Right, Progress: 0.25},
+		{ID: 2, X: 1, Y: 5, Direction: sim.Right, Progress: 0.75},
+	}
+	speeds := map[int]float64{1: 0.5, 2: 1.5}
+
+	if ttc := minTTCStep(vehicles, speeds, true); ttc != 1.5 {
+		t.Fatalf("ttc = %.2f, want 1.50 between continuous positions", ttc)
+	}
+	speeds[2] = 0.5
+	if ttc := minTTCStep(vehicles, speeds, true); ttc != noClosingTTC {
+		t.Fatalf("ttc = %.2f, want no headway proxy without a closing pair", ttc)
+	}
+}
+
+func TestEvaluateComparesContinuousTTCInSeconds(t *testing.T) {
+	spec := Spec{Name: "continuous", Thresholds: Thresholds{MaxMinTTCDrop: 0.5}}
+	base := Scorecard{MinTTCSteps: 4, Continuous: true, MinTTCSeconds: 2}
+	candidate := Scorecard{MinTTCSteps: 4, Continuous: true, MinTTCSeconds: 1}
+
+	result := evaluate(spec, base, candidate)
+	for _, check := range result.Checks {
+		if check.Name != "min_ttc" {
+			continue
+		}
+		if check.Passed || check.Baseline != 2 || check.Candidate != 1 {
+			t.Fatalf("min_ttc check = %+v, want a failing comparison in seconds", check)
+		}
+		return
+	}
+	t.Fatalf("no min_ttc check in %+v", result.Checks)
+}
+
 func TestEvaluateChecksFailOnRegression(t *testing.T) {
 	spec := Spec{
 		Name: "regression-check",
@@ -122,3 +201,75 @@ func TestLoadSpecResolvesRelativePaths(t *testing.T) {
 		t.Fatalf("unexpected report path: %s", spec.ReportPath)
 	}
 }
+
+func TestEvaluateAddsPercentileDelayChecks(t *testing.T) {
+	increase := 5.0
+	spec := Spec{
+		Name: "percentiles",
+		Thresholds: Thresholds{
+			MaxDelayIncrease:    1,
+			MinThroughputRatio:  0,
+			MaxJerkIncrease:     1,
+			MaxMinTTCDrop:       1,
+			MaxP95DelayIncrease: &increase,
+		},
+	}
+	base := Scorecard{AverageDelay: 5, P95Delay: 20, MinTTCSteps: 4}
+	candidate := Scorecard{AverageDelay: 5, P95Delay: 30, MinTTCSteps: 4}
+
+	result := evaluate(spec, base, candidate)
+	var p95 *CheckResult
+	for i := range result.Checks {
+		switch result.Checks[i].Name {
+		case "p95_delay":
+			p95 = &result.Checks[i]
+		case "p50_delay", "p90_delay", "p99_delay":
+			t.Fatalf("unexpected check %s without a threshold", result.Checks[i].Name)
+		}
+	}
+	if p95 == nil {
+		t.Fatalf("missing p95_delay check in %+v", result.Checks)
+	}
+	if p95.Passed || result.Passed {
+		t.Fatalf("p95 delay 20 -> 30 should fail a 5 step increase limit")
+	}
+
+	candidate.P95Delay = 24
+	if result := evaluate(spec, base, candidate); !result.Passed {
+		t.Fatalf("p95 delay 20 -> 24 should pass: %+v", result.Checks)
+	}
+}
+
+func TestEvaluateAddsPedestrianWaitCheck(t *testing.T) {
+	increase := 2.0
+	spec := Spec{
+		Name:       "pedestrians",
+		Thresholds: Thresholds{MaxDelayIncrease: 1, MaxMinTTCDrop: 1, MaxPedestrianWaitIncrease: &increase},
+	}
+	base := Scorecard{AverageDelay: 5, MinTTCSteps: 4, Pedestrians: true, PedestrianWait: 10}
+	candidate := Scorecard{AverageDelay: 4, MinTTCSteps: 4, Pedestrians: true, PedestrianWait: 13}
+
+	result := evaluate(spec, base, candidate)
+	var wait *CheckResult
+	for i := range result.Checks {
+		if result.Checks[i].Name == "pedestrian_wait" {
+			wait = &result.Checks[i]
+		}
+	}
+	if wait == nil {
+		t.Fatalf("missing pedestrian_wait check in %+v", result.Checks)
+	}
+	if wait.Passed || result.Passed {
+		t.Fatalf("pedestrian wait 10 -> 13 should fail a 2 step increase limit")
+	}
+
+	candidate.PedestrianWait = 11.5
+	if result := evaluate(spec, base, candidate); !result.Passed {
+		t.Fatalf("pedestrian wait 10 -> 11.5 should pass: %+v", result.Checks)
+	}
+
+	score := aggregate([]Scorecard{{AverageDelay: 5}, {AverageDelay: 6}}, 0.95)
+	if _, ok := score.Samples["pedestrian_wait_steps"]; ok || score.Pedestrians {
+		t.Fatalf("scenarios without crosswalks should not sample pedestrian waits: %+v", score.Samples)
+	}
+}
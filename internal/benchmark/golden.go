@@ -0,0 +1,83 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// LoadScorecard reads a scorecard previously written by WriteGolden or taken
+// from a benchmark report.
+func LoadScorecard(path string) (Scorecard, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Scorecard{}, fmt.Errorf("read golden scorecard: %w", err)
+	}
+	var score Scorecard
+	if err := json.Unmarshal(data, &score); err != nil {
+		return Scorecard{}, fmt.Errorf("parse golden scorecard %q: %w", path, err)
+	}
+	return score, nil
+}
+
+// UpdateGolden simulates the baseline of a spec and rewrites its
+// baseline_scorecard file.
+func UpdateGolden(spec Spec) (Scorecard, error) {
+	if spec.BaselineScorecard == "" {
+		return Scorecard{}, fmt.Errorf("spec %q has no baseline_scorecard to update", spec.Name)
+	}
+	if spec.BaselineConfig == "" {
+		return Scorecard{}, fmt.Errorf("spec %q needs baseline_config to update its golden scorecard", spec.Name)
+	}
+	score, err := runReplications(spec, spec.BaselineConfig)
+	if err != nil {
+		return Scorecard{}, fmt.Errorf("run baseline scenario: %w", err)
+	}
+	if err := writeResult(spec.BaselineScorecard, score); err != nil {
+		return Scorecard{}, err
+	}
+	return score, nil
+}
+
+// VerifyGolden re-simulates the baseline and lists every metric that no
+// longer matches the golden scorecard, which flags engine changes that shift
+// the reference numbers.
+func VerifyGolden(spec Spec) ([]string, error) {
+	golden, err := goldenBaseline(spec)
+	if err != nil {
+		return nil, err
+	}
+	if spec.BaselineConfig == "" {
+		return nil, fmt.Errorf("spec %q needs baseline_config to verify its golden scorecard", spec.Name)
+	}
+	current, err := runReplications(spec, spec.BaselineConfig)
+	if err != nil {
+		return nil, fmt.Errorf("run baseline scenario: %w", err)
+	}
+
+	var drift []string
+	for _, metric := range scorecardMetrics {
+		want, got := metric.value(golden), metric.value(current)
+		if math.Abs(want-got) > 1e-9 {
+			drift = append(drift, fmt.Sprintf("%s: golden=%.3f current=%.3f", metric.name, want, got))
+		}
+	}
+	return drift, nil
+}
+
+func goldenBaseline(spec Spec) (Scorecard, error) {
+	score, err := LoadScorecard(spec.BaselineScorecard)
+	if err != nil {
+		return Scorecard{}, err
+	}
+	replications := score.Replications
+	if replications == 0 {
+		replications = 1
+	}
+	if replications != spec.Replications {
+		return Scorecard{}, fmt.Errorf("golden scorecard %q has %d replications but the spec runs %d; rerun with -update-golden",
+			spec.BaselineScorecard, replications, spec.Replications)
+	}
+	return score, nil
+}
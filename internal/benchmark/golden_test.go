@@ -0,0 +1,98 @@
+package benchmark
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func goldenSpec(t *testing.T) Spec {
+	t.Helper()
+	dir := t.TempDir()
+	writeSuiteScenario(t, dir, "baseline", 5, 5)
+	writeSuiteScenario(t, dir, "candidate", 5, 5)
+	spec := Spec{
+		Name:              "golden",
+		BaselineConfig:    filepath.Join(dir, "baseline.json"),
+		BaselineScorecard: filepath.Join(dir, "golden", "baseline.json"),
+		CandidateConfig:   filepath.Join(dir, "candidate.json"),
+		Thresholds:        Thresholds{MinThroughputRatio: 1},
+	}
+	applySpecDefaults(&spec)
+	return spec
+}
+
+func TestRunUsesGoldenBaseline(t *testing.T) {
+	spec := goldenSpec(t)
+	if _, err := Run(spec); err == nil {
+		t.Fatalf("expected an error while the golden scorecard is missing")
+	}
+
+	golden, err := UpdateGolden(spec)
+	if err != nil {
+		t.Fatalf("update golden: %v", err)
+	}
+	result, err := Run(spec)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !result.Passed || result.BaselineSource != "golden:"+spec.BaselineScorecard {
+		t.Fatalf("passed=%v source=%q, want pass from golden scorecard", result.Passed, result.BaselineSource)
+	}
+
+	// A pinned baseline that is better than the engine now produces makes
+	// an otherwise identical candidate fail.
+	golden.AverageDelay = 0
+	if err := writeResult(spec.BaselineScorecard, golden); err != nil {
+		t.Fatalf("rewrite golden: %v", err)
+	}
+	result, err = Run(spec)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.Passed {
+		t.Fatalf("expected candidate to fail against the tightened golden baseline")
+	}
+}
+
+func TestVerifyGoldenReportsDrift(t *testing.T) {
+	spec := goldenSpec(t)
+	golden, err := UpdateGolden(spec)
+	if err != nil {
+		t.Fatalf("update golden: %v", err)
+	}
+	drift, err := VerifyGolden(spec)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(drift) != 0 {
+		t.Fatalf("unexpected drift right after update: %v", drift)
+	}
+
+	golden.HardBrakes++
+	if err := writeResult(spec.BaselineScorecard, golden); err != nil {
+		t.Fatalf("rewrite golden: %v", err)
+	}
+	drift, err = VerifyGolden(spec)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(drift) != 1 || !strings.HasPrefix(drift[0], "hard_brakes:") {
+		t.Fatalf("drift = %v, want hard_brakes only", drift)
+	}
+}
+
+func TestGoldenBaselineRejectsReplicationMismatch(t *testing.T) {
+	spec := goldenSpec(t)
+	if _, err := UpdateGolden(spec); err != nil {
+		t.Fatalf("update golden: %v", err)
+	}
+	spec.Replications = 3
+	if _, err := Run(spec); err == nil || !strings.Contains(err.Error(), "replications") {
+		t.Fatalf("expected replication mismatch error, got %v", err)
+	}
+	if _, err := os.Stat(spec.BaselineScorecard); err != nil {
+		t.Fatalf("golden scorecard missing: %v", err)
+	}
+}
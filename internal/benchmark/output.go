@@ -0,0 +1,194 @@
+package benchmark
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit renders benchmark results as JUnit XML with one test suite per
+// result and one test case per check, so CI systems list regressions
+// natively.
+func JUnit(name string, results ...Result) ([]byte, error) {
+	doc := junitTestSuites{Name: name}
+	for _, result := range results {
+		suite := junitTestSuite{
+			Name:      result.Name,
+			Timestamp: result.Generated.Format("2006-01-02T15:04:05"),
+		}
+		for _, check := range result.Checks {
+			tc := junitTestCase{
+				Name:      check.Name,
+				ClassName: "benchmark." + result.Name,
+				Time:      "0",
+				SystemOut: checkDetail(check),
+			}
+			if !check.Passed {
+				tc.Failure = &junitFailure{
+					Message: check.Rule,
+					Type:    "regression",
+					Text:    checkDetail(check),
+				}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+			suite.Tests++
+		}
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Suites = append(doc.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal junit report: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+func checkDetail(check CheckResult) string {
+	detail := fmt.Sprintf("%s: baseline=%.3f candidate=%.3f delta=%s",
+		check.Rule, check.Baseline, check.Candidate, formatDelta(check.Baseline, check.Candidate))
+	if check.Test != "" {
+		detail += fmt.Sprintf(" %s p=%.4f", check.Test, check.PValue)
+	}
+	return detail
+}
+
+// Markdown renders benchmark results as a scorecard that can be pasted into
+// a pull request comment.
+func Markdown(title string, results ...Result) string {
+	var b strings.Builder
+	passed := true
+	for _, result := range results {
+		passed = passed && result.Passed
+	}
+	fmt.Fprintf(&b, "# %s %s\n", badge(passed), title)
+
+	if len(results) > 1 {
+		b.WriteString("\n| Comparison | Result | Failed checks |\n|---|---|---|\n")
+		for _, result := range results {
+			var failed []string
+			for _, check := range result.Checks {
+				if !check.Passed {
+					failed = append(failed, check.Name)
+				}
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", result.Name, badge(result.Passed), strings.Join(failed, ", "))
+		}
+	}
+
+	for _, result := range results {
+		if len(results) > 1 {
+			fmt.Fprintf(&b, "\n## %s %s\n", badge(result.Passed), result.Name)
+		}
+		b.WriteString("\n")
+		if result.BaselineSource != "" && result.BaselineSource != "simulated" {
+			fmt.Fprintf(&b, "Baseline: `%s`\n\n", result.BaselineSource)
+		}
+		b.WriteString("| Case | Scenario | Completed | Throughput/100 | Avg Delay | Collisions | Min TTC | Mean Abs Jerk | Hard Brakes |\n")
+		b.WriteString("|---|---|---:|---:|---:|---:|---:|---:|---:|\n")
+		for _, row := range []struct {
+			label string
+			score Scorecard
+		}{{"baseline", result.Baseline}, {"candidate", result.Candidate}} {
+			s := row.score
+			fmt.Fprintf(&b, "| %s | %s | %d | %.2f | %.2f | %d | %.2f | %.3f | %d |\n",
+				row.label, s.ScenarioName, s.VehiclesCompleted, s.ThroughputPer100, s.AverageDelay,
+				s.PotentialCollisions, s.MinTTCSteps, s.MeanAbsJerk, s.HardBrakes)
+		}
+
+		b.WriteString("\n| Check | Rule | Baseline | Candidate | Delta | Result |\n")
+		b.WriteString("|---|---|---:|---:|---:|---|\n")
+		for _, check := range result.Checks {
+			status := badge(check.Passed)
+			if check.Test != "" {
+				status += fmt.Sprintf(" (%s p=%.4f)", check.Test, check.PValue)
+			}
+			fmt.Fprintf(&b, "| %s | `%s` | %.3f | %.3f | %s | %s |\n",
+				check.Name, check.Rule, check.Baseline, check.Candidate,
+				formatDelta(check.Baseline, check.Candidate), status)
+		}
+	}
+	return b.String()
+}
+
+func badge(passed bool) string {
+	if passed {
+		return "✅ PASS"
+	}
+	return "❌ FAIL"
+}
+
+// Delta formats the candidate's change from the baseline, with the relative
+// change when the baseline allows one.
+func (c CheckResult) Delta() string {
+	return formatDelta(c.Baseline, c.Candidate)
+}
+
+func formatDelta(baseline, candidate float64) string {
+	delta := candidate - baseline
+	if baseline == 0 || math.Abs(baseline) >= noClosingTTC {
+		return fmt.Sprintf("%+.3f", delta)
+	}
+	return fmt.Sprintf("%+.3f (%+.1f%%)", delta, delta/math.Abs(baseline)*100)
+}
+
+// writeOutputs writes the optional JUnit and Markdown renderings of results.
+func writeOutputs(name, junitPath, markdownPath string, results ...Result) error {
+	if junitPath != "" {
+		data, err := JUnit(name, results...)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(junitPath, data); err != nil {
+			return fmt.Errorf("write junit report: %w", err)
+		}
+	}
+	if markdownPath != "" {
+		if err := writeFile(markdownPath, []byte(Markdown(name, results...))); err != nil {
+			return fmt.Errorf("write markdown report: %w", err)
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
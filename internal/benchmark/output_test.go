@@ -0,0 +1,63 @@
+package benchmark
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func outputResults() []Result {
+	return []Result{
+		{
+			Name:   "rush/actuated",
+			Passed: true,
+			Checks: []CheckResult{
+				{Name: "throughput", Rule: "candidate throughput >= baseline * 0.950", Baseline: 50, Candidate: 51, Passed: true},
+			},
+		},
+		{
+			Name:   "rush/starved",
+			Passed: false,
+			Checks: []CheckResult{
+				{Name: "throughput", Rule: "candidate throughput >= baseline * 0.950", Baseline: 50, Candidate: 51, Passed: true},
+				{Name: "average_delay", Rule: "candidate delay <= baseline + 0.200", Baseline: 4, Candidate: 6, Test: TestWelch, PValue: 0.91, Passed: false},
+			},
+		},
+	}
+}
+
+func TestJUnitHasOneTestCasePerCheck(t *testing.T) {
+	data, err := JUnit("suite", outputResults()...)
+	if err != nil {
+		t.Fatalf("junit: %v", err)
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("parse junit: %v", err)
+	}
+	if doc.Tests != 3 || doc.Failures != 1 || len(doc.Suites) != 2 {
+		t.Fatalf("tests=%d failures=%d suites=%d, want 3/1/2", doc.Tests, doc.Failures, len(doc.Suites))
+	}
+	failed := doc.Suites[1].Cases[1]
+	if failed.Name != "average_delay" || failed.Failure == nil {
+		t.Fatalf("expected average_delay failure, got %+v", failed)
+	}
+	if !strings.Contains(failed.Failure.Text, "welch p=0.9100") {
+		t.Fatalf("failure text %q lacks the significance test", failed.Failure.Text)
+	}
+}
+
+func TestMarkdownShowsBadgesAndDeltas(t *testing.T) {
+	md := Markdown("suite", outputResults()...)
+	for _, want := range []string{
+		"# ❌ FAIL suite",
+		"| rush/starved | ❌ FAIL | average_delay |",
+		"## ✅ PASS rush/actuated",
+		"| average_delay | `candidate delay <= baseline + 0.200` | 4.000 | 6.000 | +2.000 (+50.0%) | ❌ FAIL (welch p=0.9100) |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package benchmark
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+)
+
+const (
+	TestWelch     = "welch"
+	TestBootstrap = "bootstrap"
+)
+
+// SampleStats summarizes one metric over the replications of a scenario. The
+// confidence interval is the two-sided Student t interval of the mean.
+type SampleStats struct {
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	CILow  float64 `json:"ci_low"`
+	CIHigh float64 `json:"ci_high"`
+}
+
+func summarize(samples []float64, confidence float64) SampleStats {
+	stats := SampleStats{N: len(samples)}
+	if len(samples) == 0 {
+		return stats
+	}
+	stats.Mean, stats.StdDev = meanStdDev(samples)
+	stats.CILow, stats.CIHigh = stats.Mean, stats.Mean
+	if len(samples) > 1 {
+		half := studentTQuantile((1+confidence)/2, float64(len(samples)-1)) * stats.StdDev / math.Sqrt(float64(len(samples)))
+		stats.CILow -= half
+		stats.CIHigh += half
+	}
+	return stats
+}
+
+func meanStdDev(samples []float64) (float64, float64) {
+	sum := 0.0
+	for _, v := range samples {
+		sum += v
+	}
+	mean := sum / float64(len(samples))
+	if len(samples) < 2 {
+		return mean, 0
+	}
+	squares := 0.0
+	for _, v := range samples {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(samples)-1))
+}
+
+// nonInferiority tests whether mean(candidate) - scale*mean(baseline) is
+// below margin, which is the form every benchmark check takes once
+// higher-is-better metrics are negated. It returns the one-sided p-value of
+// the null hypothesis that the candidate violates the margin, and whether
+// the check passes. Without any spread in the samples the difference is
+// exact and the check falls back to a plain comparison.
+func nonInferiority(test string, candidate, baseline []float64, scale, margin, alpha float64, rng *rand.Rand, resamples int) (float64, bool) {
+	candMean, candSD := meanStdDev(candidate)
+	baseMean, baseSD := meanStdDev(baseline)
+	if candSD == 0 && baseSD == 0 {
+		if candMean <= scale*baseMean+margin {
+			return 0, true
+		}
+		return 1, false
+	}
+
+	diff := candMean - scale*baseMean
+	var p float64
+	switch test {
+	case TestBootstrap:
+		p = bootstrapPValue(candidate, baseline, scale, margin, rng, resamples)
+	default:
+		candVar := candSD * candSD / float64(len(candidate))
+		baseVar := scale * scale * baseSD * baseSD / float64(len(baseline))
+		se := math.Sqrt(candVar + baseVar)
+		df := (candVar + baseVar) * (candVar + baseVar) /
+			(welchTerm(candVar, len(candidate)) + welchTerm(baseVar, len(baseline)))
+		p = 1 - studentTCDF((margin-diff)/se, df)
+	}
+	return p, p < alpha
+}
+
+func welchTerm(variance float64, n int) float64 {
+	if n < 2 {
+		return 0
+	}
+	return variance * variance / float64(n-1)
+}
+
+func bootstrapPValue(candidate, baseline []float64, scale, margin float64, rng *rand.Rand, resamples int) float64 {
+	violations := 0
+	for i := 0; i < resamples; i++ {
+		diff := resampleMean(candidate, rng) - scale*resampleMean(baseline, rng)
+		if diff > margin {
+			violations++
+		}
+	}
+	return float64(violations) / float64(resamples)
+}
+
+func resampleMean(samples []float64, rng *rand.Rand) float64 {
+	sum := 0.0
+	for range samples {
+		sum += samples[rng.IntN(len(samples))]
+	}
+	return sum / float64(len(samples))
+}
+
+// checkRNG gives every check its own bootstrap stream so results do not
+// depend on the order checks are evaluated in.
+func checkRNG(name string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return rand.New(rand.NewPCG(1, h.Sum64()))
+}
+
+// studentTCDF is the cumulative distribution of Student's t with df degrees
+// of freedom, computed from the regularized incomplete beta function.
+func studentTCDF(t, df float64) float64 {
+	if math.IsInf(df, 1) || df <= 0 {
+		return 0.5 * math.Erfc(-t/math.Sqrt2)
+	}
+	tail := 0.5 * regularizedBeta(df/(df+t*t), df/2, 0.5)
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+func studentTQuantile(p, df float64) float64 {
+	lo, hi := -1e3, 1e3
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		if studentTCDF(mid, df) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+func regularizedBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction of the incomplete
+// beta function with the modified Lentz method.
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		tiny = 1e-300
+		eps  = 1e-14
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 300; m++ {
+		fm := float64(m)
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < eps {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,93 @@
+package benchmark
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStudentTDistribution(t *testing.T) {
+	tests := []struct {
+		t, df, want float64
+	}{
+		{t: 0, df: 5, want: 0.5},
+		{t: 2.015, df: 5, want: 0.95},
+		{t: -2.228, df: 10, want: 0.025},
+		{t: 1.96, df: math.Inf(1), want: 0.975},
+	}
+	for _, tt := range tests {
+		if got := studentTCDF(tt.t, tt.df); math.Abs(got-tt.want) > 1e-3 {
+			t.Fatalf("studentTCDF(%.3f, %.0f) = %.4f, want %.4f", tt.t, tt.df, got, tt.want)
+		}
+	}
+	if got := studentTQuantile(0.975, 9); math.Abs(got-2.262) > 1e-3 {
+		t.Fatalf("studentTQuantile(0.975, 9) = %.4f, want 2.262", got)
+	}
+}
+
+func TestSummarizeReportsConfidenceInterval(t *testing.T) {
+	stats := summarize([]float64{2, 4, 4, 4, 5, 5, 7, 9}, 0.95)
+	if stats.N != 8 || stats.Mean != 5 {
+		t.Fatalf("n=%d mean=%.3f, want n=8 mean=5", stats.N, stats.Mean)
+	}
+	if math.Abs(stats.StdDev-2.138) > 1e-3 {
+		t.Fatalf("stddev = %.4f, want 2.138", stats.StdDev)
+	}
+	// t(0.975, 7) = 2.365
+	half := 2.365 * stats.StdDev / math.Sqrt(8)
+	if math.Abs(stats.CILow-(5-half)) > 1e-2 || math.Abs(stats.CIHigh-(5+half)) > 1e-2 {
+		t.Fatalf("ci = [%.3f, %.3f], want 5 ± %.3f", stats.CILow, stats.CIHigh, half)
+	}
+}
+
+func TestNonInferiorityRequiresSignificance(t *testing.T) {
+	baseline := []float64{10, 11, 9, 10, 12, 8, 10, 11, 9, 10}
+	clearlyBetter := []float64{6, 7, 5, 6, 8, 4, 6, 7, 5, 6}
+	slightlyWorse := []float64{10.2, 11.1, 9.3, 10.1, 12.2, 8.1, 10.3, 11.1, 9.2, 10.2}
+
+	for _, test := range []string{TestWelch, TestBootstrap} {
+		t.Run(test, func(t *testing.T) {
+			p, ok := nonInferiority(test, clearlyBetter, baseline, 1, 0.2, 0.05, checkRNG("delay"), 2000)
+			if !ok || p >= 0.05 {
+				t.Fatalf("clearly better candidate: p=%.4f passed=%v, want significant pass", p, ok)
+			}
+			p, ok = nonInferiority(test, slightlyWorse, baseline, 1, 0.2, 0.05, checkRNG("delay"), 2000)
+			if ok {
+				t.Fatalf("candidate within noise of the margin passed with p=%.4f", p)
+			}
+		})
+	}
+}
+
+func TestEvaluateReplicatedScorecards(t *testing.T) {
+	spec := Spec{
+		Name:         "replicated",
+		Replications: 3,
+		Significance: Significance{Test: TestWelch, Alpha: 0.05},
+		Thresholds:   Thresholds{MinThroughputRatio: 0.95, MaxDelayIncrease: 0.5},
+	}
+	runs := func(delays ...float64) Scorecard {
+		scores := make([]Scorecard, len(delays))
+		for i, delay := range delays {
+			scores[i] = Scorecard{ThroughputPer100: 50, AverageDelay: delay, MinTTCSteps: 1}
+		}
+		return aggregate(scores, 0.95)
+	}
+
+	result := evaluate(spec, runs(5, 6, 7), runs(5.1, 6.3, 6.9))
+	if result.Passed {
+		t.Fatalf("expected the noisy delay check to fail without significance")
+	}
+	for _, check := range result.Checks {
+		if check.Test != TestWelch {
+			t.Fatalf("check %s test = %q, want welch", check.Name, check.Test)
+		}
+		if check.Name == "throughput" && !check.Passed {
+			t.Fatalf("identical throughput should pass")
+		}
+	}
+
+	result = evaluate(spec, runs(5, 6, 7), runs(2, 2.5, 3))
+	if !result.Passed {
+		t.Fatalf("expected a clearly lower delay to pass: %+v", result.Checks)
+	}
+}
@@ -0,0 +1,239 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Suite runs several scenarios, each comparing one baseline against one or
+// more candidates. Replications, Significance and Thresholds apply to every
+// comparison unless a scenario overrides the thresholds.
+type Suite struct {
+	Name         string          `json:"name"`
+	Scenarios    []SuiteScenario `json:"scenarios"`
+	Replications int             `json:"replications"`
+	Significance Significance    `json:"significance"`
+	Thresholds   Thresholds      `json:"thresholds"`
+	ReportPath   string          `json:"report_path"`
+	JUnitPath    string          `json:"junit_path"`
+	MarkdownPath string          `json:"markdown_path"`
+}
+
+type SuiteScenario struct {
+	Name              string           `json:"name"`
+	BaselineConfig    string           `json:"baseline_config"`
+	BaselineScorecard string           `json:"baseline_scorecard"`
+	Candidates        []SuiteCandidate `json:"candidates"`
+	Thresholds        *Thresholds      `json:"thresholds,omitempty"`
+}
+
+type SuiteCandidate struct {
+	Name   string `json:"name"`
+	Config string `json:"config"`
+}
+
+type SuiteEntry struct {
+	Scenario  string `json:"scenario"`
+	Candidate string `json:"candidate"`
+	Result    Result `json:"result"`
+}
+
+type SuiteResult struct {
+	Name      string       `json:"name"`
+	Generated time.Time    `json:"generated"`
+	Checks    []string     `json:"checks"`
+	Entries   []SuiteEntry `json:"entries"`
+	Passed    bool         `json:"passed"`
+}
+
+// Failures lists every scenario/candidate/check combination that failed.
+func (r SuiteResult) Failures() []string {
+	var failures []string
+	for _, entry := range r.Entries {
+		for _, check := range entry.Result.Checks {
+			if !check.Passed {
+				failures = append(failures, fmt.Sprintf("%s/%s/%s", entry.Scenario, entry.Candidate, check.Name))
+			}
+		}
+	}
+	return failures
+}
+
+func LoadSuite(path string) (Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Suite{}, fmt.Errorf("read benchmark suite: %w", err)
+	}
+
+	var suite Suite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return Suite{}, fmt.Errorf("parse benchmark suite: %w", err)
+	}
+
+	if suite.Name == "" {
+		suite.Name = "benchmark-suite"
+	}
+	resolveSuitePaths(&suite, filepath.Dir(path))
+	if err := validateSuite(suite); err != nil {
+		return Suite{}, err
+	}
+	return suite, nil
+}
+
+func resolveSuitePaths(suite *Suite, baseDir string) {
+	if baseDir == "" {
+		return
+	}
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(baseDir, path)
+	}
+	suite.ReportPath = resolve(suite.ReportPath)
+	suite.JUnitPath = resolve(suite.JUnitPath)
+	suite.MarkdownPath = resolve(suite.MarkdownPath)
+	for i := range suite.Scenarios {
+		scenario := &suite.Scenarios[i]
+		scenario.BaselineConfig = resolve(scenario.BaselineConfig)
+		scenario.BaselineScorecard = resolve(scenario.BaselineScorecard)
+		for j := range scenario.Candidates {
+			scenario.Candidates[j].Config = resolve(scenario.Candidates[j].Config)
+		}
+	}
+}
+
+func validateSuite(suite Suite) error {
+	if len(suite.Scenarios) == 0 {
+		return fmt.Errorf("suite needs at least one scenario")
+	}
+	scenarios := map[string]bool{}
+	for _, scenario := range suite.Scenarios {
+		if scenario.Name == "" {
+			return fmt.Errorf("suite scenarios require a name")
+		}
+		if scenarios[scenario.Name] {
+			return fmt.Errorf("duplicate suite scenario %q", scenario.Name)
+		}
+		scenarios[scenario.Name] = true
+		if len(scenario.Candidates) == 0 {
+			return fmt.Errorf("scenario %q needs at least one candidate", scenario.Name)
+		}
+		candidates := map[string]bool{}
+		for _, candidate := range scenario.Candidates {
+			if candidate.Name == "" {
+				return fmt.Errorf("scenario %q: candidates require a name", scenario.Name)
+			}
+			if candidates[candidate.Name] {
+				return fmt.Errorf("scenario %q: duplicate candidate %q", scenario.Name, candidate.Name)
+			}
+			candidates[candidate.Name] = true
+		}
+		for _, spec := range suite.specs(scenario) {
+			if err := validateSpec(spec); err != nil {
+				return fmt.Errorf("scenario %q: %w", scenario.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// specs expands a scenario into one defaulted Spec per candidate.
+func (s Suite) specs(scenario SuiteScenario) []Spec {
+	thresholds := s.Thresholds
+	if scenario.Thresholds != nil {
+		thresholds = *scenario.Thresholds
+	}
+	specs := make([]Spec, 0, len(scenario.Candidates))
+	for _, candidate := range scenario.Candidates {
+		spec := Spec{
+			Name:              fmt.Sprintf("%s/%s", scenario.Name, candidate.Name),
+			BaselineConfig:    scenario.BaselineConfig,
+			BaselineScorecard: scenario.BaselineScorecard,
+			CandidateConfig:   candidate.Config,
+			Replications:      s.Replications,
+			Significance:      s.Significance,
+			Thresholds:        thresholds,
+		}
+		applySpecDefaults(&spec)
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// GoldenSpecs returns one spec per scenario that pins a golden baseline
+// scorecard, for updating or verifying those files.
+func (s Suite) GoldenSpecs() []Spec {
+	var specs []Spec
+	for _, scenario := range s.Scenarios {
+		if scenario.BaselineScorecard == "" {
+			continue
+		}
+		spec := s.specs(scenario)[0]
+		spec.Name = scenario.Name
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// RunSuite runs every scenario of a suite. Each baseline is simulated once
+// and compared against all of its candidates.
+func RunSuite(suite Suite) (SuiteResult, error) {
+	result := SuiteResult{
+		Name:      suite.Name,
+		Generated: time.Now().UTC(),
+		Passed:    true,
+	}
+	for _, scenario := range suite.Scenarios {
+		specs := suite.specs(scenario)
+		baseScore, source, err := baselineScorecard(specs[0])
+		if err != nil {
+			return SuiteResult{}, fmt.Errorf("scenario %q: %w", scenario.Name, err)
+		}
+		for i, spec := range specs {
+			candidate := scenario.Candidates[i]
+			candScore, err := runReplications(spec, candidate.Config)
+			if err != nil {
+				return SuiteResult{}, fmt.Errorf("scenario %q: run candidate %q: %w", scenario.Name, candidate.Name, err)
+			}
+			entry := SuiteEntry{
+				Scenario:  scenario.Name,
+				Candidate: candidate.Name,
+				Result:    evaluate(spec, baseScore, candScore),
+			}
+			entry.Result.BaselineSource = source
+			result.Entries = append(result.Entries, entry)
+			if !entry.Result.Passed {
+				result.Passed = false
+			}
+		}
+	}
+	// Scenarios with their own thresholds may add checks, so the columns are
+	// every check name in order of first appearance.
+	seen := map[string]bool{}
+	for _, entry := range result.Entries {
+		for _, check := range entry.Result.Checks {
+			if !seen[check.Name] {
+				seen[check.Name] = true
+				result.Checks = append(result.Checks, check.Name)
+			}
+		}
+	}
+
+	if suite.ReportPath != "" {
+		if err := writeResult(suite.ReportPath, result); err != nil {
+			return SuiteResult{}, err
+		}
+	}
+	results := make([]Result, len(result.Entries))
+	for i, entry := range result.Entries {
+		results[i] = entry.Result
+	}
+	if err := writeOutputs(suite.Name, suite.JUnitPath, suite.MarkdownPath, results...); err != nil {
+		return SuiteResult{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package benchmark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSuiteScenario(t *testing.T, dir, name string, verticalGreen, horizontalGreen int) {
+	t.Helper()
+	content := fmt.Sprintf(`{
+		"name": %q,
+		"steps": 120,
+		"grid": { "width": 20, "height": 10 },
+		"signal": { "vertical_green_steps": %d, "horizontal_green_steps": %d },
+		"spawn": {
+			"lanes": {
+				"up": { "entry_x": 10, "entry_y": 9, "step_interval": 3 },
+				"right": { "entry_x": 0, "entry_y": 5, "step_interval": 4 }
+			}
+		}
+	}`, name, verticalGreen, horizontalGreen)
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func TestRunSuiteReportsFailingCombinations(t *testing.T) {
+	dir := t.TempDir()
+	writeSuiteScenario(t, dir, "baseline", 5, 5)
+	writeSuiteScenario(t, dir, "same", 5, 5)
+	writeSuiteScenario(t, dir, "starved", 2, 20)
+
+	suitePath := filepath.Join(dir, "suite.json")
+	content := `{
+		"name": "suite-test",
+		"thresholds": { "min_throughput_ratio": 0.95, "max_delay_increase": 0.2 },
+		"scenarios": [
+			{
+				"name": "steady",
+				"baseline_config": "baseline.json",
+				"candidates": [
+					{ "name": "same", "config": "same.json" },
+					{ "name": "starved", "config": "starved.json" }
+				]
+			}
+		]
+	}`
+	if err := os.WriteFile(suitePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write suite: %v", err)
+	}
+
+	suite, err := LoadSuite(suitePath)
+	if err != nil {
+		t.Fatalf("load suite: %v", err)
+	}
+	if got := suite.Scenarios[0].Candidates[1].Config; got != filepath.Join(dir, "starved.json") {
+		t.Fatalf("candidate config = %q, want resolved path", got)
+	}
+
+	result, err := RunSuite(suite)
+	if err != nil {
+		t.Fatalf("run suite: %v", err)
+	}
+	if result.Passed {
+		t.Fatalf("expected suite to fail")
+	}
+	if len(result.Entries) != 2 || !result.Entries[0].Result.Passed {
+		t.Fatalf("entries = %+v, want the identical candidate to pass", result.Entries)
+	}
+	failures := result.Failures()
+	if len(failures) == 0 {
+		t.Fatalf("expected failures for the starved candidate")
+	}
+	for _, failure := range failures {
+		if !strings.HasPrefix(failure, "steady/starved/") {
+			t.Fatalf("unexpected failure %q", failure)
+		}
+	}
+}
+
+func TestLoadSuiteRejectsDuplicateCandidates(t *testing.T) {
+	dir := t.TempDir()
+	suitePath := filepath.Join(dir, "suite.json")
+	content := `{
+		"scenarios": [
+			{
+				"name": "steady",
+				"baseline_config": "baseline.json",
+				"candidates": [
+					{ "name": "a", "config": "a.json" },
+					{ "name": "a", "config": "b.json" }
+				]
+			}
+		]
+	}`
+	if err := os.WriteFile(suitePath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write suite: %v", err)
+	}
+	if _, err := LoadSuite(suitePath); err == nil || !strings.Contains(err.Error(), "duplicate candidate") {
+		t.Fatalf("expected duplicate candidate error, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package htmlreport
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
+)
+
+// series is one metric sampled at every step of a timeline.
+type series struct {
+	Title  string
+	Unit   string
+	Steps  []int
+	Values []float64
+}
+
+// timelineSeries derives the charted metrics from the snapshots of a run:
+// vehicles queued (blocked during the step), cumulative throughput and the
+// mean wait of the vehicles on the grid.
+func timelineSeries(cfg sim.Config, timeline []sim.StepSnapshot) []series {
+	stats := sim.TimelineStats(cfg, timeline)
+	queue := series{Title: "Queued vehicles", Unit: "vehicles"}
+	throughput := series{Title: "Throughput", Unit: "per 100 steps"}
+	delay := series{Title: "Mean wait of active vehicles", Unit: "steps"}
+	for i, snap := range timeline {
+		queued, wait := 0, 0
+		for _, v := range snap.Vehicles {
+			if v.BlockedStep == snap.Step {
+				queued++
+			}
+			wait += v.WaitSteps
+		}
+		mean := 0.0
+		if len(snap.Vehicles) > 0 {
+			mean = float64(wait) / float64(len(snap.Vehicles))
+		}
+		for _, s := range []*series{&queue, &throughput, &delay} {
+			s.Steps = append(s.Steps, snap.Step)
+		}
+		queue.Values = append(queue.Values, float64(queued))
+		throughput.Values = append(throughput.Values, stats[i].ThroughputPer100Step)
+		delay.Values = append(delay.Values, mean)
+	}
+	return []series{queue, throughput, delay}
+}
+
+// reportSeries derives the charted metrics from the sampled series of a
+// report: the summed lane queues, the vehicles on the grid and the
+// throughput of each window.
+func reportSeries(samples []sim.SeriesSample) []series {
+	queue := series{Title: "Queued vehicles, lane peaks summed", Unit: "vehicles"}
+	active := series{Title: "Active vehicles", Unit: "vehicles"}
+	throughput := series{Title: "Throughput per window", Unit: "per 100 steps"}
+	for _, sample := range samples {
+		queued := 0
+		for _, n := range sample.LaneQueues {
+			queued += n
+		}
+		for _, s := range []*series{&queue, &active, &throughput} {
+			s.Steps = append(s.Steps, sample.Step)
+		}
+		queue.Values = append(queue.Values, float64(queued))
+		active.Values = append(active.Values, float64(sample.ActiveVehicles))
+		throughput.Values = append(throughput.Values, float64(sample.Completed)/float64(sample.Steps)*100)
+	}
+	return []series{queue, active, throughput}
+}
+
+const (
+	chartWidth   = 640
+	chartHeight  = 200
+	chartLeft    = 48
+	chartRight   = 12
+	chartTop     = 12
+	chartBottom  = 28
+	chartGridRow = 4
+)
+
+// lineChart draws a series as a captioned inline SVG line chart with a
+// labelled value axis and the first and last step on the step axis.
+func lineChart(s series) template.HTML {
+	plotW := float64(chartWidth - chartLeft - chartRight)
+	plotH := float64(chartHeight - chartTop - chartBottom)
+	top := 0.0
+	for _, v := range s.Values {
+		top = math.Max(top, v)
+	}
+	top = niceCeil(top)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<figure><figcaption>%s (%s)</figcaption>`, html.EscapeString(s.Title), html.EscapeString(s.Unit))
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="%s">`,
+		chartWidth, chartHeight, html.EscapeString(s.Title))
+	for row := 0; row <= chartGridRow; row++ {
+		value := top * float64(row) / chartGridRow
+		y := float64(chartTop) + plotH - plotH*float64(row)/chartGridRow
+		fmt.Fprintf(&b, `<line class="grid" x1="%d" y1="%.1f" x2="%d" y2="%.1f"/>`,
+			chartLeft, y, chartWidth-chartRight, y)
+		fmt.Fprintf(&b, `<text class="axis" x="%d" y="%.1f" text-anchor="end">%s</text>`,
+			chartLeft-6, y+4, formatValue(value))
+	}
+
+	if len(s.Steps) > 0 {
+		first, last := s.Steps[0], s.Steps[len(s.Steps)-1]
+		span := float64(max(1, last-first))
+		points := make([]string, len(s.Steps))
+		for i, step := range s.Steps {
+			x := float64(chartLeft) + plotW*float64(step-first)/span
+			y := float64(chartTop) + plotH
+			if top > 0 {
+				y -= plotH * s.Values[i] / top
+			}
+			points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+		}
+		fmt.Fprintf(&b, `<polyline class="line" points="%s"/>`, strings.Join(points, " "))
+		base := chartHeight - chartBottom + 18
+		fmt.Fprintf(&b, `<text class="axis" x="%d" y="%d">step %d</text>`, chartLeft, base, first)
+		fmt.Fprintf(&b, `<text class="axis" x="%d" y="%d" text-anchor="end">step %d</text>`,
+			chartWidth-chartRight, base, last)
+	}
+	b.WriteString(`</svg></figure>`)
+	return template.HTML(b.String())
+}
+
+// niceCeil rounds v up to 1, 2 or 5 times a power of ten so axis labels stay
+// short.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if v <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+func formatValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2g", v)
+}
@@ -0,0 +1,275 @@
+// Package htmlreport renders a simulation report, optionally together with
+// a benchmark result, as one self-contained HTML page. Charts are inline SVG
+// and the timeline playback is drawn by an inline script, so the page needs
+// no network access to view.
+package htmlreport
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/benchmark"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
+)
+
+//go:embed report.html
+var pageSource string
+
+var pageTemplate = template.Must(template.New("report").Parse(pageSource))
+
+type page struct {
+	Title         string
+	Report        sim.Report
+	Summary       []summaryRow
+	Directions    []directionRow
+	RoadLanes     []roadLaneRow
+	Classes       []classRow
+	Histogram     []histogramRow
+	Intersections []intersectionRow
+	Charts        []template.HTML
+	Benchmark     *benchmark.Result
+	Playback      *playback
+}
+
+type summaryRow struct {
+	Label string
+	Value string
+}
+
+type directionRow struct {
+	Name  string
+	Stats sim.DirStats
+	// Movement rows are only listed for lanes that turn.
+	Movements []movementRow
+}
+
+type movementRow struct {
+	Name  sim.Movement
+	Stats sim.MovementStats
+}
+
+type roadLaneRow struct {
+	Direction sim.Direction
+	Stats     sim.RoadLaneStats
+}
+
+type classRow struct {
+	Name  string
+	Stats sim.ClassStats
+}
+
+// histogramRow is one bucket of the wait and trip histograms, which share
+// their bucket edges.
+type histogramRow struct {
+	Range string
+	Wait  int
+	Trip  int
+}
+
+type intersectionRow struct {
+	ID    string
+	Stats sim.IntersectionStats
+}
+
+// playback is the timeline data the inline script draws. Vehicles are
+// [x, y, heading, wait, lane, lanes] with heading indexing playbackHeadings
+// and lanes the number of lanes of the road at the vehicle's cell. Long
+// vehicles have one entry per cell they occupy, so Active counts them.
+type playback struct {
+	Width         int                      `json:"width"`
+	Height        int                      `json:"height"`
+	Roads         []sim.RoadConfig         `json:"roads"`
+	Intersections []sim.IntersectionConfig `json:"intersections"`
+	Frames        []playbackFrame          `json:"frames"`
+}
+
+type playbackFrame struct {
+	Step      int               `json:"step"`
+	Lights    []sim.SignalPhase `json:"lights"`
+	Vehicles  [][6]int          `json:"vehicles"`
+	Active    int               `json:"active"`
+	Completed int               `json:"completed"`
+}
+
+var playbackHeadings = []sim.Direction{sim.Up, sim.Down, sim.Left, sim.Right}
+
+// Render builds the page for report. Charts come from the report's series,
+// or else its timeline; playback needs the timeline and the config it was
+// run with. Without either the page only has the summary tables. result may
+// be nil.
+func Render(report sim.Report, result *benchmark.Result) ([]byte, error) {
+	m := report.Metrics
+	p := page{
+		Title:     m.ScenarioName,
+		Report:    report,
+		Summary:   summary(m),
+		Benchmark: result,
+	}
+	if p.Title == "" {
+		p.Title = report.ConfigName
+	}
+
+	for _, dir := range sortedKeys(m.DirectionStats) {
+		s := m.DirectionStats[dir]
+		row := directionRow{Name: string(dir), Stats: s}
+		if _, onlyThrough := s.Movements[sim.Through]; !onlyThrough || len(s.Movements) > 1 {
+			for _, movement := range []sim.Movement{sim.Through, sim.TurnRight, sim.TurnLeft} {
+				if ms, ok := s.Movements[movement]; ok {
+					row.Movements = append(row.Movements, movementRow{Name: movement, Stats: ms})
+				}
+			}
+		}
+		p.Directions = append(p.Directions, row)
+		for _, lane := range s.RoadLanes {
+			p.RoadLanes = append(p.RoadLanes, roadLaneRow{Direction: dir, Stats: lane})
+		}
+	}
+	// Classes are only listed when the run mixed several.
+	if len(m.ClassStats) > 1 {
+		for _, name := range sortedKeys(m.ClassStats) {
+			p.Classes = append(p.Classes, classRow{Name: name, Stats: m.ClassStats[name]})
+		}
+	}
+	for i, bucket := range m.WaitDistribution.Histogram {
+		row := histogramRow{Range: fmt.Sprintf("%d–%d", bucket.From, bucket.To-1), Wait: bucket.Count}
+		if bucket.To == 0 {
+			row.Range = fmt.Sprintf("%d+", bucket.From)
+		} else if bucket.To == bucket.From+1 {
+			row.Range = fmt.Sprint(bucket.From)
+		}
+		if i < len(m.TripDistribution.Histogram) {
+			row.Trip = m.TripDistribution.Histogram[i].Count
+		}
+		p.Histogram = append(p.Histogram, row)
+	}
+	if len(m.IntersectionStats) > 1 {
+		for _, id := range sortedKeys(m.IntersectionStats) {
+			p.Intersections = append(p.Intersections, intersectionRow{ID: id, Stats: m.IntersectionStats[id]})
+		}
+	}
+
+	var charts []series
+	if len(report.Timeline) > 0 && report.Config != nil {
+		charts = timelineSeries(*report.Config, report.Timeline)
+		p.Playback = newPlayback(*report.Config, report.Timeline)
+	}
+	// The sampled series is preferred: it covers lane queues at the entries
+	// and exists without a timeline.
+	if len(report.Series) > 0 {
+		charts = reportSeries(report.Series)
+	}
+	for _, s := range charts {
+		p.Charts = append(p.Charts, lineChart(s))
+	}
+
+	var b strings.Builder
+	if err := pageTemplate.Execute(&b, p); err != nil {
+		return nil, fmt.Errorf("render html report: %w", err)
+	}
+	return []byte(b.String()), nil
+}
+
+// Write renders the page for report and result and writes it to path.
+func Write(path string, report sim.Report, result *benchmark.Result) error {
+	data, err := Render(report, result)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create html report directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write html report: %w", err)
+	}
+	return nil
+}
+
+func summary(m sim.Metrics) []summaryRow {
+	rows := []summaryRow{
+		{"Steps", fmt.Sprint(m.Steps)},
+		{"Spawned", fmt.Sprint(m.VehiclesSpawned)},
+		{"Completed", fmt.Sprint(m.VehiclesCompleted)},
+		{"Active at end", fmt.Sprint(m.ActiveVehicles)},
+		{"Throughput / 100 steps", fmt.Sprintf("%.2f", m.ThroughputPer100Step)},
+		{"Average wait per trip", fmt.Sprintf("%.2f", m.AverageWaitPerTrip)},
+		{"Average trip duration", fmt.Sprintf("%.2f", m.AverageTripDuration)},
+		{"Wait p50 / p90 / p95 / p99 / max", percentiles(m.WaitDistribution)},
+		{"Trip p50 / p90 / p95 / p99 / max", percentiles(m.TripDistribution)},
+		{"Average network speed", fmt.Sprintf("%.3f", m.AverageNetworkSpeed)},
+		{"Max queue", fmt.Sprint(m.MaxQueueOverall)},
+		{"Blocked by signal", fmt.Sprint(m.BlockedBySignal)},
+		{"Blocked by traffic", fmt.Sprint(m.BlockedByTraffic)},
+		{"Potential collisions", fmt.Sprint(m.PotentialCollisions)},
+		{"Yellow entries", fmt.Sprint(m.YellowEntries)},
+		{"Clearance conflicts", fmt.Sprint(m.ClearanceConflicts)},
+		{"Phase switches", fmt.Sprint(m.PhaseSwitches)},
+		{"Lost time steps", fmt.Sprint(m.LostTimeSteps)},
+		{"Lane changes (mandatory)", fmt.Sprintf("%d (%d)", m.LaneChanges, m.MandatoryLaneChanges)},
+	}
+	if p := m.Pedestrians; p != nil {
+		rows = append(rows,
+			summaryRow{"Pedestrians crossed / arrived", fmt.Sprintf("%d / %d", p.Crossed, p.Arrived)},
+			summaryRow{"Average pedestrian wait", fmt.Sprintf("%.2f", p.AverageWait)},
+			summaryRow{"Pedestrian wait p50 / p90 / p95 / p99 / max", percentiles(p.WaitDistribution)},
+			summaryRow{"Blocked by pedestrians", fmt.Sprint(p.BlockedVehicles)},
+		)
+	}
+	return rows
+}
+
+func percentiles(d sim.Distribution) string {
+	return fmt.Sprintf("%d / %d / %d / %d / %d", d.P50, d.P90, d.P95, d.P99, d.Max)
+}
+
+func newPlayback(cfg sim.Config, timeline []sim.StepSnapshot) *playback {
+	network := cfg.ResolvedNetwork()
+	stats := sim.TimelineStats(cfg, timeline)
+	headings := make(map[sim.Direction]int, len(playbackHeadings))
+	for i, d := range playbackHeadings {
+		headings[d] = i
+	}
+
+	p := &playback{
+		Width:         cfg.Grid.Width,
+		Height:        cfg.Grid.Height,
+		Roads:         network.Roads,
+		Intersections: network.Intersections,
+		Frames:        make([]playbackFrame, len(timeline)),
+	}
+	for i, snap := range timeline {
+		frame := playbackFrame{
+			Step:      snap.Step,
+			Vehicles:  make([][6]int, 0, len(snap.Vehicles)),
+			Active:    len(snap.Vehicles),
+			Completed: stats[i].CompletedVehicles,
+		}
+		for _, light := range snap.Lights {
+			frame.Lights = append(frame.Lights, light.Phase)
+		}
+		if len(frame.Lights) == 0 {
+			frame.Lights = []sim.SignalPhase{snap.Phase}
+		}
+		for _, v := range snap.Vehicles {
+			for _, s := range v.Segments() {
+				lanes := max(network.Lanes(s.X, s.Y, s.Heading), 1)
+				frame.Vehicles = append(frame.Vehicles, [6]int{s.X, s.Y, headings[s.Heading], v.WaitSteps, s.RoadLane, lanes})
+			}
+		}
+		p.Frames[i] = frame
+	}
+	return p
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
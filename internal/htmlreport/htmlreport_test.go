@@ -0,0 +1,127 @@
+package htmlreport
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/internal/benchmark"
+	"github.com/Vedant-Mhatre/TrafficFlowSimulator/sim"
+)
+
+func timelineReport(t *testing.T) sim.Report {
+	t.Helper()
+	cfg, err := sim.LoadConfig("../../configs/turning.json")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.Steps = 40
+	engine, err := sim.NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	render := false
+	return engine.Run(true, &render)
+}
+
+func TestRenderIsSelfContained(t *testing.T) {
+	result := &benchmark.Result{
+		Name: "turning/actuated",
+		Checks: []benchmark.CheckResult{
+			{Name: "average_delay", Rule: "candidate delay <= baseline + 0.200", Baseline: 4, Candidate: 6},
+		},
+	}
+	data, err := Render(timelineReport(t), result)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	page := string(data)
+	for _, want := range []string{
+		"<h2>Benchmark turning/actuated",
+		"(&#43;50.0%)",
+		"<h2>Directions</h2>",
+		`<tr class="movement"><td>left</td>`,
+		`<svg class="chart"`,
+		`const data = {"width":`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("page missing %q", want)
+		}
+	}
+	if got := strings.Count(page, `<svg class="chart"`); got != 3 {
+		t.Fatalf("got %d charts, want 3", got)
+	}
+	for _, external := range []string{"http://", "https://", "src="} {
+		if strings.Contains(page, external) {
+			t.Fatalf("page references external resource %q", external)
+		}
+	}
+}
+
+func TestRenderWithoutTimelineSkipsChartsAndPlayback(t *testing.T) {
+	report := timelineReport(t)
+	report.Timeline, report.Config = nil, nil
+	data, err := Render(report, nil)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	page := string(data)
+	if strings.Contains(page, "<canvas") || strings.Contains(page, `<svg class="chart"`) {
+		t.Fatalf("page without timeline should not have charts or playback")
+	}
+	if strings.Contains(page, "Benchmark") {
+		t.Fatalf("page without result should not have a benchmark section")
+	}
+}
+
+func TestTimelineSeriesCountsQueuedVehicles(t *testing.T) {
+	timeline := []sim.StepSnapshot{
+		{Step: 1, Vehicles: []sim.Vehicle{{ID: 1, BlockedStep: 1, WaitSteps: 1}, {ID: 2, WaitSteps: 0}}},
+		{Step: 2, Vehicles: []sim.Vehicle{{ID: 1, BlockedStep: 2, WaitSteps: 2}, {ID: 2, BlockedStep: 2, WaitSteps: 1}}},
+	}
+	got := timelineSeries(sim.Config{Steps: 2}, timeline)
+	queue, delay := got[0], got[2]
+	if queue.Values[0] != 1 || queue.Values[1] != 2 {
+		t.Fatalf("queue = %v, want [1 2]", queue.Values)
+	}
+	if delay.Values[0] != 0.5 || delay.Values[1] != 1.5 {
+		t.Fatalf("delay = %v, want [0.5 1.5]", delay.Values)
+	}
+}
+
+func TestRenderChartsSeriesWithoutTimeline(t *testing.T) {
+	report := sim.Report{
+		ConfigName: "series-only",
+		Series: []sim.SeriesSample{
+			{Step: 10, Steps: 10, ActiveVehicles: 4, Completed: 2, LaneQueues: map[string]int{"up": 3, "right": 1}},
+			{Step: 20, Steps: 10, ActiveVehicles: 2, Completed: 5, LaneQueues: map[string]int{"up": 0, "right": 2}},
+		},
+	}
+	data, err := Render(report, nil)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	page := string(data)
+	if got := strings.Count(page, `<svg class="chart"`); got != 3 {
+		t.Fatalf("got %d charts, want 3", got)
+	}
+	if strings.Contains(page, "<canvas") {
+		t.Fatalf("page without timeline should not have playback")
+	}
+}
+
+func TestPlaybackDrawsEveryCellOfLongVehicles(t *testing.T) {
+	truck := sim.Vehicle{ID: 1, X: 4, Y: 2, Direction: sim.Right, Class: "truck", Tail: []sim.Segment{
+		{X: 3, Y: 2, Heading: sim.Right},
+		{X: 2, Y: 2, Heading: sim.Right},
+	}}
+	cfg := sim.Config{Steps: 1, Grid: sim.GridConfig{Width: 9, Height: 5}}
+	p := newPlayback(cfg, []sim.StepSnapshot{{Step: 1, Vehicles: []sim.Vehicle{truck}}})
+
+	frame := p.Frames[0]
+	if frame.Active != 1 || len(frame.Vehicles) != 3 {
+		t.Fatalf("frame has %d active and %d cells, want 1 and 3", frame.Active, len(frame.Vehicles))
+	}
+	if frame.Vehicles[2][0] != 2 || frame.Vehicles[2][1] != 2 {
+		t.Fatalf("last cell = %v, want the truck's rear at (2,2)", frame.Vehicles[2])
+	}
+}
@@ -0,0 +1,143 @@
+// Package term reads single key presses from the terminal for the
+// interactive dashboard and the replay viewer.
+package term
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Key is a decoded key press: a single printable character, or one of the
+// named keys below.
+type Key string
+
+const (
+	KeyUp        Key = "up"
+	KeyDown      Key = "down"
+	KeyLeft      Key = "left"
+	KeyRight     Key = "right"
+	KeyHome      Key = "home"
+	KeyEnd       Key = "end"
+	KeyEnter     Key = "enter"
+	KeyBackspace Key = "backspace"
+	KeyEscape    Key = "esc"
+	KeyInterrupt Key = "ctrl-c"
+)
+
+// Raw switches the terminal on stdin to unbuffered input without echo and
+// returns a function that restores the previous settings. It fails when
+// stdin is not a terminal; keys then arrive line by line after Enter.
+func Raw() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("terminal raw mode: %w", err)
+	}
+	if _, err := stty("-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return nil, fmt.Errorf("terminal raw mode: %w", err)
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Keys decodes key presses from r until it fails or reaches EOF, then closes
+// the returned channel.
+func Keys(r io.Reader) <-chan Key {
+	keys := make(chan Key)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 32)
+		for {
+			n, err := r.Read(buf)
+			for _, key := range Decode(buf[:n]) {
+				keys <- key
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return keys
+}
+
+// Decode splits the bytes of one read into key presses. Escape sequences for
+// arrow, Home and End keys arrive in a single read on every common terminal;
+// other sequences, such as Page Up, are dropped whole so that only a bare ESC
+// reads as KeyEscape.
+func Decode(data []byte) []Key {
+	var keys []Key
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b == 0x1b && i+1 < len(data) && (data[i+1] == '[' || data[i+1] == 'O'):
+			key, size := decodeEscape(data[i+2:])
+			if key == "" {
+				size = escapeLength(data[i+2:])
+			} else {
+				keys = append(keys, key)
+			}
+			i += 1 + size
+		case b == 0x1b:
+			keys = append(keys, KeyEscape)
+		case b == '\r' || b == '\n':
+			keys = append(keys, KeyEnter)
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, KeyBackspace)
+		case b == 0x03:
+			keys = append(keys, KeyInterrupt)
+		case b >= 0x20 && b < 0x7f:
+			keys = append(keys, Key(string(rune(b))))
+		}
+	}
+	return keys
+}
+
+// decodeEscape maps the part of an escape sequence after "ESC [" or "ESC O"
+// to a key and the number of bytes it used.
+func decodeEscape(seq []byte) (Key, int) {
+	if len(seq) == 0 {
+		return "", 0
+	}
+	switch seq[0] {
+	case 'A':
+		return KeyUp, 1
+	case 'B':
+		return KeyDown, 1
+	case 'C':
+		return KeyRight, 1
+	case 'D':
+		return KeyLeft, 1
+	case 'H':
+		return KeyHome, 1
+	case 'F':
+		return KeyEnd, 1
+	}
+	if len(seq) >= 2 && seq[1] == '~' {
+		switch seq[0] {
+		case '1', '7':
+			return KeyHome, 2
+		case '4', '8':
+			return KeyEnd, 2
+		}
+	}
+	return "", 0
+}
+
+// escapeLength returns the number of bytes up to and including the final byte
+// (0x40-0x7e) of an escape sequence, or all of seq when it was cut off.
+func escapeLength(seq []byte) int {
+	for i, b := range seq {
+		if b >= 0x40 && b <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(seq)
+}
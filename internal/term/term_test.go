@@ -0,0 +1,40 @@
+package term
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Key
+	}{
+		{name: "characters", in: "a +", want: []Key{"a", " ", "+"}},
+		{name: "arrows", in: "\x1b[C\x1b[D\x1b[A\x1b[B", want: []Key{KeyRight, KeyLeft, KeyUp, KeyDown}},
+		{name: "home and end", in: "\x1b[H\x1b[4~\x1bOF", want: []Key{KeyHome, KeyEnd, KeyEnd}},
+		{name: "page up", in: "\x1b[5~", want: nil},
+		{name: "unknown sequences", in: "a\x1b[1;5C\x1bOPb", want: []Key{"a", "b"}},
+		{name: "lone escape", in: "\x1bq", want: []Key{KeyEscape, "q"}},
+		{name: "control keys", in: "12\r\x7f\x03", want: []Key{"1", "2", KeyEnter, KeyBackspace, KeyInterrupt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Decode([]byte(tt.in)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Decode(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeysClosesAtEOF(t *testing.T) {
+	var got []Key
+	for key := range Keys(strings.NewReader("n\x1b[C")) {
+		got = append(got, key)
+	}
+	if want := []Key{"n", KeyRight}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func arrivalSeries(t *testing.T, seed uint64, arrivals ArrivalConfig, steps int) []int {
+	t.Helper()
+	cfg := Config{
+		Name:   "arrivals",
+		Steps:  steps,
+		Seed:   seed,
+		Grid:   GridConfig{Width: 20, Height: 10},
+		Signal: SignalConfig{VerticalGreenSteps: 5, HorizontalGreenSteps: 5},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Up: {EntryX: 10, EntryY: 9, Arrivals: arrivals},
+			},
+		},
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	lane := engine.laneStates[string(Up)]
+	series := make([]int, steps)
+	for step := range series {
+		series[step] = engine.arrivalsForStep(lane, step)
+	}
+	return series
+}
+
+func TestSeededArrivalsAreReproducible(t *testing.T) {
+	poisson := ArrivalConfig{Distribution: ArrivalsPoisson, Rate: 0.4}
+	a := arrivalSeries(t, 11, poisson, 200)
+	b := arrivalSeries(t, 11, poisson, 200)
+	c := arrivalSeries(t, 12, poisson, 200)
+
+	same := true
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("step %d: arrivals differ for the same seed (%d vs %d)", i+1, a[i], b[i])
+		}
+		if a[i] != c[i] {
+			same = false
+		}
+	}
+	if same {
+		t.Fatalf("different seeds produced identical arrivals")
+	}
+}
+
+func TestPoissonArrivalsMatchRate(t *testing.T) {
+	const steps = 20000
+	series := arrivalSeries(t, 3, ArrivalConfig{Distribution: ArrivalsPoisson, Rate: 0.3}, steps)
+	total := 0
+	for _, n := range series {
+		total += n
+	}
+	mean := float64(total) / steps
+	if math.Abs(mean-0.3) > 0.02 {
+		t.Fatalf("mean arrivals per step = %.3f, want about 0.3", mean)
+	}
+}
+
+func TestHeadwayArrivals(t *testing.T) {
+	tests := []struct {
+		name      string
+		arrivals  ArrivalConfig
+		wantBurst int
+	}{
+		{name: "uniform", arrivals: ArrivalConfig{Distribution: ArrivalsUniform, MinHeadway: 3, MaxHeadway: 6}, wantBurst: 1},
+		{name: "platoon", arrivals: ArrivalConfig{Distribution: ArrivalsPlatoon, MinHeadway: 10, MaxHeadway: 15, PlatoonSize: 4}, wantBurst: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := arrivalSeries(t, 5, tt.arrivals, 500)
+			last := 0
+			for i, n := range series {
+				if n == 0 {
+					continue
+				}
+				if n != tt.wantBurst {
+					t.Fatalf("step %d: %d arrivals, want %d", i+1, n, tt.wantBurst)
+				}
+				headway := i + 1 - last
+				if headway < tt.arrivals.MinHeadway || headway > tt.arrivals.MaxHeadway {
+					t.Fatalf("step %d: headway %d outside %d..%d", i+1, headway, tt.arrivals.MinHeadway, tt.arrivals.MaxHeadway)
+				}
+				last = i + 1
+			}
+			if last == 0 {
+				t.Fatalf("no arrivals generated")
+			}
+		})
+	}
+}
+
+func TestValidateArrivalsRejectsBadSettings(t *testing.T) {
+	tests := []ArrivalConfig{
+		{Distribution: "gaussian"},
+		{Distribution: ArrivalsPoisson},
+		{Distribution: ArrivalsUniform, MinHeadway: 4, MaxHeadway: 2},
+		{Distribution: ArrivalsPlatoon, MinHeadway: 4, MaxHeadway: 6},
+	}
+	for _, arrivals := range tests {
+		if err := validateArrivals(arrivals); err == nil {
+			t.Fatalf("expected error for %+v", arrivals)
+		}
+	}
+}
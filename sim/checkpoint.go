@@ -0,0 +1,361 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+)
+
+// checkpointVersion changes whenever the checkpoint layout does, so old
+// files are rejected instead of restoring a half-filled engine.
+const checkpointVersion = 5
+
+// Checkpoint is the complete state of an engine between two steps. Restoring
+// it and running the remaining steps gives the same report as a run that was
+// never interrupted. Controllers set with SetController and observers added
+// with AddObserver are not part of it and have to be registered again.
+type Checkpoint struct {
+	Version         int                      `json:"version"`
+	Config          Config                   `json:"config"`
+	Step            int                      `json:"step"`
+	NextVehicleID   int                      `json:"next_vehicle_id"`
+	Vehicles        []Vehicle                `json:"vehicles"`
+	Intersections   []IntersectionCheckpoint `json:"intersections"`
+	Lanes           []LaneCheckpoint         `json:"lanes"`
+	Crosswalks      []CrosswalkState         `json:"crosswalks,omitempty"`
+	Counters        metricsCheckpoint        `json:"counters"`
+	CaptureTimeline bool                     `json:"capture_timeline,omitempty"`
+	Timeline        []StepSnapshot           `json:"timeline,omitempty"`
+	Series          []SeriesSample           `json:"series,omitempty"`
+	SeriesWindow    SeriesSample             `json:"series_window"`
+	Trips           *tripCheckpoint          `json:"trips,omitempty"`
+}
+
+type IntersectionCheckpoint struct {
+	ID             string            `json:"id"`
+	Light          TrafficLight      `json:"light"`
+	SinceDetection map[Direction]int `json:"since_detection"`
+	Stats          IntersectionStats `json:"stats"`
+}
+
+// LaneCheckpoint is a lane with its demand profile and the state of its
+// random arrival stream.
+type LaneCheckpoint struct {
+	LaneState
+	Random []byte `json:"random"`
+}
+
+// tripCheckpoint holds the trip log when trips are captured: the finished
+// trips and the progress of the vehicles still on the grid.
+type tripCheckpoint struct {
+	Trips    []Trip               `json:"trips"`
+	Progress map[int]tripProgress `json:"progress"`
+}
+
+type metricsCheckpoint struct {
+	TotalVehicleStep   int                            `json:"total_vehicle_steps"`
+	TotalWaitEnded     int                            `json:"total_wait_ended"`
+	TotalTripEnded     int                            `json:"total_trip_ended"`
+	DirWaitEnded       map[Direction]int              `json:"direction_wait_ended"`
+	DirTripEnded       map[Direction]int              `json:"direction_trip_ended"`
+	DirDone            map[Direction]int              `json:"direction_done"`
+	DirSpawn           map[Direction]int              `json:"direction_spawned"`
+	DirWaits           map[Direction][]int            `json:"direction_waits"`
+	DirTrips           map[Direction][]int            `json:"direction_trips"`
+	MoveWaitEnded      map[Direction]map[Movement]int `json:"movement_wait_ended"`
+	MoveTripEnded      map[Direction]map[Movement]int `json:"movement_trip_ended"`
+	MoveDone           map[Direction]map[Movement]int `json:"movement_done"`
+	MoveSpawn          map[Direction]map[Movement]int `json:"movement_spawned"`
+	ClassWaitEnded     map[string]int                 `json:"class_wait_ended"`
+	ClassTripEnded     map[string]int                 `json:"class_trip_ended"`
+	ClassDone          map[string]int                 `json:"class_done"`
+	ClassSpawn         map[string]int                 `json:"class_spawned"`
+	ClassWaits         map[string][]int               `json:"class_waits"`
+	ClassTrips         map[string][]int               `json:"class_trips"`
+	BlockedSignal      int                            `json:"blocked_by_signal"`
+	BlockedTraffic     int                            `json:"blocked_by_traffic"`
+	PotentialCrash     int                            `json:"potential_collisions"`
+	YellowEntries      int                            `json:"yellow_entries"`
+	ClearanceCrash     int                            `json:"clearance_conflicts"`
+	TotalDistance      int                            `json:"total_distance"`
+	LaneChanges        int                            `json:"lane_changes"`
+	MandatoryChanges   int                            `json:"mandatory_lane_changes"`
+	RoadLanes          map[Direction][]RoadLaneStats  `json:"road_lanes"`
+	PedestrianWaits    map[string][]int               `json:"pedestrian_waits,omitempty"`
+	BlockedPedestrians int                            `json:"blocked_by_pedestrians,omitempty"`
+}
+
+// Checkpoint captures the engine state after the steps run so far.
+func (e *Engine) Checkpoint() (Checkpoint, error) {
+	cp := Checkpoint{
+		Version:         checkpointVersion,
+		Config:          e.cfg,
+		Step:            e.step,
+		NextVehicleID:   e.nextVehicleID,
+		Vehicles:        append([]Vehicle(nil), e.vehicles...),
+		Counters:        e.stats.checkpoint(),
+		CaptureTimeline: e.captureTimeline,
+		Timeline:        append([]StepSnapshot(nil), e.timeline...),
+	}
+	if e.series != nil {
+		cp.Series = append([]SeriesSample(nil), e.series.samples...)
+		cp.SeriesWindow = e.series.current
+		cp.SeriesWindow.LaneQueues = copyCounts(e.series.current.LaneQueues)
+	}
+	if e.trips != nil {
+		trips := &tripCheckpoint{
+			Trips:    append([]Trip(nil), e.trips.trips...),
+			Progress: make(map[int]tripProgress, len(e.trips.progress)),
+		}
+		for id, p := range e.trips.progress {
+			trips.Progress[id] = *p
+		}
+		cp.Trips = trips
+	}
+	for _, in := range e.intersections {
+		cp.Intersections = append(cp.Intersections, IntersectionCheckpoint{
+			ID:             in.id,
+			Light:          in.light,
+			SinceDetection: copyCounts(in.sinceDetection),
+			Stats:          *e.stats.intersections[in.id],
+		})
+	}
+	for _, id := range e.laneOrder {
+		lane := e.laneStates[id]
+		random, err := lane.source.MarshalBinary()
+		if err != nil {
+			return Checkpoint{}, fmt.Errorf("lane %q: save random state: %w", id, err)
+		}
+		state := *lane
+		state.MovementCounts = copyCounts(lane.MovementCounts)
+		state.ClassCounts = copyCounts(lane.ClassCounts)
+		state.source, state.rng = nil, nil
+		cp.Lanes = append(cp.Lanes, LaneCheckpoint{LaneState: state, Random: random})
+	}
+	for _, c := range e.crosswalks {
+		cp.Crosswalks = append(cp.Crosswalks, c.copy())
+	}
+	return cp, nil
+}
+
+// RestoreEngine rebuilds an engine from a checkpoint. Demand profiles come
+// from the checkpoint, so the CSV files need not exist any more.
+func RestoreEngine(cp Checkpoint) (*Engine, error) {
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d", cp.Version)
+	}
+	noProfile := func(string, string) (DemandProfile, error) { return nil, nil }
+	e, err := newEngine(cp.Config, noProfile)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Step < 0 || cp.Step > cp.Config.Steps {
+		return nil, fmt.Errorf("checkpoint step %d outside 0..%d", cp.Step, cp.Config.Steps)
+	}
+
+	if len(cp.Lanes) != len(e.laneStates) {
+		return nil, fmt.Errorf("checkpoint has %d lanes, config has %d", len(cp.Lanes), len(e.laneStates))
+	}
+	for _, saved := range cp.Lanes {
+		lane, ok := e.laneStates[saved.ID]
+		if !ok {
+			return nil, fmt.Errorf("checkpoint lane %q not in config", saved.ID)
+		}
+		source := &rand.PCG{}
+		if err := source.UnmarshalBinary(saved.Random); err != nil {
+			return nil, fmt.Errorf("lane %q: restore random state: %w", saved.ID, err)
+		}
+		*lane = saved.LaneState
+		lane.source = source
+		lane.rng = rand.New(source)
+	}
+
+	if len(cp.Crosswalks) != len(e.crosswalks) {
+		return nil, fmt.Errorf("checkpoint has %d crosswalks, config has %d", len(cp.Crosswalks), len(e.crosswalks))
+	}
+	for i, saved := range cp.Crosswalks {
+		c := e.crosswalks[i]
+		if saved.ID != c.ID {
+			return nil, fmt.Errorf("checkpoint crosswalk %q not in config", saved.ID)
+		}
+		saved.intersection = c.intersection
+		*c = saved
+	}
+
+	if len(cp.Intersections) != len(e.intersections) {
+		return nil, fmt.Errorf("checkpoint has %d intersections, network has %d", len(cp.Intersections), len(e.intersections))
+	}
+	byID := make(map[string]IntersectionCheckpoint, len(cp.Intersections))
+	for _, saved := range cp.Intersections {
+		byID[saved.ID] = saved
+	}
+	e.stats.restore(cp.Counters)
+	for _, in := range e.intersections {
+		saved, ok := byID[in.id]
+		if !ok {
+			return nil, fmt.Errorf("intersection %q missing from checkpoint", in.id)
+		}
+		in.light = saved.Light
+		in.sinceDetection = copyCounts(saved.SinceDetection)
+		stats := saved.Stats
+		e.stats.intersections[in.id] = &stats
+		e.stats.lastGreen[in.id] = saved.Light.LastGreen
+	}
+
+	e.step = cp.Step
+	e.nextVehicleID = cp.NextVehicleID
+	e.vehicles = append([]Vehicle(nil), cp.Vehicles...)
+	e.captureTimeline = cp.CaptureTimeline
+	e.timeline = append([]StepSnapshot(nil), cp.Timeline...)
+	if e.series != nil {
+		e.series.samples = append([]SeriesSample(nil), cp.Series...)
+		e.series.current = cp.SeriesWindow
+	}
+	if cp.Trips != nil {
+		e.CaptureTrips()
+		e.trips.trips = append([]Trip(nil), cp.Trips.Trips...)
+		for id, p := range cp.Trips.Progress {
+			p := p
+			e.trips.progress[id] = &p
+		}
+	}
+	return e, nil
+}
+
+func WriteCheckpoint(path string, cp Checkpoint) error {
+	if path == "" {
+		return fmt.Errorf("checkpoint path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create checkpoint directory: %w", err)
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
+
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("read checkpoint: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, fmt.Errorf("parse checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+func (m *metricsObserver) checkpoint() metricsCheckpoint {
+	return metricsCheckpoint{
+		TotalVehicleStep:   m.totalVehicleStep,
+		TotalWaitEnded:     m.totalWaitEnded,
+		TotalTripEnded:     m.totalTripEnded,
+		DirWaitEnded:       copyCounts(m.dirWaitEnded),
+		DirTripEnded:       copyCounts(m.dirTripEnded),
+		DirDone:            copyCounts(m.dirDone),
+		DirSpawn:           copyCounts(m.dirSpawn),
+		DirWaits:           copySamples(m.dirWaits),
+		DirTrips:           copySamples(m.dirTrips),
+		MoveWaitEnded:      nestMovements(m.moveWaitEnded),
+		MoveTripEnded:      nestMovements(m.moveTripEnded),
+		MoveDone:           nestMovements(m.moveDone),
+		MoveSpawn:          nestMovements(m.moveSpawn),
+		ClassWaitEnded:     copyCounts(m.classWaitEnded),
+		ClassTripEnded:     copyCounts(m.classTripEnded),
+		ClassDone:          copyCounts(m.classDone),
+		ClassSpawn:         copyCounts(m.classSpawn),
+		ClassWaits:         copySamples(m.classWaits),
+		ClassTrips:         copySamples(m.classTrips),
+		BlockedSignal:      m.blockedSignal,
+		BlockedTraffic:     m.blockedTraffic,
+		PotentialCrash:     m.potentialCrash,
+		YellowEntries:      m.yellowEntries,
+		ClearanceCrash:     m.clearanceCrash,
+		TotalDistance:      m.totalDistance,
+		LaneChanges:        m.laneChanges,
+		MandatoryChanges:   m.mandatoryChanges,
+		RoadLanes:          copySamples(m.roadLanes),
+		PedestrianWaits:    copySamples(m.pedestrianWaits),
+		BlockedPedestrians: m.blockedPedestrians,
+	}
+}
+
+func (m *metricsObserver) restore(cp metricsCheckpoint) {
+	m.totalVehicleStep = cp.TotalVehicleStep
+	m.totalWaitEnded = cp.TotalWaitEnded
+	m.totalTripEnded = cp.TotalTripEnded
+	m.dirWaitEnded = copyCounts(cp.DirWaitEnded)
+	m.dirTripEnded = copyCounts(cp.DirTripEnded)
+	m.dirDone = copyCounts(cp.DirDone)
+	m.dirSpawn = copyCounts(cp.DirSpawn)
+	m.dirWaits = copySamples(cp.DirWaits)
+	m.dirTrips = copySamples(cp.DirTrips)
+	m.moveWaitEnded = flattenMovements(cp.MoveWaitEnded)
+	m.moveTripEnded = flattenMovements(cp.MoveTripEnded)
+	m.moveDone = flattenMovements(cp.MoveDone)
+	m.moveSpawn = flattenMovements(cp.MoveSpawn)
+	m.classWaitEnded = copyCounts(cp.ClassWaitEnded)
+	m.classTripEnded = copyCounts(cp.ClassTripEnded)
+	m.classDone = copyCounts(cp.ClassDone)
+	m.classSpawn = copyCounts(cp.ClassSpawn)
+	m.classWaits = copySamples(cp.ClassWaits)
+	m.classTrips = copySamples(cp.ClassTrips)
+	m.blockedSignal = cp.BlockedSignal
+	m.blockedTraffic = cp.BlockedTraffic
+	m.potentialCrash = cp.PotentialCrash
+	m.yellowEntries = cp.YellowEntries
+	m.clearanceCrash = cp.ClearanceCrash
+	m.totalDistance = cp.TotalDistance
+	m.laneChanges = cp.LaneChanges
+	m.mandatoryChanges = cp.MandatoryChanges
+	m.roadLanes = copySamples(cp.RoadLanes)
+	m.pedestrianWaits = copySamples(cp.PedestrianWaits)
+	m.blockedPedestrians = cp.BlockedPedestrians
+}
+
+func copyCounts[K comparable](counts map[K]int) map[K]int {
+	out := make(map[K]int, len(counts))
+	for k, n := range counts {
+		out[k] = n
+	}
+	return out
+}
+
+func copySamples[K comparable, V any](samples map[K][]V) map[K][]V {
+	out := make(map[K][]V, len(samples))
+	for k, values := range samples {
+		out[k] = append([]V(nil), values...)
+	}
+	return out
+}
+
+func nestMovements(counts map[movementKey]int) map[Direction]map[Movement]int {
+	out := map[Direction]map[Movement]int{}
+	for key, n := range counts {
+		if out[key.dir] == nil {
+			out[key.dir] = map[Movement]int{}
+		}
+		out[key.dir][key.movement] = n
+	}
+	return out
+}
+
+func flattenMovements(counts map[Direction]map[Movement]int) map[movementKey]int {
+	out := map[movementKey]int{}
+	for dir, movements := range counts {
+		for movement, n := range movements {
+			out[movementKey{dir: dir, movement: movement}] = n
+		}
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package sim
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointResumeMatchesUninterruptedRun(t *testing.T) {
+	for _, path := range []string{"../configs/random-arrivals.json", "../configs/turning.json", "../configs/rush-hour.json", "../configs/turn-bays.json", "../configs/truck-mix.json", "../configs/cellular.json", "../configs/idm.json", "../configs/pedestrians.json", "../configs/pedestrians-exclusive.json"} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("load config: %v", err)
+			}
+			cfg.Series.Window = 7
+
+			full, err := NewEngine(cfg)
+			if err != nil {
+				t.Fatalf("new engine: %v", err)
+			}
+			full.CaptureTrips()
+			want := full.Run(true, boolPtr(false))
+
+			first, err := NewEngine(cfg)
+			if err != nil {
+				t.Fatalf("new engine: %v", err)
+			}
+			first.CaptureTimeline()
+			first.CaptureTrips()
+			for i := 0; i < cfg.Steps/2; i++ {
+				if err := first.Step(); err != nil {
+					t.Fatalf("step: %v", err)
+				}
+			}
+			file := filepath.Join(t.TempDir(), "engine.checkpoint.json")
+			cp, err := first.Checkpoint()
+			if err != nil {
+				t.Fatalf("checkpoint: %v", err)
+			}
+			if err := WriteCheckpoint(file, cp); err != nil {
+				t.Fatalf("write checkpoint: %v", err)
+			}
+
+			loaded, err := LoadCheckpoint(file)
+			if err != nil {
+				t.Fatalf("load checkpoint: %v", err)
+			}
+			resumed, err := RestoreEngine(loaded)
+			if err != nil {
+				t.Fatalf("restore: %v", err)
+			}
+			got := resumed.Run(false, boolPtr(false))
+
+			if !reflect.DeepEqual(got.Metrics, want.Metrics) {
+				t.Fatalf("resumed metrics differ:\n got %+v\nwant %+v", got.Metrics, want.Metrics)
+			}
+			gotTimeline, _ := json.Marshal(got.Timeline)
+			wantTimeline, _ := json.Marshal(want.Timeline)
+			if string(gotTimeline) != string(wantTimeline) {
+				t.Fatalf("resumed timeline differs from uninterrupted run")
+			}
+			if !reflect.DeepEqual(got.Trips, want.Trips) {
+				t.Fatalf("resumed trip log differs from uninterrupted run")
+			}
+			if !reflect.DeepEqual(got.Series, want.Series) {
+				t.Fatalf("resumed series differs:\n got %+v\nwant %+v", got.Series, want.Series)
+			}
+		})
+	}
+}
+
+func TestRestoreRejectsMismatchedCheckpoint(t *testing.T) {
+	engine, err := NewEngine(stepTestConfig())
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	cp, err := engine.Checkpoint()
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	old := cp
+	old.Version = 0
+	if _, err := RestoreEngine(old); err == nil {
+		t.Fatalf("expected error for unsupported version")
+	}
+	missing := cp
+	missing.Lanes = cp.Lanes[:1]
+	if _, err := RestoreEngine(missing); err == nil {
+		t.Fatalf("expected error for missing lane")
+	}
+}
@@ -0,0 +1,220 @@
+package sim
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultClass is the class of vehicles spawned by lanes without a class mix.
+const DefaultClass = "car"
+
+// progressEpsilon absorbs rounding when fractional speeds add up to a cell.
+const progressEpsilon = 1e-9
+
+// VehicleClassConfig describes a kind of vehicle. Length is the number of
+// cells it occupies, MaxSpeed the cells it covers per step at full speed,
+// Acceleration how much that speed may grow per step and Deceleration how
+// much it comfortably drops per step. Unit dynamics move vehicles at most one
+// cell per step, so there MaxSpeed is at most one, a slower class needs
+// several steps for each cell and Deceleration is not used.
+type VehicleClassConfig struct {
+	Length       int     `json:"length"`
+	MaxSpeed     float64 `json:"max_speed"`
+	Acceleration float64 `json:"acceleration"`
+	Deceleration float64 `json:"deceleration,omitempty"`
+}
+
+// builtinClasses are available without being declared. Classes in the config
+// override them by name.
+var builtinClasses = map[string]VehicleClassConfig{
+	"car":   {Length: 1, MaxSpeed: 1, Acceleration: 1, Deceleration: 1},
+	"truck": {Length: 3, MaxSpeed: 0.75, Acceleration: 0.25, Deceleration: 0.5},
+	"bus":   {Length: 3, MaxSpeed: 0.75, Acceleration: 0.35, Deceleration: 0.5},
+	"bike":  {Length: 1, MaxSpeed: 0.5, Acceleration: 0.5, Deceleration: 0.5},
+}
+
+// Segment is a cell a long vehicle occupies behind its front, with the
+// heading and road lane it had when its front was there.
+type Segment struct {
+	X        int       `json:"x"`
+	Y        int       `json:"y"`
+	Heading  Direction `json:"heading"`
+	RoadLane int       `json:"road_lane,omitempty"`
+}
+
+// ClassStats are the trip counters of one vehicle class.
+type ClassStats struct {
+	Spawned          int          `json:"spawned"`
+	Completed        int          `json:"completed"`
+	AverageWait      float64      `json:"average_wait"`
+	AverageDuration  float64      `json:"average_duration"`
+	WaitDistribution Distribution `json:"wait_distribution"`
+	TripDistribution Distribution `json:"trip_distribution"`
+}
+
+// Segments returns the cells the vehicle occupies, front first.
+func (v Vehicle) Segments() []Segment {
+	segments := make([]Segment, 0, 1+len(v.Tail))
+	segments = append(segments, Segment{X: v.X, Y: v.Y, Heading: v.CurrentHeading(), RoadLane: v.RoadLane})
+	return append(segments, v.Tail...)
+}
+
+// vehicleClasses returns the built-in classes merged with the ones in cfg.
+// Fields a configured class leaves at zero come from the built-in class of
+// the same name, or from a car.
+func vehicleClasses(cfg Config) map[string]VehicleClassConfig {
+	classes := make(map[string]VehicleClassConfig, len(builtinClasses)+len(cfg.VehicleClasses))
+	for name, class := range builtinClasses {
+		classes[name] = class
+	}
+	for name, class := range cfg.VehicleClasses {
+		base, ok := builtinClasses[name]
+		if !ok {
+			base = builtinClasses[DefaultClass]
+		}
+		if class.Length == 0 {
+			class.Length = base.Length
+		}
+		if class.MaxSpeed == 0 {
+			class.MaxSpeed = base.MaxSpeed
+		}
+		if class.Acceleration == 0 {
+			class.Acceleration = base.Acceleration
+		}
+		if class.Deceleration == 0 {
+			class.Deceleration = base.Deceleration
+		}
+		classes[name] = class
+	}
+	return classes
+}
+
+func validateClasses(cfg Config) error {
+	for name, class := range cfg.VehicleClasses {
+		if name == "" {
+			return fmt.Errorf("vehicle classes require a name")
+		}
+		if class.Length < 0 {
+			return fmt.Errorf("vehicle class %q length must be >= 0", name)
+		}
+		if class.MaxSpeed < 0 {
+			return fmt.Errorf("vehicle class %q max_speed must be >= 0", name)
+		}
+		if class.MaxSpeed > 1 && dynamicsModel(cfg.Dynamics) == DynamicsUnit {
+			return fmt.Errorf("vehicle class %q max_speed must be at most 1 cell per step with unit dynamics", name)
+		}
+		if class.Acceleration < 0 || class.Deceleration < 0 {
+			return fmt.Errorf("vehicle class %q acceleration and deceleration must be >= 0", name)
+		}
+	}
+	return nil
+}
+
+func validateClassMix(classes map[string]VehicleClassConfig, mix map[string]float64) error {
+	for name, share := range mix {
+		if _, ok := classes[name]; !ok {
+			return fmt.Errorf("unknown vehicle class %q", name)
+		}
+		if share < 0 {
+			return fmt.Errorf("vehicle class %q share must be >= 0", name)
+		}
+	}
+	return nil
+}
+
+// nextClass assigns the class of the next vehicle leaving a lane, the same
+// way nextMovement assigns movements.
+func (lane *LaneState) nextClass() string {
+	names := make([]string, 0, len(lane.Classes))
+	total := 0.0
+	for name, share := range lane.Classes {
+		if share > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		total += lane.Classes[name]
+	}
+	if total <= 0 {
+		return DefaultClass
+	}
+	if lane.ClassCounts == nil {
+		lane.ClassCounts = map[string]int{}
+	}
+
+	assigned := 0
+	for _, count := range lane.ClassCounts {
+		assigned += count
+	}
+	best := ""
+	bestDeficit := 0.0
+	for _, name := range names {
+		deficit := lane.Classes[name]/total*float64(assigned+1) - float64(lane.ClassCounts[name])
+		if best == "" || deficit > bestDeficit {
+			best = name
+			bestDeficit = deficit
+		}
+	}
+	lane.ClassCounts[best]++
+	return best
+}
+
+func (e *Engine) class(v Vehicle) VehicleClassConfig {
+	if class, ok := e.classes[v.Class]; ok {
+		return class
+	}
+	return builtinClasses[DefaultClass]
+}
+
+// length returns the number of cells a vehicle occupies once fully on the
+// grid.
+func (e *Engine) length(v Vehicle) int {
+	return max(e.class(v).Length, 1)
+}
+
+// tailAfterMove returns the tail of a vehicle whose front leaves its current
+// cell. The tail is replaced rather than modified, since snapshots and
+// events share it.
+func (e *Engine) tailAfterMove(v Vehicle) []Segment {
+	length := e.length(v)
+	if length <= 1 {
+		return nil
+	}
+	tail := make([]Segment, 0, length-1)
+	tail = append(tail, Segment{X: v.X, Y: v.Y, Heading: v.CurrentHeading(), RoadLane: v.RoadLane})
+	return append(tail, v.Tail[:min(len(v.Tail), length-2)]...)
+}
+
+// tailLeaves reports whether the cell of tail segment i is free once the
+// vehicle moves, which is only the case for the last segment of a vehicle
+// that is fully on the grid.
+func (e *Engine) tailLeaves(v Vehicle, i int) bool {
+	return i == len(v.Tail)-1 && len(v.Tail) == e.length(v)-1
+}
+
+// segmentMovement returns the movement a vehicle made leaving the cell of
+// tail segment i.
+func segmentMovement(v Vehicle, i int) Movement {
+	ahead := v.CurrentHeading()
+	if i > 0 {
+		ahead = v.Tail[i-1].Heading
+	}
+	from := v.Tail[i].Heading
+	switch ahead {
+	case from:
+		return Through
+	case turnHeading(from, TurnRight):
+		return TurnRight
+	}
+	return TurnLeft
+}
+
+// slots returns the slots a vehicle occupies, front first.
+func (e *Engine) slots(v Vehicle) []slot {
+	slots := []slot{e.slotOf(v)}
+	for _, s := range v.Tail {
+		slots = append(slots, e.slotAt(s.X, s.Y, s.Heading, s.RoadLane))
+	}
+	return slots
+}
@@ -0,0 +1,110 @@
+package sim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNextClassFollowsShares(t *testing.T) {
+	lane := &LaneState{Classes: map[string]float64{"car": 0.9, "truck": 0.1}}
+	counts := map[string]int{}
+	for range 20 {
+		counts[lane.nextClass()]++
+	}
+	if counts["car"] != 18 || counts["truck"] != 2 {
+		t.Fatalf("classes = %v, want 18 cars and 2 trucks", counts)
+	}
+
+	if got := (&LaneState{}).nextClass(); got != DefaultClass {
+		t.Fatalf("lane without a mix spawned %q", got)
+	}
+}
+
+// classTestConfig is a straight road through one intersection whose signal
+// stays green for the vehicles entering from the left.
+func classTestConfig(mix map[string]float64) Config {
+	return Config{
+		Name:  "class-test",
+		Steps: 60,
+		Grid:  GridConfig{Width: 12, Height: 5},
+		Signal: SignalConfig{
+			VerticalGreenSteps:   1,
+			HorizontalGreenSteps: 1000,
+		},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Right: {EntryX: 0, EntryY: 2, StepInterval: 1, MaxVehicles: 6, Classes: mix},
+			},
+		},
+	}
+}
+
+func TestTrucksOccupyTheirLength(t *testing.T) {
+	engine, err := NewEngine(classTestConfig(map[string]float64{"truck": 1}))
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	engine.CaptureTimeline()
+	stepChecked(t, engine)
+	report := engine.Finalize()
+
+	longest := 0
+	for _, snap := range report.Timeline {
+		for _, v := range snap.Vehicles {
+			longest = max(longest, len(v.Segments()))
+		}
+	}
+	if longest != 3 {
+		t.Fatalf("trucks occupied up to %d cells, want 3", longest)
+	}
+	m := report.Metrics
+	if stats := m.ClassStats["truck"]; stats.Spawned != 6 || stats.Completed != 6 {
+		t.Fatalf("truck stats = %+v, want 6 spawned and completed", stats)
+	}
+	if m.PotentialCollisions != 0 {
+		t.Fatalf("trucks produced %d potential collisions", m.PotentialCollisions)
+	}
+}
+
+func TestSlowClassesTakeLonger(t *testing.T) {
+	durations := map[string]float64{}
+	for _, class := range []string{"car", "bike"} {
+		engine, err := NewEngine(classTestConfig(map[string]float64{class: 1}))
+		if err != nil {
+			t.Fatalf("new engine: %v", err)
+		}
+		m := engine.Run(false, boolPtr(false)).Metrics
+		stats := m.ClassStats[class]
+		if stats.Completed != 6 {
+			t.Fatalf("%s completed %d trips, want 6", class, stats.Completed)
+		}
+		durations[class] = stats.AverageDuration
+	}
+	// Cars cross the 12 cells in 12 steps; bikes at half a cell per step
+	// need twice as long.
+	if durations["car"] != 12 || durations["bike"] != 24 {
+		t.Fatalf("average trip = %v, want car 12 and bike 24", durations)
+	}
+}
+
+func TestLoadConfigRejectsUnknownVehicleClass(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "invalid.json")
+	content := `{
+		"grid": { "width": 20, "height": 10 },
+		"vehicle_classes": { "van": { "length": 2 } },
+		"spawn": {
+			"lanes": { "right": { "entry_x": 0, "entry_y": 5, "step_interval": 2, "classes": { "van": 1, "tram": 1 } } }
+		}
+	}`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := LoadConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), `unknown vehicle class "tram"`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
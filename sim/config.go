@@ -0,0 +1,438 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Direction string
+
+const (
+	Up    Direction = "up"
+	Down  Direction = "down"
+	Left  Direction = "left"
+	Right Direction = "right"
+)
+
+type Movement string
+
+const (
+	Through   Movement = "through"
+	TurnRight Movement = "right"
+	TurnLeft  Movement = "left"
+)
+
+type Axis string
+
+const (
+	Horizontal Axis = "horizontal"
+	Vertical   Axis = "vertical"
+)
+
+type Config struct {
+	Name       string           `json:"name"`
+	Steps      int              `json:"steps"`
+	Seed       uint64           `json:"seed"`
+	Grid       GridConfig       `json:"grid"`
+	Network    NetworkConfig    `json:"network"`
+	Signal     SignalConfig     `json:"signal"`
+	Spawn      SpawnConfig      `json:"spawn"`
+	Render     RenderConfig     `json:"render"`
+	Series     SeriesConfig     `json:"series"`
+	LaneChange LaneChangeConfig `json:"lane_change"`
+	ReportPath string           `json:"report_path"`
+
+	VehicleClasses map[string]VehicleClassConfig `json:"vehicle_classes,omitempty"`
+	Dynamics       DynamicsConfig                `json:"dynamics"`
+	Pedestrians    PedestrianConfig              `json:"pedestrians"`
+}
+
+type GridConfig struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// NetworkConfig declares the road layout. When both lists are empty the
+// network is a single intersection at the grid center. Omitted roads are
+// derived from the intersections and omitted intersections are derived from
+// the crossings of the roads.
+type NetworkConfig struct {
+	Roads         []RoadConfig         `json:"roads"`
+	Intersections []IntersectionConfig `json:"intersections"`
+}
+
+// RoadConfig is a straight road segment along one axis. At is the row of a
+// horizontal road or the column of a vertical road; From and To bound the
+// segment on the other coordinate and default to the full grid extent.
+//
+// Lanes is the number of lanes per travel direction and defaults to one.
+// Where segments overlap a cell has the lanes of the widest one, so a short
+// segment with an extra lane and a Direction, which limits its lanes to
+// traffic heading that way, adds a turn bay to a longer road. Lanes are
+// counted from the curb and extra lanes are added on the median side.
+type RoadConfig struct {
+	ID        string    `json:"id"`
+	Axis      Axis      `json:"axis"`
+	At        int       `json:"at"`
+	From      int       `json:"from"`
+	To        int       `json:"to"`
+	Lanes     int       `json:"lanes,omitempty"`
+	Direction Direction `json:"direction,omitempty"`
+}
+
+// IntersectionConfig is a signalized crossing. Signal overrides the global
+// signal plan and Offset shifts the cycle start for coordinated corridors.
+type IntersectionConfig struct {
+	ID     string          `json:"id"`
+	X      int             `json:"x"`
+	Y      int             `json:"y"`
+	Offset int             `json:"offset"`
+	Signal *SignalOverride `json:"signal,omitempty"`
+}
+
+// SignalOverride replaces the settings of the global signal plan it sets.
+// Durations are pointers so that an explicit 0, such as no yellow at one
+// intersection, differs from leaving the global value in place.
+type SignalOverride struct {
+	Controller            string `json:"controller,omitempty"`
+	VerticalGreenSteps    *int   `json:"vertical_green_steps,omitempty"`
+	HorizontalGreenSteps  *int   `json:"horizontal_green_steps,omitempty"`
+	YellowSteps           *int   `json:"yellow_steps,omitempty"`
+	AllRedSteps           *int   `json:"all_red_steps,omitempty"`
+	MinGreenSteps         *int   `json:"min_green_steps,omitempty"`
+	MaxGreenSteps         *int   `json:"max_green_steps,omitempty"`
+	GapSteps              *int   `json:"gap_steps,omitempty"`
+	DetectorCells         *int   `json:"detector_cells,omitempty"`
+	WalkSteps             *int   `json:"walk_steps,omitempty"`
+	FlashingDontWalkSteps *int   `json:"flashing_dont_walk_steps,omitempty"`
+	PedestrianPhase       string `json:"pedestrian_phase,omitempty"`
+}
+
+// SignalConfig is a two-phase plan. Each green is followed by YellowSteps of
+// yellow and AllRedSteps of all-red clearance before the crossing green.
+// Controller selects how green times are decided; the green durations are
+// used by the fixed-time controller, the actuation settings by the actuated
+// one, and MinGreenSteps is the decision interval of max-pressure control.
+//
+// Crosswalks show walk for WalkSteps and flashing don't walk for
+// FlashingDontWalkSteps, both 4 by default. PedestrianPhase picks when: with
+// concurrent phasing, the default, at the start of the green of the traffic
+// running alongside them; with exclusive phasing in a pedestrian phase of
+// their own, which stops all vehicles and follows the horizontal clearance.
+type SignalConfig struct {
+	Controller           string `json:"controller"`
+	VerticalGreenSteps   int    `json:"vertical_green_steps"`
+	HorizontalGreenSteps int    `json:"horizontal_green_steps"`
+	YellowSteps          int    `json:"yellow_steps"`
+	AllRedSteps          int    `json:"all_red_steps"`
+	MinGreenSteps        int    `json:"min_green_steps"`
+	MaxGreenSteps        int    `json:"max_green_steps"`
+	GapSteps             int    `json:"gap_steps"`
+	DetectorCells        int    `json:"detector_cells"`
+
+	WalkSteps             int    `json:"walk_steps,omitempty"`
+	FlashingDontWalkSteps int    `json:"flashing_dont_walk_steps,omitempty"`
+	PedestrianPhase       string `json:"pedestrian_phase,omitempty"`
+}
+
+// SpawnConfig holds one lane per direction in Lanes plus any number of
+// additional Entries, which must carry their own id and direction.
+type SpawnConfig struct {
+	Lanes   map[Direction]LaneSpawnConfig `json:"lanes"`
+	Entries []LaneSpawnConfig             `json:"entries,omitempty"`
+}
+
+type LaneSpawnConfig struct {
+	ID            string        `json:"id,omitempty"`
+	Direction     Direction     `json:"direction,omitempty"`
+	EntryX        int           `json:"entry_x"`
+	EntryY        int           `json:"entry_y"`
+	StepInterval  int           `json:"step_interval"`
+	MaxVehicles   int           `json:"max_vehicles"`
+	ProfileCSV    string        `json:"profile_csv"`
+	ProfileColumn string        `json:"profile_column"`
+	Turns         TurnRatio     `json:"turns"`
+	Arrivals      ArrivalConfig `json:"arrivals"`
+	// Classes splits the lane's vehicles between vehicle classes by relative
+	// weight. A lane without any weight only spawns cars.
+	Classes map[string]float64 `json:"classes,omitempty"`
+}
+
+// TurnRatio splits a lane's vehicles between movements. The shares are
+// relative weights; a lane without any weight sends everything through.
+type TurnRatio struct {
+	Through float64 `json:"through"`
+	Right   float64 `json:"right"`
+	Left    float64 `json:"left"`
+}
+
+type RenderConfig struct {
+	Enabled bool `json:"enabled"`
+	DelayMS int  `json:"delay_ms"`
+}
+
+// SeriesConfig adds a time series of the network state to the report, one
+// sample per Window steps. A zero Window leaves the series out.
+type SeriesConfig struct {
+	Window int `json:"window"`
+}
+
+// LaneChangeConfig sets the gap acceptance of lane changes on multi-lane
+// roads: a vehicle only moves over when the target lane is free beside it and
+// for GapBehind cells behind it. GapBehind defaults to one cell.
+type LaneChangeConfig struct {
+	GapBehind int `json:"gap_behind"`
+}
+
+type DemandProfile map[int]int
+
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+
+	applyDefaults(&cfg)
+	resolveConfigPaths(&cfg, filepath.Dir(path))
+	if err := validateConfig(cfg); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func applyDefaults(cfg *Config) {
+	if cfg.Name == "" {
+		cfg.Name = "default"
+	}
+	if cfg.Steps <= 0 {
+		cfg.Steps = 100
+	}
+	if cfg.Grid.Width <= 0 {
+		cfg.Grid.Width = 20
+	}
+	if cfg.Grid.Height <= 0 {
+		cfg.Grid.Height = 10
+	}
+	if cfg.Signal.VerticalGreenSteps <= 0 {
+		cfg.Signal.VerticalGreenSteps = 5
+	}
+	if cfg.Signal.HorizontalGreenSteps <= 0 {
+		cfg.Signal.HorizontalGreenSteps = 5
+	}
+	if cfg.Signal.YellowSteps < 0 {
+		cfg.Signal.YellowSteps = 0
+	}
+	if cfg.Signal.AllRedSteps < 0 {
+		cfg.Signal.AllRedSteps = 0
+	}
+	if cfg.Spawn.Lanes == nil && len(cfg.Spawn.Entries) == 0 {
+		cfg.Spawn.Lanes = map[Direction]LaneSpawnConfig{
+			Up: {
+				EntryX:       cfg.Grid.Width / 2,
+				EntryY:       cfg.Grid.Height - 1,
+				StepInterval: 3,
+			},
+			Right: {
+				EntryX:       0,
+				EntryY:       cfg.Grid.Height / 2,
+				StepInterval: 4,
+			},
+		}
+	}
+	if cfg.Render.DelayMS < 0 {
+		cfg.Render.DelayMS = 0
+	}
+	cfg.Network = resolveNetwork(*cfg)
+}
+
+func validateConfig(cfg Config) error {
+	if cfg.Grid.Width < 3 || cfg.Grid.Height < 3 {
+		return fmt.Errorf("grid must be at least 3x3")
+	}
+	if cfg.Series.Window < 0 {
+		return fmt.Errorf("series window must be >= 0")
+	}
+	if cfg.LaneChange.GapBehind < 0 {
+		return fmt.Errorf("lane_change gap_behind must be >= 0")
+	}
+	if err := validateDynamics(cfg.Dynamics); err != nil {
+		return err
+	}
+	if err := validateClasses(cfg); err != nil {
+		return err
+	}
+	classes := vehicleClasses(cfg)
+	lanes := spawnLanes(cfg.Spawn)
+	if len(lanes) == 0 {
+		return fmt.Errorf("spawn lanes cannot be empty")
+	}
+
+	network := resolveNetwork(cfg)
+	if err := validateNetwork(cfg.Grid, network); err != nil {
+		return err
+	}
+	for _, in := range network.Intersections {
+		if err := validateSignal(intersectionSignal(cfg.Signal, in)); err != nil {
+			return fmt.Errorf("intersection %q: %w", in.ID, err)
+		}
+	}
+	if err := validatePedestrians(cfg, network); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, lane := range lanes {
+		if lane.ID == "" {
+			return fmt.Errorf("spawn entries require an id")
+		}
+		if seen[lane.ID] {
+			return fmt.Errorf("duplicate lane id %q", lane.ID)
+		}
+		seen[lane.ID] = true
+
+		dir := lane.Direction
+		if !validDirection(dir) {
+			return fmt.Errorf("unsupported direction %q", dir)
+		}
+		if lane.EntryX < 0 || lane.EntryX >= cfg.Grid.Width || lane.EntryY < 0 || lane.EntryY >= cfg.Grid.Height {
+			return fmt.Errorf("lane %q entry is outside grid", lane.ID)
+		}
+		if lane.StepInterval < 0 {
+			return fmt.Errorf("lane %q step_interval must be >= 0", lane.ID)
+		}
+		if lane.MaxVehicles < 0 {
+			return fmt.Errorf("lane %q max_vehicles must be >= 0", lane.ID)
+		}
+		if lane.Turns.Through < 0 || lane.Turns.Right < 0 || lane.Turns.Left < 0 {
+			return fmt.Errorf("lane %q turn ratios must be >= 0", lane.ID)
+		}
+		if err := validateArrivals(lane.Arrivals); err != nil {
+			return fmt.Errorf("lane %q: %w", lane.ID, err)
+		}
+		if err := validateClassMix(classes, lane.Classes); err != nil {
+			return fmt.Errorf("lane %q: %w", lane.ID, err)
+		}
+		if axis := axisOf(dir); !network.onRoad(lane.EntryX, lane.EntryY, axis) {
+			return fmt.Errorf("lane %q entry (%d,%d) is not on a %s road", lane.ID, lane.EntryX, lane.EntryY, axis)
+		}
+	}
+	return nil
+}
+
+func validDirection(d Direction) bool {
+	return d == Up || d == Down || d == Left || d == Right
+}
+
+func validateSignal(signal SignalConfig) error {
+	switch signal.Controller {
+	case "", ControllerFixedTime, ControllerActuated, ControllerMaxPressure:
+	default:
+		return fmt.Errorf("unsupported signal controller %q", signal.Controller)
+	}
+	if signal.MinGreenSteps < 0 || signal.MaxGreenSteps < 0 || signal.GapSteps < 0 || signal.DetectorCells < 0 {
+		return fmt.Errorf("signal actuation settings must be >= 0")
+	}
+	if signal.MaxGreenSteps > 0 && signal.MaxGreenSteps < signal.MinGreenSteps {
+		return fmt.Errorf("signal max_green_steps must be >= min_green_steps")
+	}
+	switch signal.PedestrianPhase {
+	case "", PedestriansConcurrent, PedestriansExclusive:
+	default:
+		return fmt.Errorf("unsupported pedestrian phase %q", signal.PedestrianPhase)
+	}
+	if signal.WalkSteps < 0 || signal.FlashingDontWalkSteps < 0 {
+		return fmt.Errorf("signal walk_steps and flashing_dont_walk_steps must be >= 0")
+	}
+	return nil
+}
+
+func resolveConfigPaths(cfg *Config, baseDir string) {
+	if baseDir == "" {
+		return
+	}
+
+	if cfg.ReportPath != "" && !filepath.IsAbs(cfg.ReportPath) {
+		cfg.ReportPath = filepath.Join(baseDir, cfg.ReportPath)
+	}
+
+	for dir, lane := range cfg.Spawn.Lanes {
+		if lane.ProfileCSV != "" && !filepath.IsAbs(lane.ProfileCSV) {
+			lane.ProfileCSV = filepath.Join(baseDir, lane.ProfileCSV)
+			cfg.Spawn.Lanes[dir] = lane
+		}
+	}
+	for i, lane := range cfg.Spawn.Entries {
+		if lane.ProfileCSV != "" && !filepath.IsAbs(lane.ProfileCSV) {
+			cfg.Spawn.Entries[i].ProfileCSV = filepath.Join(baseDir, lane.ProfileCSV)
+		}
+	}
+	for i, crosswalk := range cfg.Pedestrians.Crosswalks {
+		if crosswalk.ProfileCSV != "" && !filepath.IsAbs(crosswalk.ProfileCSV) {
+			cfg.Pedestrians.Crosswalks[i].ProfileCSV = filepath.Join(baseDir, crosswalk.ProfileCSV)
+		}
+	}
+}
+
+func LoadDemandProfile(path string, column string) (DemandProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open profile: %w", err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read profile csv: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("profile csv is empty")
+	}
+
+	header := rows[0]
+	stepIdx := -1
+	valueIdx := -1
+	for i, col := range header {
+		col = strings.TrimSpace(strings.ToLower(col))
+		if col == "step" {
+			stepIdx = i
+		}
+		if col == strings.TrimSpace(strings.ToLower(column)) {
+			valueIdx = i
+		}
+	}
+	if stepIdx == -1 {
+		return nil, fmt.Errorf("profile csv missing 'step' column")
+	}
+	if valueIdx == -1 {
+		return nil, fmt.Errorf("profile csv missing column %q", column)
+	}
+
+	profile := DemandProfile{}
+	for _, row := range rows[1:] {
+		if stepIdx >= len(row) || valueIdx >= len(row) {
+			continue
+		}
+		step, err := strconv.Atoi(strings.TrimSpace(row[stepIdx]))
+		if err != nil {
+			continue
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(row[valueIdx]))
+		if err != nil {
+			continue
+		}
+		profile[step] = count
+	}
+	return profile, nil
+}
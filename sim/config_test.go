@@ -80,7 +80,7 @@ func TestLoadConfigRejectsLaneOutsideRoadAxis(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected validation error")
 	}
-	if !strings.Contains(err.Error(), "entry_x must equal center road x") {
+	if !strings.Contains(err.Error(), "is not on a vertical road") {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
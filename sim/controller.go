@@ -0,0 +1,279 @@
+package sim
+
+import "fmt"
+
+const (
+	ControllerFixedTime   = "fixed_time"
+	ControllerActuated    = "actuated"
+	ControllerMaxPressure = "max_pressure"
+)
+
+// SignalController decides the phase of one intersection. It is called once
+// per step after vehicles have moved and returns the phase for the next step.
+// Returning an empty phase keeps the current one.
+type SignalController interface {
+	NextPhase(state SignalState) SignalPhase
+}
+
+// SignalState is what a controller sees of its intersection. Light holds the
+// phase that was shown during the step that just ended, with Timer already
+// advanced to count that step.
+type SignalState struct {
+	Step         int
+	Intersection string
+	Light        TrafficLight
+	Approaches   map[Direction]ApproachState
+	// Pedestrians counts the pedestrians waiting at the crosswalks of the
+	// intersection by leg.
+	Pedestrians map[Direction]int
+}
+
+// ApproachState describes the traffic heading into an intersection from one
+// direction. Vehicles counts everything between the previous intersection
+// and the stop line, Queued the vehicles among them that were stopped this
+// step, and Detected those inside the detector zone next to the stop line.
+// SinceDetection is the number of steps since the detector was last
+// occupied. LaneQueue counts vehicles still waiting to enter the grid on
+// lanes that feed the approach. Downstream counts vehicles that have crossed
+// the intersection in this direction and not yet reached the next one.
+type ApproachState struct {
+	Vehicles       int `json:"vehicles"`
+	Queued         int `json:"queued"`
+	Detected       int `json:"detected"`
+	SinceDetection int `json:"since_detection"`
+	LaneQueue      int `json:"lane_queue"`
+	Downstream     int `json:"downstream"`
+}
+
+func newSignalController(signal SignalConfig, offset int) (SignalController, error) {
+	switch signal.Controller {
+	case "", ControllerFixedTime:
+		return fixedTimeController{signal: signal, offset: offset}, nil
+	case ControllerActuated:
+		return newActuatedController(signal), nil
+	case ControllerMaxPressure:
+		return newMaxPressureController(signal), nil
+	default:
+		return nil, fmt.Errorf("unsupported signal controller %q", signal.Controller)
+	}
+}
+
+// phaseAligner is implemented by controllers that keep their own notion of
+// time and need to resynchronize when a phase is forced.
+type phaseAligner interface {
+	align(light *TrafficLight)
+}
+
+type fixedTimeController struct {
+	signal SignalConfig
+	offset int
+}
+
+// align moves the cycle timer forward to the next point where the light's
+// phase starts, so the forced phase runs for its full duration.
+func (c fixedTimeController) align(light *TrafficLight) {
+	signal := c.signal
+	cycle := cycleSteps(signal)
+	if cycle <= 0 {
+		return
+	}
+	best := -1
+	for s := 0; s < cycle; s++ {
+		phase, _ := fixedTimePhase(signal, s+1)
+		prev, _ := fixedTimePhase(signal, s+cycle)
+		if phase != light.Phase || (s > 0 && prev == phase) {
+			continue
+		}
+		// The next update shows cycle position s+1.
+		delta := ((s+1-c.offset-light.Timer)%cycle + cycle) % cycle
+		if best < 0 || delta < best {
+			best = delta
+		}
+	}
+	if best >= 0 {
+		light.Timer += best
+	}
+}
+
+func (c fixedTimeController) NextPhase(state SignalState) SignalPhase {
+	phase, ok := fixedTimePhase(c.signal, state.Light.Timer+c.offset)
+	if !ok {
+		return ""
+	}
+	return phase
+}
+
+// actuatedController holds green for at least MinGreenSteps and extends it
+// while the detectors of the served approaches keep seeing vehicles. The
+// green gaps out once no vehicle has been detected for GapSteps, or maxes
+// out after MaxGreenSteps, but only when the crossing approaches have
+// demand; otherwise it rests in green.
+type actuatedController struct {
+	signal SignalConfig
+}
+
+func newActuatedController(signal SignalConfig) actuatedController {
+	if signal.MinGreenSteps <= 0 {
+		signal.MinGreenSteps = 3
+	}
+	if signal.MaxGreenSteps <= 0 {
+		signal.MaxGreenSteps = 4 * signal.MinGreenSteps
+	}
+	if signal.GapSteps <= 0 {
+		signal.GapSteps = 2
+	}
+	return actuatedController{signal: signal}
+}
+
+func (c actuatedController) NextPhase(state SignalState) SignalPhase {
+	light := state.Light
+	if !light.Phase.IsGreen() {
+		return advancePhase(light, c.signal, false)
+	}
+
+	shown := light.PhaseSteps + 1
+	if shown < c.signal.MinGreenSteps || !(crossingDemand(state) || pedestrianDemand(state, c.signal)) {
+		return advancePhase(light, c.signal, true)
+	}
+	if shown >= c.signal.MaxGreenSteps {
+		return advancePhase(light, c.signal, false)
+	}
+
+	gap := -1
+	for dir, approach := range state.Approaches {
+		if !light.Phase.Serves(dir) {
+			continue
+		}
+		if gap < 0 || approach.SinceDetection < gap {
+			gap = approach.SinceDetection
+		}
+	}
+	hold := gap >= 0 && gap < c.signal.GapSteps
+	return advancePhase(light, c.signal, hold)
+}
+
+// maxPressureController re-decides the green every MinGreenSteps and serves
+// the axis with the largest pressure, the upstream demand of its approaches
+// minus the vehicles already queued downstream of them. Serving that axis
+// moves the most vehicles into links that have room for them. Ties keep the
+// current green.
+type maxPressureController struct {
+	signal SignalConfig
+}
+
+func newMaxPressureController(signal SignalConfig) maxPressureController {
+	if signal.MinGreenSteps <= 0 {
+		signal.MinGreenSteps = 3
+	}
+	return maxPressureController{signal: signal}
+}
+
+func (c maxPressureController) NextPhase(state SignalState) SignalPhase {
+	light := state.Light
+	if !light.Phase.IsGreen() {
+		return advancePhase(light, c.signal, false)
+	}
+	if light.PhaseSteps+1 < c.signal.MinGreenSteps {
+		return light.Phase
+	}
+
+	served, crossing := 0, 0
+	for dir, approach := range state.Approaches {
+		if light.Phase.Serves(dir) {
+			served += approach.pressure()
+		} else {
+			crossing += approach.pressure()
+		}
+	}
+	return advancePhase(light, c.signal, served >= crossing)
+}
+
+func (a ApproachState) pressure() int {
+	return a.Vehicles + a.LaneQueue - a.Downstream
+}
+
+func crossingDemand(state SignalState) bool {
+	for dir, approach := range state.Approaches {
+		if state.Light.Phase.Serves(dir) {
+			continue
+		}
+		if approach.Vehicles > 0 || approach.LaneQueue > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pedestrianDemand reports whether pedestrians wait at a crosswalk the
+// current green does not serve.
+func pedestrianDemand(state SignalState, signal SignalConfig) bool {
+	for leg, waiting := range state.Pedestrians {
+		if waiting > 0 && (signal.PedestrianPhase == PedestriansExclusive || state.Light.Phase.Serves(leg)) {
+			return true
+		}
+	}
+	return false
+}
+
+// advancePhase moves a light one step along the phase sequence. While a green
+// is showing, holdGreen decides whether it continues; yellow, all-red and
+// pedestrian intervals always run for their configured durations.
+func advancePhase(light TrafficLight, signal SignalConfig, holdGreen bool) SignalPhase {
+	shown := light.PhaseSteps + 1
+	switch light.Phase {
+	case PhaseVerticalGreen, PhaseHorizontalGreen:
+		if holdGreen {
+			return light.Phase
+		}
+		if signal.YellowSteps > 0 {
+			return yellowAfter(light.Phase)
+		}
+		return clearAfter(light.Phase, signal)
+	case PhaseVerticalYellow, PhaseHorizontalYellow:
+		if shown < signal.YellowSteps {
+			return light.Phase
+		}
+		return clearAfter(light.LastGreen, signal)
+	case PhaseAllRed:
+		if shown < signal.AllRedSteps {
+			return light.Phase
+		}
+		return afterClearance(light.LastGreen, signal)
+	case PhasePedestrian:
+		if walk, flashing := pedestrianIntervals(signal); shown < walk+flashing {
+			return light.Phase
+		}
+		return crossingGreen(light.LastGreen)
+	}
+	return PhaseVerticalGreen
+}
+
+func yellowAfter(green SignalPhase) SignalPhase {
+	if green == PhaseVerticalGreen {
+		return PhaseVerticalYellow
+	}
+	return PhaseHorizontalYellow
+}
+
+func clearAfter(green SignalPhase, signal SignalConfig) SignalPhase {
+	if signal.AllRedSteps > 0 {
+		return PhaseAllRed
+	}
+	return afterClearance(green, signal)
+}
+
+// afterClearance returns the phase that follows the clearance of green: the
+// crossing green, or the exclusive pedestrian phase once per cycle.
+func afterClearance(green SignalPhase, signal SignalConfig) SignalPhase {
+	if green == PhaseHorizontalGreen && signal.PedestrianPhase == PedestriansExclusive {
+		return PhasePedestrian
+	}
+	return crossingGreen(green)
+}
+
+func crossingGreen(green SignalPhase) SignalPhase {
+	if green == PhaseVerticalGreen {
+		return PhaseHorizontalGreen
+	}
+	return PhaseVerticalGreen
+}
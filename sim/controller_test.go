@@ -0,0 +1,241 @@
+package sim
+
+import "testing"
+
+func TestActuatedControllerGreenDecisions(t *testing.T) {
+	signal := SignalConfig{
+		Controller:    ControllerActuated,
+		YellowSteps:   1,
+		AllRedSteps:   1,
+		MinGreenSteps: 2,
+		MaxGreenSteps: 6,
+		GapSteps:      2,
+	}
+	controller, err := newSignalController(signal, 0)
+	if err != nil {
+		t.Fatalf("new controller: %v", err)
+	}
+
+	crossing := ApproachState{Vehicles: 1, Queued: 1, Detected: 1}
+	tests := []struct {
+		name       string
+		phaseSteps int
+		approaches map[Direction]ApproachState
+		want       SignalPhase
+	}{
+		{
+			name:       "holds min green",
+			phaseSteps: 0,
+			approaches: map[Direction]ApproachState{Up: {SinceDetection: 5}, Left: crossing},
+			want:       PhaseVerticalGreen,
+		},
+		{
+			name:       "extends while detectors are occupied",
+			phaseSteps: 2,
+			approaches: map[Direction]ApproachState{Up: {Vehicles: 2, Detected: 1}, Left: crossing},
+			want:       PhaseVerticalGreen,
+		},
+		{
+			name:       "gaps out",
+			phaseSteps: 2,
+			approaches: map[Direction]ApproachState{Up: {SinceDetection: 2}, Down: {SinceDetection: 3}, Left: crossing},
+			want:       PhaseVerticalYellow,
+		},
+		{
+			name:       "maxes out",
+			phaseSteps: 5,
+			approaches: map[Direction]ApproachState{Up: {Vehicles: 4, Detected: 2}, Left: crossing},
+			want:       PhaseVerticalYellow,
+		},
+		{
+			name:       "rests without crossing demand",
+			phaseSteps: 9,
+			approaches: map[Direction]ApproachState{Up: {SinceDetection: 9}},
+			want:       PhaseVerticalGreen,
+		},
+		{
+			name:       "serves lane backlog",
+			phaseSteps: 3,
+			approaches: map[Direction]ApproachState{Up: {SinceDetection: 9}, Right: {LaneQueue: 3}},
+			want:       PhaseVerticalYellow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			light := newTrafficLight("center")
+			light.PhaseSteps = tt.phaseSteps
+			got := controller.NextPhase(SignalState{Light: light, Approaches: tt.approaches})
+			if got != tt.want {
+				t.Fatalf("NextPhase = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdvancePhaseRunsClearanceToCrossingGreen(t *testing.T) {
+	signal := SignalConfig{YellowSteps: 2, AllRedSteps: 1}
+	light := newTrafficLight("center")
+
+	want := []SignalPhase{PhaseVerticalYellow, PhaseVerticalYellow, PhaseAllRed, PhaseHorizontalGreen}
+	for i, phase := range want {
+		got := advancePhase(light, signal, false)
+		if got != phase {
+			t.Fatalf("transition %d: phase = %s, want %s", i+1, got, phase)
+		}
+		light.setPhase(got)
+	}
+}
+
+func TestActuatedEngineServesOnlyApproachWithDemand(t *testing.T) {
+	cfg := Config{
+		Name:  "actuated",
+		Steps: 40,
+		Grid:  GridConfig{Width: 20, Height: 10},
+		Signal: SignalConfig{
+			Controller:           ControllerActuated,
+			VerticalGreenSteps:   4,
+			HorizontalGreenSteps: 4,
+			YellowSteps:          1,
+			MinGreenSteps:        2,
+		},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Right: {EntryX: 0, EntryY: 5, StepInterval: 2},
+			},
+		},
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	report := engine.Run(true, boolPtr(false))
+
+	last := report.Timeline[len(report.Timeline)-1]
+	if last.Phase != PhaseHorizontalGreen {
+		t.Fatalf("final phase = %s, want horizontal_green", last.Phase)
+	}
+	if report.Metrics.BlockedBySignal > 5 {
+		t.Fatalf("blocked_by_signal = %d, want the green to rest on the only approach", report.Metrics.BlockedBySignal)
+	}
+}
+
+type stubController struct {
+	phase SignalPhase
+	calls int
+}
+
+func (c *stubController) NextPhase(state SignalState) SignalPhase {
+	c.calls++
+	return c.phase
+}
+
+func TestSetControllerOverridesSignal(t *testing.T) {
+	engine := turnTestEngine(t, PhaseVerticalGreen)
+	stub := &stubController{phase: PhaseHorizontalGreen}
+	if err := engine.SetController("center", stub); err != nil {
+		t.Fatalf("set controller: %v", err)
+	}
+	if err := engine.SetController("missing", stub); err == nil {
+		t.Fatalf("expected error for unknown intersection")
+	}
+
+	engine.updateLights(0)
+	if stub.calls != 1 {
+		t.Fatalf("controller calls = %d, want 1", stub.calls)
+	}
+	if got := engine.intersections[0].light.Phase; got != PhaseHorizontalGreen {
+		t.Fatalf("phase = %s, want horizontal_green", got)
+	}
+}
+
+func TestMaxPressureControllerServesLargestPressure(t *testing.T) {
+	controller, err := newSignalController(SignalConfig{Controller: ControllerMaxPressure, MinGreenSteps: 2}, 0)
+	if err != nil {
+		t.Fatalf("new controller: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		phaseSteps int
+		approaches map[Direction]ApproachState
+		want       SignalPhase
+	}{
+		{
+			name:       "holds min green",
+			phaseSteps: 0,
+			approaches: map[Direction]ApproachState{Left: {Vehicles: 9}},
+			want:       PhaseVerticalGreen,
+		},
+		{
+			name:       "switches to heavier axis",
+			phaseSteps: 1,
+			approaches: map[Direction]ApproachState{Up: {Vehicles: 2}, Left: {Vehicles: 2, LaneQueue: 2}},
+			want:       PhaseHorizontalGreen,
+		},
+		{
+			name:       "blocked downstream lowers pressure",
+			phaseSteps: 1,
+			approaches: map[Direction]ApproachState{Up: {Vehicles: 3}, Left: {Vehicles: 5, Downstream: 4}},
+			want:       PhaseVerticalGreen,
+		},
+		{
+			name:       "ties keep current green",
+			phaseSteps: 4,
+			approaches: map[Direction]ApproachState{Up: {Vehicles: 2}, Right: {Vehicles: 2}},
+			want:       PhaseVerticalGreen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			light := newTrafficLight("center")
+			light.PhaseSteps = tt.phaseSteps
+			got := controller.NextPhase(SignalState{Light: light, Approaches: tt.approaches})
+			if got != tt.want {
+				t.Fatalf("NextPhase = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhaseSwitchesAndLostTime(t *testing.T) {
+	cfg := Config{
+		Name:  "lost-time",
+		Steps: 10,
+		Grid:  GridConfig{Width: 20, Height: 10},
+		Signal: SignalConfig{
+			VerticalGreenSteps:   2,
+			HorizontalGreenSteps: 2,
+			YellowSteps:          1,
+			AllRedSteps:          1,
+		},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Up: {EntryX: 10, EntryY: 9},
+			},
+		},
+	}
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	m := engine.Run(false, boolPtr(false)).Metrics
+
+	// Ten steps cover one full cycle: two greens switch in and, with no
+	// traffic, all four clearance steps are lost.
+	if m.PhaseSwitches != 2 {
+		t.Fatalf("phase_switches = %d, want 2", m.PhaseSwitches)
+	}
+	if m.LostTimeSteps != 4 {
+		t.Fatalf("lost_time_steps = %d, want 4", m.LostTimeSteps)
+	}
+	if got := m.IntersectionStats["center"].LostTimeSteps; got != 4 {
+		t.Fatalf("center lost_time_steps = %d, want 4", got)
+	}
+}
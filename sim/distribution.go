@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"math"
+	"sort"
+)
+
+// Distribution summarizes the wait or trip steps of completed vehicles.
+// Percentiles use the nearest-rank method, so each is a value some vehicle
+// actually had. Histogram buckets count values in From..To-1; the last
+// bucket has no upper bound and omits To.
+type Distribution struct {
+	P50       int               `json:"p50"`
+	P90       int               `json:"p90"`
+	P95       int               `json:"p95"`
+	P99       int               `json:"p99"`
+	Max       int               `json:"max"`
+	Histogram []HistogramBucket `json:"histogram"`
+}
+
+type HistogramBucket struct {
+	From  int `json:"from"`
+	To    int `json:"to,omitempty"`
+	Count int `json:"count"`
+}
+
+// histogramEdges are the lower bounds of the histogram buckets in steps.
+// They are fixed so histograms of different runs line up.
+var histogramEdges = []int{0, 1, 2, 5, 10, 20, 30, 60, 120}
+
+func newDistribution(values []int) Distribution {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	d := Distribution{Histogram: make([]HistogramBucket, len(histogramEdges))}
+	for i, from := range histogramEdges {
+		d.Histogram[i].From = from
+		if i+1 < len(histogramEdges) {
+			d.Histogram[i].To = histogramEdges[i+1]
+		}
+	}
+	if len(sorted) == 0 {
+		return d
+	}
+
+	d.P50 = nearestRank(sorted, 50)
+	d.P90 = nearestRank(sorted, 90)
+	d.P95 = nearestRank(sorted, 95)
+	d.P99 = nearestRank(sorted, 99)
+	d.Max = sorted[len(sorted)-1]
+	bucket := 0
+	for _, v := range sorted {
+		for bucket+1 < len(histogramEdges) && v >= histogramEdges[bucket+1] {
+			bucket++
+		}
+		d.Histogram[bucket].Count++
+	}
+	return d
+}
+
+// nearestRank returns the smallest value of sorted that at least p percent
+// of the values do not exceed.
+func nearestRank(sorted []int, p float64) int {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
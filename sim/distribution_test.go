@@ -0,0 +1,75 @@
+package sim
+
+import "testing"
+
+func TestDistributionUsesNearestRank(t *testing.T) {
+	values := make([]int, 0, 100)
+	for i := 100; i >= 1; i-- {
+		values = append(values, i)
+	}
+	d := newDistribution(values)
+	if d.P50 != 50 || d.P90 != 90 || d.P95 != 95 || d.P99 != 99 || d.Max != 100 {
+		t.Fatalf("got p50=%d p90=%d p95=%d p99=%d max=%d", d.P50, d.P90, d.P95, d.P99, d.Max)
+	}
+
+	small := newDistribution([]int{0, 3, 7})
+	if small.P50 != 3 || small.P99 != 7 {
+		t.Fatalf("got p50=%d p99=%d, want 3 and 7", small.P50, small.P99)
+	}
+	counts := map[int]int{}
+	for _, b := range small.Histogram {
+		counts[b.From] = b.Count
+	}
+	if counts[0] != 1 || counts[2] != 1 || counts[5] != 1 {
+		t.Fatalf("unexpected buckets: %+v", small.Histogram)
+	}
+	if last := small.Histogram[len(small.Histogram)-1]; last.From != 120 || last.To != 0 {
+		t.Fatalf("last bucket = %+v, want open bucket from 120", last)
+	}
+
+	if empty := newDistribution(nil); empty.Max != 0 || len(empty.Histogram) != len(histogramEdges) {
+		t.Fatalf("empty distribution = %+v", empty)
+	}
+}
+
+func TestDistributionsCoverCompletedVehicles(t *testing.T) {
+	engine, err := NewEngine(stepTestConfig())
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	m := engine.Run(false, boolPtr(false)).Metrics
+	if m.VehiclesCompleted == 0 {
+		t.Fatalf("expected completed vehicles")
+	}
+
+	for name, d := range map[string]Distribution{"wait": m.WaitDistribution, "trip": m.TripDistribution} {
+		total := 0
+		for _, b := range d.Histogram {
+			total += b.Count
+		}
+		if total != m.VehiclesCompleted {
+			t.Fatalf("%s histogram counts %d vehicles, want %d", name, total, m.VehiclesCompleted)
+		}
+		if d.P50 > d.P90 || d.P90 > d.P95 || d.P95 > d.P99 || d.P99 > d.Max {
+			t.Fatalf("%s percentiles are not ordered: %+v", name, d)
+		}
+	}
+	if float64(m.TripDistribution.Max) < m.AverageTripDuration {
+		t.Fatalf("max trip %d below average %.2f", m.TripDistribution.Max, m.AverageTripDuration)
+	}
+
+	dirCompleted := 0
+	for dir, s := range m.DirectionStats {
+		total := 0
+		for _, b := range s.WaitDistribution.Histogram {
+			total += b.Count
+		}
+		if total != s.Completed {
+			t.Fatalf("%s wait histogram counts %d vehicles, want %d", dir, total, s.Completed)
+		}
+		dirCompleted += total
+	}
+	if dirCompleted != m.VehiclesCompleted {
+		t.Fatalf("direction histograms count %d vehicles, want %d", dirCompleted, m.VehiclesCompleted)
+	}
+}
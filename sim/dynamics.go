@@ -0,0 +1,377 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+)
+
+// Dynamics models.
+const (
+	DynamicsUnit     = "unit"
+	DynamicsCellular = "cellular"
+	DynamicsIDM      = "idm"
+)
+
+// DynamicsConfig selects how vehicles pick their speed. The unit model, the
+// default, moves a vehicle at most one cell per step and stops it within a
+// step. The cellular model lets classes reach several cells per step, limits
+// how fast they speed up and slow down and keeps every vehicle slow enough to
+// stop behind the one ahead, or at a red stop line, at its class deceleration.
+// The idm model follows the Intelligent Driver Model on continuous positions
+// along the lanes, with the class speeds as desired speeds.
+//
+// CellMeters and StepSeconds give cells and steps a physical size, which the
+// idm model needs for its headway and minimum gap and reports use for times
+// in seconds. They default to 7.5 m and 1 s.
+type DynamicsConfig struct {
+	Model       string    `json:"model,omitempty"`
+	CellMeters  float64   `json:"cell_meters,omitempty"`
+	StepSeconds float64   `json:"step_seconds,omitempty"`
+	IDM         IDMConfig `json:"idm,omitempty"`
+}
+
+// IDMConfig holds the Intelligent Driver Model parameters shared by all
+// classes: the desired time headway in seconds, the gap kept to a stopped
+// leader in meters and the acceleration exponent. They default to 1.5 s,
+// 2 m and 4. Desired speed, maximum acceleration and comfortable
+// deceleration come from the vehicle class. A cell holds a vehicle together
+// with the gap it keeps when standing, so the gap the model sees is the free
+// distance ahead plus MinGap and stopped vehicles queue one per cell.
+type IDMConfig struct {
+	TimeHeadway float64 `json:"time_headway,omitempty"`
+	MinGap      float64 `json:"min_gap,omitempty"`
+	Delta       float64 `json:"delta,omitempty"`
+}
+
+func dynamicsModel(d DynamicsConfig) string {
+	if d.Model == "" {
+		return DynamicsUnit
+	}
+	return d.Model
+}
+
+func validateDynamics(d DynamicsConfig) error {
+	switch dynamicsModel(d) {
+	case DynamicsUnit, DynamicsCellular, DynamicsIDM:
+	default:
+		return fmt.Errorf("unsupported dynamics model %q", d.Model)
+	}
+	if d.CellMeters < 0 || d.StepSeconds < 0 {
+		return fmt.Errorf("dynamics cell_meters and step_seconds must be >= 0")
+	}
+	if d.IDM.TimeHeadway < 0 || d.IDM.MinGap < 0 || d.IDM.Delta < 0 {
+		return fmt.Errorf("idm time_headway, min_gap and delta must be >= 0")
+	}
+	return nil
+}
+
+// DynamicsModel returns the dynamics model of the config, with the default
+// filled in.
+func (c Config) DynamicsModel() string {
+	return dynamicsModel(c.Dynamics)
+}
+
+// Units returns the length of a cell in meters and of a step in seconds.
+func (c Config) Units() (cellMeters, stepSeconds float64) {
+	cellMeters, stepSeconds = c.Dynamics.CellMeters, c.Dynamics.StepSeconds
+	if cellMeters == 0 {
+		cellMeters = 7.5
+	}
+	if stepSeconds == 0 {
+		stepSeconds = 1
+	}
+	return cellMeters, stepSeconds
+}
+
+// ResolvedClasses returns the vehicle classes of the config merged with the
+// built-in ones.
+func (c Config) ResolvedClasses() map[string]VehicleClassConfig {
+	return vehicleClasses(c)
+}
+
+// idmParams are the IDM parameters in cells and steps.
+type idmParams struct {
+	headway float64
+	minGap  float64
+	delta   float64
+}
+
+func (c Config) idm() idmParams {
+	cellMeters, stepSeconds := c.Units()
+	p := idmParams{headway: 1.5, minGap: 2, delta: 4}
+	if c.Dynamics.IDM.TimeHeadway > 0 {
+		p.headway = c.Dynamics.IDM.TimeHeadway
+	}
+	if c.Dynamics.IDM.MinGap > 0 {
+		p.minGap = c.Dynamics.IDM.MinGap
+	}
+	if c.Dynamics.IDM.Delta > 0 {
+		p.delta = c.Dynamics.IDM.Delta
+	}
+	p.headway /= stepSeconds
+	p.minGap /= cellMeters
+	return p
+}
+
+// accel returns the IDM acceleration of a vehicle at speed, gap cells behind
+// a leader it closes in on by approach cells per step, or on a free road
+// when there is no leader.
+func (p idmParams) accel(class VehicleClassConfig, speed float64, leader bool, gap, approach float64) float64 {
+	free := 1 - math.Pow(speed/class.MaxSpeed, p.delta)
+	if !leader {
+		return class.Acceleration * free
+	}
+	desired := p.minGap + max(0, speed*p.headway+speed*approach/(2*math.Sqrt(class.Acceleration*class.Deceleration)))
+	gap = max(gap, progressEpsilon)
+	return class.Acceleration * (free - (desired/gap)*(desired/gap))
+}
+
+// motion is what a vehicle plans to do in a step: the speed it ends the step
+// with, the distance it covers, the cells that distance carries it and,
+// when it cannot move at all, why.
+type motion struct {
+	speed   float64
+	advance float64
+	cells   int
+	reason  BlockReason
+}
+
+// obstacle is what a vehicle sees ahead of it: the free cells up to the next
+// thing it has to stop for and, for a vehicle, its speed, deceleration and
+// the progress it already made towards its next cell.
+type obstacle struct {
+	found     bool
+	gap       int
+	speed     float64
+	decel     float64
+	progress  float64
+	reason    BlockReason
+	committed bool
+}
+
+// gapFrom returns the distance from the front of v to the obstacle, counting
+// the progress both made within their cells.
+func (o obstacle) gapFrom(v Vehicle) float64 {
+	return float64(o.gap) + o.progress - v.Progress
+}
+
+// travel returns how far the obstacle may still move if it brakes at once.
+func (o obstacle) travel() float64 {
+	return o.progress + brakingDistance(o.speed, o.decel)
+}
+
+// boxUse is a vehicle, or a segment of one, inside an intersection box.
+type boxUse struct {
+	vehicle  int
+	heading  Direction
+	movement Movement
+}
+
+// prepareDynamics indexes where vehicles are at the start of the moves of a
+// step, which the cellular and idm models look ahead into.
+func (e *Engine) prepareDynamics() {
+	e.committed = map[int]bool{}
+	if dynamicsModel(e.cfg.Dynamics) != DynamicsUnit {
+		e.indexVehicles()
+	}
+}
+
+func (e *Engine) indexVehicles() {
+	e.occupied = map[slot]int{}
+	e.boxUses = map[int][]boxUse{}
+	for i, v := range e.vehicles {
+		for _, s := range e.slots(v) {
+			e.occupied[s] = i
+		}
+		if idx, ok := e.intersectionAt[cell{x: v.X, y: v.Y}]; ok {
+			e.boxUses[idx] = append(e.boxUses[idx], boxUse{vehicle: v.ID, heading: v.CurrentHeading(), movement: v.pendingMovement()})
+		}
+		for j, s := range v.Tail {
+			if idx, ok := e.intersectionAt[cell{x: s.X, y: s.Y}]; ok {
+				e.boxUses[idx] = append(e.boxUses[idx], boxUse{vehicle: v.ID, heading: s.Heading, movement: segmentMovement(v, j)})
+			}
+		}
+	}
+}
+
+// nextMotion plans the step of a vehicle as if the cell moves go as planned.
+func (e *Engine) nextMotion(v Vehicle) motion {
+	class := e.class(v)
+	speed := min(class.MaxSpeed, v.Speed+class.Acceleration)
+	switch dynamicsModel(e.cfg.Dynamics) {
+	case DynamicsUnit:
+		if v.Progress+speed >= 1-progressEpsilon {
+			return motion{speed: speed, advance: speed, cells: 1}
+		}
+		return motion{speed: speed, advance: speed}
+	case DynamicsIDM:
+		return e.idmMotion(v, class)
+	}
+
+	ahead := e.obstacleAhead(v, class)
+	if ahead.committed {
+		e.committed[v.ID] = true
+	}
+	if ahead.found {
+		speed = min(speed, safeSpeed(float64(ahead.gap)+ahead.travel()-v.Progress, class.Deceleration))
+	}
+	if speed < progressEpsilon {
+		return motion{reason: ahead.reason}
+	}
+	return motion{speed: speed, advance: speed, cells: int(math.Floor(v.Progress + speed + progressEpsilon)), reason: ahead.reason}
+}
+
+// idmMotion integrates the IDM acceleration over one step. A vehicle never
+// covers more than the gap in front of it, so it cannot run into the rear of
+// a leader that stops dead.
+func (e *Engine) idmMotion(v Vehicle, class VehicleClassConfig) motion {
+	ahead := e.obstacleAhead(v, class)
+	if ahead.committed {
+		e.committed[v.ID] = true
+	}
+	gap := ahead.gapFrom(v)
+	p := e.cfg.idm()
+	accel := p.accel(class, v.Speed, ahead.found, gap+p.minGap, v.Speed-ahead.speed)
+
+	speed := v.Speed + accel
+	advance := v.Speed + accel/2
+	if speed < 0 {
+		speed = 0
+		advance = v.Speed * v.Speed / (-2 * accel)
+	}
+	if ahead.found && advance > gap {
+		advance = max(gap, 0)
+		speed = min(speed, advance)
+	}
+	if advance < progressEpsilon {
+		return motion{reason: ahead.reason}
+	}
+	return motion{speed: speed, advance: advance, cells: int(math.Floor(v.Progress + advance + progressEpsilon)), reason: ahead.reason}
+}
+
+// entrySpeed is the speed a vehicle enters the grid with. Vehicles arrive
+// from upstream at full speed, unless the cellular or idm model has them
+// slow down for what is ahead of the entry.
+func (e *Engine) entrySpeed(v Vehicle) float64 {
+	class := e.class(v)
+	if dynamicsModel(e.cfg.Dynamics) == DynamicsUnit {
+		return class.MaxSpeed
+	}
+	e.indexVehicles()
+	v.Speed = class.MaxSpeed
+	ahead := e.obstacleAhead(v, class)
+	if !ahead.found {
+		return class.MaxSpeed
+	}
+	return min(class.MaxSpeed, safeSpeed(float64(ahead.gap)+ahead.travel(), class.Deceleration))
+}
+
+// stoppingDistance returns the distance a vehicle needs to stop from speed
+// at its comfortable deceleration.
+func (e *Engine) stoppingDistance(speed float64, class VehicleClassConfig) float64 {
+	if dynamicsModel(e.cfg.Dynamics) == DynamicsIDM {
+		return speed * speed / (2 * class.Deceleration)
+	}
+	return brakingDistance(speed, class.Deceleration)
+}
+
+// obstacleAhead follows the path of a vehicle as far as it could need to
+// stop and returns the first vehicle, red or yellow stop line, occupied
+// crosswalk or lane end on it, which includes an intersection held by a
+// conflicting movement. A
+// vehicle too close to stop at a yellow stop line is committed and looks
+// past it.
+func (e *Engine) obstacleAhead(v Vehicle, class VehicleClassConfig) obstacle {
+	horizon := int(math.Ceil(class.MaxSpeed+e.stoppingDistance(class.MaxSpeed, class))) + 1
+	if dynamicsModel(e.cfg.Dynamics) == DynamicsIDM {
+		p := e.cfg.idm()
+		horizon += int(math.Ceil(p.minGap + class.MaxSpeed*p.headway))
+	}
+	committed := false
+	w := v
+	for gap := 0; gap < horizon; gap++ {
+		x, y, heading := e.ahead(w)
+		if e.leavesNetwork(w.X, w.Y, x, y, heading) {
+			break
+		}
+		_, inBox := e.intersectionAt[cell{x: w.X, y: w.Y}]
+		idx, nextIsBox := e.intersectionAt[cell{x: x, y: y}]
+		lane := w.RoadLane
+		if inBox {
+			lane = e.exitLane(w, x, y, heading)
+		} else if !e.laneContinues(w, x, y, nextIsBox) {
+			return obstacle{found: true, gap: gap, reason: BlockedByTraffic, committed: committed}
+		}
+		if nextIsBox {
+			light := e.intersections[idx].light
+			switch {
+			case light.green(heading):
+			case light.yellow(heading) && e.stoppingDistance(v.Speed, class) > float64(gap)-v.Progress+progressEpsilon:
+				committed = true
+			default:
+				return obstacle{found: true, gap: gap, reason: BlockedBySignal, committed: committed}
+			}
+			for _, use := range e.boxUses[idx] {
+				if use.vehicle != v.ID && movementsConflict(w.CurrentHeading(), w.pendingMovement(), use.heading, use.movement) {
+					return obstacle{found: true, gap: gap, reason: BlockedByTraffic, committed: committed}
+				}
+			}
+		}
+		if e.pedestriansCrossing(w.X, w.Y, x, y, heading) {
+			return obstacle{found: true, gap: gap, reason: BlockedByPedestrians, committed: committed}
+		}
+		next := e.slotAt(x, y, heading, lane)
+		if k, held := e.occupied[next]; held && e.vehicles[k].ID != v.ID {
+			leader := e.vehicles[k]
+			return obstacle{
+				found:     true,
+				gap:       gap,
+				speed:     leader.Speed,
+				decel:     e.class(leader).Deceleration,
+				progress:  leader.Progress,
+				reason:    BlockedByTraffic,
+				committed: committed,
+			}
+		}
+		if e.merging[next] {
+			return obstacle{found: true, gap: gap, reason: BlockedByTraffic, committed: committed}
+		}
+		w.X, w.Y, w.Heading, w.RoadLane = x, y, heading, lane
+	}
+	return obstacle{committed: committed}
+}
+
+// mayEnterOnYellow reports whether a vehicle at the stop line may still
+// enter an intersection whose light shows yellow.
+func (e *Engine) mayEnterOnYellow(v Vehicle, light TrafficLight, step int) bool {
+	if dynamicsModel(e.cfg.Dynamics) == DynamicsUnit {
+		return committedOnYellow(v, light, step)
+	}
+	return e.committed[v.ID]
+}
+
+// brakingDistance returns the cells a vehicle at speed covers when it slows
+// down by decel every step from the next one on until it stands.
+func brakingDistance(speed, decel float64) float64 {
+	distance := 0.0
+	for s := speed - decel; s > progressEpsilon; s -= decel {
+		distance += s
+	}
+	return distance
+}
+
+// safeSpeed returns the highest speed from which a vehicle that covers it
+// this step and then brakes by decel per step stays within allowed cells.
+// A speed s with (m-1)*decel < s <= m*decel covers m*s - decel*m*(m-1)/2
+// cells in total, which gives s for the first m where it falls in range.
+func safeSpeed(allowed, decel float64) float64 {
+	if allowed <= 0 {
+		return 0
+	}
+	for m := 1; ; m++ {
+		s := (allowed + decel*float64(m*(m-1))/2) / float64(m)
+		if s <= float64(m)*decel+progressEpsilon {
+			return s
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package sim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cellObserver records the most cells a vehicle covered in one step.
+type cellObserver struct {
+	NopObserver
+	most int
+}
+
+func (o *cellObserver) OnMove(_ int, move Move) {
+	o.most = max(o.most, move.Cells)
+}
+
+func TestCellularVehiclesCoverSeveralCellsPerStep(t *testing.T) {
+	durations := map[string]float64{}
+	for _, model := range []string{DynamicsUnit, DynamicsCellular} {
+		cfg := classTestConfig(nil)
+		cfg.Dynamics.Model = model
+		if model == DynamicsCellular {
+			cfg.VehicleClasses = map[string]VehicleClassConfig{
+				"car": {MaxSpeed: 3, Acceleration: 1, Deceleration: 2},
+			}
+		}
+		engine, err := NewEngine(cfg)
+		if err != nil {
+			t.Fatalf("new engine: %v", err)
+		}
+		obs := &cellObserver{}
+		engine.AddObserver(obs)
+		stepChecked(t, engine)
+		m := engine.Finalize().Metrics
+		if m.VehiclesCompleted != 6 || m.PotentialCollisions != 0 {
+			t.Fatalf("%s: completed %d trips with %d potential collisions", model, m.VehiclesCompleted, m.PotentialCollisions)
+		}
+		if want := map[string]int{DynamicsUnit: 1, DynamicsCellular: 3}[model]; obs.most != want {
+			t.Fatalf("%s: vehicles covered up to %d cells per step, want %d", model, obs.most, want)
+		}
+		durations[model] = m.ClassStats[DefaultClass].AverageDuration
+	}
+	if durations[DynamicsCellular] >= durations[DynamicsUnit] {
+		t.Fatalf("average trip = %v, want cellular cars faster", durations)
+	}
+}
+
+func TestCellularVehiclesBrakeWithinTheirDeceleration(t *testing.T) {
+	cfg, err := LoadConfig("../configs/cellular.json")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+
+	speeds := map[int]float64{}
+	fastest := 0.0
+	for !engine.Done() {
+		if err := engine.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		seen := map[slot]int{}
+		for _, v := range engine.State().Vehicles {
+			for _, s := range engine.slots(v) {
+				if other, ok := seen[s]; ok {
+					t.Fatalf("step %d: vehicles %d and %d share %+v", engine.step, other, v.ID, s)
+				}
+				seen[s] = v.ID
+			}
+			if before, ok := speeds[v.ID]; ok && before-v.Speed > engine.class(v).Deceleration+progressEpsilon {
+				t.Fatalf("step %d: %s %d braked from %.2f to %.2f", engine.step, v.Class, v.ID, before, v.Speed)
+			}
+			speeds[v.ID] = v.Speed
+			fastest = max(fastest, v.Speed)
+		}
+	}
+	if fastest != 3 {
+		t.Fatalf("fastest vehicle reached %.2f cells per step, want 3", fastest)
+	}
+	m := engine.Finalize().Metrics
+	if m.PotentialCollisions != 0 || m.ClearanceConflicts != 0 {
+		t.Fatalf("potential collisions = %d, clearance conflicts = %d", m.PotentialCollisions, m.ClearanceConflicts)
+	}
+}
+
+func TestIDMVehiclesFollowOnContinuousPositions(t *testing.T) {
+	cfg, err := LoadConfig("../configs/idm.json")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+
+	fastest := 0.0
+	fractional := false
+	for !engine.Done() {
+		if err := engine.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		seen := map[slot]int{}
+		for _, v := range engine.State().Vehicles {
+			for _, s := range engine.slots(v) {
+				if other, ok := seen[s]; ok {
+					t.Fatalf("step %d: vehicles %d and %d share %+v", engine.step, other, v.ID, s)
+				}
+				seen[s] = v.ID
+			}
+			if v.Speed > engine.class(v).MaxSpeed+progressEpsilon {
+				t.Fatalf("step %d: %s %d drives %.2f cells per step", engine.step, v.Class, v.ID, v.Speed)
+			}
+			fastest = max(fastest, v.Speed)
+			fractional = fractional || (v.Progress > progressEpsilon && v.Speed > 1)
+		}
+	}
+	if fastest < 1.8 {
+		t.Fatalf("fastest vehicle reached %.2f cells per step, want close to 2", fastest)
+	}
+	if !fractional {
+		t.Fatal("expected fast vehicles between cells")
+	}
+	m := engine.Finalize().Metrics
+	if m.VehiclesCompleted == 0 || m.PotentialCollisions != 0 || m.ClearanceConflicts != 0 {
+		t.Fatalf("completed = %d, potential collisions = %d, clearance conflicts = %d", m.VehiclesCompleted, m.PotentialCollisions, m.ClearanceConflicts)
+	}
+}
+
+func TestIDMKeepsTheDesiredGapBehindALeader(t *testing.T) {
+	p := Config{}.idm()
+	class := VehicleClassConfig{MaxSpeed: 2, Acceleration: 0.2, Deceleration: 0.27}
+	if a := p.accel(class, 0, false, 0, 0); a != class.Acceleration {
+		t.Fatalf("free road acceleration from standstill = %.3f, want %.3f", a, class.Acceleration)
+	}
+	if a := p.accel(class, 1, true, 1, 1); a >= -class.Deceleration {
+		t.Fatalf("closing in fast on a near leader accelerates %.3f, want hard braking", a)
+	}
+	if a := p.accel(class, 1, true, 20, 0); a <= 0 {
+		t.Fatalf("far behind a leader accelerates %.3f, want speeding up", a)
+	}
+}
+
+func TestSafeSpeedStopsWithinTheAllowedDistance(t *testing.T) {
+	for _, decel := range []float64{0.5, 1, 2} {
+		for allowed := 0.0; allowed <= 10; allowed += 0.25 {
+			s := safeSpeed(allowed, decel)
+			if d := s + brakingDistance(s, decel); d > allowed+progressEpsilon {
+				t.Fatalf("safeSpeed(%.2f, %.1f) = %.3f covers %.3f cells", allowed, decel, s, d)
+			}
+			if faster := s + 0.01; faster+brakingDistance(faster, decel) <= allowed {
+				t.Fatalf("safeSpeed(%.2f, %.1f) = %.3f, but %.3f stops in time too", allowed, decel, s, faster)
+			}
+		}
+	}
+}
+
+func TestLoadConfigRejectsFastClassesWithUnitDynamics(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "invalid.json")
+	content := `{
+		"grid": { "width": 20, "height": 10 },
+		"vehicle_classes": { "car": { "max_speed": 2 } },
+		"spawn": {
+			"lanes": { "right": { "entry_x": 0, "entry_y": 5, "step_interval": 2 } }
+		}
+	}`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := LoadConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), "at most 1 cell per step with unit dynamics") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content = strings.Replace(content, `"grid"`, `"dynamics": { "model": "idm", "idm": { "min_gap": -1 } }, "grid"`, 1)
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "min_gap") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content = strings.Replace(content, `"model": "idm", "idm": { "min_gap": -1 }`, `"model": "cellular"`, 1)
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(configPath); err != nil {
+		t.Fatalf("cellular config rejected: %v", err)
+	}
+}
@@ -0,0 +1,1238 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Vehicle is a vehicle on the grid. Lane is the spawn lane it entered from
+// and RoadLane the lane of the road it drives in, counted from the curb.
+//
+// X and Y are the cell of its front; a vehicle longer than one cell drags a
+// Tail of the cells behind it, which fills up as it enters the grid. Speed is
+// the cells it covers per step and Progress how far past its cell it has got
+// towards the next one.
+type Vehicle struct {
+	ID          int       `json:"id"`
+	X           int       `json:"x"`
+	Y           int       `json:"y"`
+	Direction   Direction `json:"direction"`
+	Heading     Direction `json:"heading,omitempty"`
+	Movement    Movement  `json:"movement,omitempty"`
+	Lane        string    `json:"lane,omitempty"`
+	RoadLane    int       `json:"road_lane,omitempty"`
+	Class       string    `json:"class,omitempty"`
+	Tail        []Segment `json:"tail,omitempty"`
+	Speed       float64   `json:"speed,omitempty"`
+	Progress    float64   `json:"progress,omitempty"`
+	SpawnStep   int       `json:"spawn_step"`
+	WaitSteps   int       `json:"wait_steps"`
+	MovedSteps  int       `json:"moved_steps"`
+	BlockedStep int       `json:"blocked_step"`
+}
+
+// CurrentHeading is the direction the vehicle is travelling in, which differs
+// from the lane direction once it has turned.
+func (v Vehicle) CurrentHeading() Direction {
+	if v.Heading == "" {
+		return v.Direction
+	}
+	return v.Heading
+}
+
+// pendingMovement is the movement the vehicle still has to perform at the
+// next intersection it crosses.
+func (v Vehicle) pendingMovement() Movement {
+	if v.Movement == "" || v.CurrentHeading() != v.Direction {
+		return Through
+	}
+	return v.Movement
+}
+
+type LaneState struct {
+	ID               string    `json:"id"`
+	Direction        Direction `json:"direction"`
+	EntryX           int       `json:"entry_x"`
+	EntryY           int       `json:"entry_y"`
+	Interval         int       `json:"interval"`
+	MaxVehicles      int       `json:"max_vehicles"`
+	Spawned          int       `json:"spawned"`
+	Queued           int       `json:"queued"`
+	MaxQueueObserved int       `json:"max_queue_observed"`
+	Turns            TurnRatio
+	MovementCounts   map[Movement]int
+	Classes          map[string]float64
+	ClassCounts      map[string]int
+	Profile          DemandProfile
+	Arrivals         ArrivalConfig
+	NextArrival      int
+	source           *rand.PCG
+	rng              *rand.Rand
+}
+
+type Metrics struct {
+	ScenarioName         string                 `json:"scenario_name"`
+	Steps                int                    `json:"steps"`
+	VehiclesSpawned      int                    `json:"vehicles_spawned"`
+	VehiclesCompleted    int                    `json:"vehicles_completed"`
+	ActiveVehicles       int                    `json:"active_vehicles"`
+	BlockedBySignal      int                    `json:"blocked_by_signal"`
+	BlockedByTraffic     int                    `json:"blocked_by_traffic"`
+	PotentialCollisions  int                    `json:"potential_collisions"`
+	YellowEntries        int                    `json:"yellow_entries"`
+	ClearanceConflicts   int                    `json:"clearance_conflicts"`
+	PhaseSwitches        int                    `json:"phase_switches"`
+	LostTimeSteps        int                    `json:"lost_time_steps"`
+	TotalDistance        int                    `json:"total_distance"`
+	AverageNetworkSpeed  float64                `json:"average_network_speed"`
+	AverageWaitPerTrip   float64                `json:"average_wait_per_trip"`
+	AverageTripDuration  float64                `json:"average_trip_duration"`
+	ThroughputPer100Step float64                `json:"throughput_per_100_steps"`
+	MaxQueueOverall      int                    `json:"max_queue_overall"`
+	LaneChanges          int                    `json:"lane_changes"`
+	MandatoryLaneChanges int                    `json:"mandatory_lane_changes"`
+	WaitDistribution     Distribution           `json:"wait_distribution"`
+	TripDistribution     Distribution           `json:"trip_distribution"`
+	DirectionStats       map[Direction]DirStats `json:"direction_stats"`
+	ClassStats           map[string]ClassStats  `json:"class_stats"`
+
+	IntersectionStats map[string]IntersectionStats `json:"intersection_stats"`
+	Pedestrians       *PedestrianStats             `json:"pedestrians,omitempty"`
+}
+
+type DirStats struct {
+	Spawned          int                        `json:"spawned"`
+	Completed        int                        `json:"completed"`
+	AverageWait      float64                    `json:"average_wait"`
+	AverageDuration  float64                    `json:"average_duration"`
+	MaxQueue         int                        `json:"max_queue"`
+	WaitDistribution Distribution               `json:"wait_distribution"`
+	TripDistribution Distribution               `json:"trip_distribution"`
+	Movements        map[Movement]MovementStats `json:"movements,omitempty"`
+	// RoadLanes is indexed by lane and only filled on multi-lane networks.
+	RoadLanes []RoadLaneStats `json:"road_lanes,omitempty"`
+}
+
+type MovementStats struct {
+	Spawned         int     `json:"spawned"`
+	Completed       int     `json:"completed"`
+	AverageWait     float64 `json:"average_wait"`
+	AverageDuration float64 `json:"average_duration"`
+}
+
+type IntersectionStats struct {
+	X                   int `json:"x"`
+	Y                   int `json:"y"`
+	VehiclesServed      int `json:"vehicles_served"`
+	BlockedBySignal     int `json:"blocked_by_signal"`
+	PotentialCollisions int `json:"potential_collisions"`
+	YellowEntries       int `json:"yellow_entries"`
+	ClearanceConflicts  int `json:"clearance_conflicts"`
+	PhaseSwitches       int `json:"phase_switches"`
+	LostTimeSteps       int `json:"lost_time_steps"`
+	MaxQueue            int `json:"max_queue"`
+}
+
+// StepSnapshot is the state after one step. Distance, the cells covered by all
+// vehicles so far, and the blocker, conflict and queue counters are the
+// dashboard's running totals at that step, so a replay can show them without
+// rerunning the scenario.
+type StepSnapshot struct {
+	Step                int               `json:"step"`
+	LightGreen          bool              `json:"light_green_vertical"`
+	Phase               SignalPhase       `json:"phase"`
+	Lights              []TrafficLight    `json:"lights,omitempty"`
+	Vehicles            []Vehicle         `json:"vehicles"`
+	Distance            int               `json:"distance"`
+	BlockedBySignal     int               `json:"blocked_by_signal"`
+	BlockedByTraffic    int               `json:"blocked_by_traffic"`
+	PotentialCollisions int               `json:"potential_collisions"`
+	MaxQueue            int               `json:"max_queue"`
+	LaneQueue           map[Direction]int `json:"lane_queue,omitempty"`
+}
+
+// Report is the result of a run. Config is only included together with a
+// timeline, so the timeline can be replayed on the same grid. Series is
+// filled when the config sets a series window and Trips after CaptureTrips.
+type Report struct {
+	ConfigName string         `json:"config_name"`
+	Seed       uint64         `json:"seed"`
+	Generated  time.Time      `json:"generated"`
+	Config     *Config        `json:"config,omitempty"`
+	Metrics    Metrics        `json:"metrics"`
+	Timeline   []StepSnapshot `json:"timeline,omitempty"`
+	Series     []SeriesSample `json:"series,omitempty"`
+	Trips      []Trip         `json:"trips,omitempty"`
+}
+
+type intersectionState struct {
+	id             string
+	x              int
+	y              int
+	signal         SignalConfig
+	controller     SignalController
+	light          TrafficLight
+	sinceDetection map[Direction]int
+}
+
+type movementKey struct {
+	dir      Direction
+	movement Movement
+}
+
+type roadLaneKey struct {
+	dir  Direction
+	lane int
+}
+
+type Engine struct {
+	cfg             Config
+	network         NetworkConfig
+	classes         map[string]VehicleClassConfig
+	multiLane       bool
+	merging         map[slot]bool
+	occupied        map[slot]int
+	boxUses         map[int][]boxUse
+	committed       map[int]bool
+	vehicles        []Vehicle
+	intersections   []*intersectionState
+	intersectionAt  map[cell]int
+	crosswalks      []*CrosswalkState
+	crosswalkAt     map[crosswalkKey]*CrosswalkState
+	laneStates      map[string]*LaneState
+	laneOrder       []string
+	nextVehicleID   int
+	stats           *metricsObserver
+	series          *seriesObserver
+	trips           *tripObserver
+	observers       []Observer
+	timeline        []StepSnapshot
+	captureTimeline bool
+	step            int
+}
+
+func NewEngine(cfg Config) (*Engine, error) {
+	return newEngine(cfg, LoadDemandProfile)
+}
+
+// newEngine builds an engine that reads demand profiles through loadProfile,
+// which lets a restored engine reuse the profiles stored in its checkpoint.
+func newEngine(cfg Config, loadProfile func(path, column string) (DemandProfile, error)) (*Engine, error) {
+	lanes := spawnLanes(cfg.Spawn)
+	laneStates := make(map[string]*LaneState, len(lanes))
+	laneOrder := make([]string, 0, len(lanes))
+	for _, lane := range lanes {
+		state := &LaneState{
+			ID:          lane.ID,
+			Direction:   lane.Direction,
+			EntryX:      lane.EntryX,
+			EntryY:      lane.EntryY,
+			Interval:    lane.StepInterval,
+			MaxVehicles: lane.MaxVehicles,
+			Turns:       lane.Turns,
+			Classes:     lane.Classes,
+			Profile:     DemandProfile{},
+			Arrivals:    lane.Arrivals,
+			source:      newLaneSource(cfg.Seed, lane.ID),
+		}
+		state.rng = rand.New(state.source)
+		if lane.ProfileCSV != "" {
+			column := lane.ProfileColumn
+			if column == "" {
+				column = lane.ID
+			}
+			profile, err := loadProfile(lane.ProfileCSV, column)
+			if err != nil {
+				return nil, fmt.Errorf("load demand profile for lane %q: %w", lane.ID, err)
+			}
+			state.Profile = profile
+		}
+		laneStates[lane.ID] = state
+		laneOrder = append(laneOrder, lane.ID)
+	}
+
+	network := resolveNetwork(cfg)
+	intersections := make([]*intersectionState, 0, len(network.Intersections))
+	intersectionAt := make(map[cell]int, len(network.Intersections))
+	for i, in := range network.Intersections {
+		signal := intersectionSignal(cfg.Signal, in)
+		controller, err := newSignalController(signal, in.Offset)
+		if err != nil {
+			return nil, fmt.Errorf("intersection %q: %w", in.ID, err)
+		}
+		intersections = append(intersections, &intersectionState{
+			id:             in.ID,
+			x:              in.X,
+			y:              in.Y,
+			signal:         signal,
+			controller:     controller,
+			light:          newTrafficLight(in.ID),
+			sinceDetection: map[Direction]int{},
+		})
+		intersectionAt[cell{x: in.X, y: in.Y}] = i
+	}
+	if len(intersections) == 0 {
+		return nil, fmt.Errorf("network has no intersections")
+	}
+
+	specs, err := resolveCrosswalks(cfg, network)
+	if err != nil {
+		return nil, err
+	}
+	stepsPerLane := cfg.Pedestrians.StepsPerLane
+	if stepsPerLane <= 0 {
+		stepsPerLane = 3
+	}
+	crosswalks := make([]*CrosswalkState, 0, len(specs))
+	crosswalkAt := make(map[crosswalkKey]*CrosswalkState, len(specs))
+	for _, spec := range specs {
+		c := &CrosswalkState{
+			ID:            spec.id,
+			Intersection:  intersections[spec.intersection].id,
+			Leg:           spec.leg,
+			Interval:      spec.StepInterval,
+			CrossingSteps: spec.lanes * stepsPerLane,
+			Signal:        DontWalk,
+			Profile:       DemandProfile{},
+			intersection:  spec.intersection,
+		}
+		if spec.ProfileCSV != "" {
+			column := spec.ProfileColumn
+			if column == "" {
+				column = string(spec.leg)
+			}
+			profile, err := loadProfile(spec.ProfileCSV, column)
+			if err != nil {
+				return nil, fmt.Errorf("load demand profile for crosswalk %q: %w", spec.id, err)
+			}
+			c.Profile = profile
+		}
+		crosswalks = append(crosswalks, c)
+		crosswalkAt[crosswalkKey{intersection: spec.intersection, leg: spec.leg}] = c
+	}
+
+	stats := newMetricsObserver(intersections)
+	e := &Engine{
+		cfg:            cfg,
+		network:        network,
+		classes:        vehicleClasses(cfg),
+		multiLane:      network.multiLane(),
+		intersections:  intersections,
+		intersectionAt: intersectionAt,
+		crosswalks:     crosswalks,
+		crosswalkAt:    crosswalkAt,
+		laneStates:     laneStates,
+		laneOrder:      laneOrder,
+		stats:          stats,
+		observers:      []Observer{stats},
+	}
+	if cfg.Series.Window > 0 {
+		e.series = newSeriesObserver(e, cfg.Series.Window)
+		e.observers = append(e.observers, e.series)
+	}
+	return e, nil
+}
+
+// Run steps the simulation to the end and returns the final report. It
+// continues from the current step when the engine was already advanced with
+// Step.
+func (e *Engine) Run(captureTimeline bool, renderOverride *bool) Report {
+	shouldRender := e.cfg.Render.Enabled
+	if renderOverride != nil {
+		shouldRender = *renderOverride
+	}
+	if captureTimeline {
+		e.CaptureTimeline()
+	}
+
+	for !e.Done() {
+		e.advance()
+
+		if shouldRender {
+			e.Render()
+			if e.cfg.Render.DelayMS > 0 {
+				time.Sleep(time.Duration(e.cfg.Render.DelayMS) * time.Millisecond)
+			}
+		}
+	}
+
+	return e.Finalize()
+}
+
+// Render draws the terminal dashboard for the steps run so far.
+func (e *Engine) Render() {
+	RenderGrid(e.cfg, e.vehicles, e.lights(), e.renderStats(e.step-1))
+}
+
+func (e *Engine) snapshot(step int) StepSnapshot {
+	copyVehicles := make([]Vehicle, len(e.vehicles))
+	copy(copyVehicles, e.vehicles)
+	return StepSnapshot{
+		Step:                step + 1,
+		LightGreen:          e.intersections[0].light.VerticalGreen,
+		Phase:               e.intersections[0].light.Phase,
+		Lights:              e.lights(),
+		Vehicles:            copyVehicles,
+		Distance:            e.stats.totalDistance,
+		BlockedBySignal:     e.stats.blockedSignal,
+		BlockedByTraffic:    e.stats.blockedTraffic,
+		PotentialCollisions: e.stats.potentialCrash,
+		MaxQueue:            e.maxQueueOverall(),
+		LaneQueue:           e.laneQueue(),
+	}
+}
+
+func (e *Engine) lights() []TrafficLight {
+	lights := make([]TrafficLight, len(e.intersections))
+	for i, in := range e.intersections {
+		lights[i] = in.light
+	}
+	return lights
+}
+
+func (e *Engine) renderStats(step int) RenderStats {
+	completed := e.stats.completed()
+
+	throughput := 0.0
+	if step+1 > 0 {
+		throughput = float64(completed) / float64(step+1) * 100
+	}
+	avgSpeed := 0.0
+	if e.stats.totalVehicleStep > 0 {
+		avgSpeed = float64(e.stats.totalDistance) / float64(e.stats.totalVehicleStep)
+	}
+
+	laneActive := map[Direction]int{
+		Up:    0,
+		Down:  0,
+		Left:  0,
+		Right: 0,
+	}
+	for _, v := range e.vehicles {
+		laneActive[v.Direction]++
+	}
+
+	return RenderStats{
+		ScenarioName:         e.cfg.Name,
+		Step:                 step + 1,
+		TotalSteps:           e.cfg.Steps,
+		VerticalGreen:        e.intersections[0].light.VerticalGreen,
+		Phase:                e.intersections[0].light.Phase,
+		SpawnedVehicles:      len(e.vehicles) + completed,
+		CompletedVehicles:    completed,
+		ActiveVehicles:       len(e.vehicles),
+		BlockedBySignal:      e.stats.blockedSignal,
+		BlockedByTraffic:     e.stats.blockedTraffic,
+		PotentialCollisions:  e.stats.potentialCrash,
+		MaxQueueOverall:      e.maxQueueOverall(),
+		AverageNetworkSpeed:  avgSpeed,
+		ThroughputPer100Step: throughput,
+		LaneQueue:            e.laneQueue(),
+		LaneActive:           laneActive,
+	}
+}
+
+// laneQueue returns the vehicles waiting to enter per lane direction.
+func (e *Engine) laneQueue() map[Direction]int {
+	laneQueue := map[Direction]int{
+		Up:    0,
+		Down:  0,
+		Left:  0,
+		Right: 0,
+	}
+	for _, lane := range e.laneStates {
+		laneQueue[lane.Direction] += lane.Queued
+	}
+	return laneQueue
+}
+
+func (e *Engine) spawnVehicles(step int) {
+	for _, id := range e.laneOrder {
+		lane := e.laneStates[id]
+		if newArrivals := e.arrivalsForStep(lane, step); newArrivals > 0 {
+			e.enqueue(lane, newArrivals)
+		}
+	}
+
+	for _, id := range e.laneOrder {
+		lane := e.laneStates[id]
+		for lane.Queued > 0 {
+			if lane.MaxVehicles > 0 && lane.Spawned >= lane.MaxVehicles {
+				lane.Queued = 0
+				break
+			}
+			free := e.freeLanes(lane.EntryX, lane.EntryY, lane.Direction)
+			if len(free) == 0 {
+				break
+			}
+			movement := lane.nextMovement()
+			class := lane.nextClass()
+			e.nextVehicleID++
+			v := Vehicle{
+				ID:        e.nextVehicleID,
+				X:         lane.EntryX,
+				Y:         lane.EntryY,
+				Direction: lane.Direction,
+				Heading:   lane.Direction,
+				Movement:  movement,
+				Lane:      lane.ID,
+				RoadLane:  free[0],
+				Class:     class,
+				SpawnStep: step + 1,
+			}
+			// Left turns enter as far towards the median as they can, every
+			// other movement as close to the curb.
+			if movement == TurnLeft {
+				v.RoadLane = free[len(free)-1]
+			}
+			v.Speed = e.entrySpeed(v)
+			e.vehicles = append(e.vehicles, v)
+			lane.Queued--
+			lane.Spawned++
+			e.emitSpawn(step+1, v)
+		}
+	}
+}
+
+func (e *Engine) enqueue(lane *LaneState, count int) {
+	lane.Queued += count
+	if lane.Queued > lane.MaxQueueObserved {
+		lane.MaxQueueObserved = lane.Queued
+	}
+}
+
+func (e *Engine) maxQueueOverall() int {
+	max := 0
+	for _, lane := range e.laneStates {
+		if lane.MaxQueueObserved > max {
+			max = lane.MaxQueueObserved
+		}
+	}
+	return max
+}
+
+// freeLanes returns the lanes of (x, y) in heading that no vehicle occupies,
+// from the curb outwards.
+func (e *Engine) freeLanes(x, y int, heading Direction) []int {
+	taken := map[slot]bool{}
+	for i := range e.vehicles {
+		for _, s := range e.slots(e.vehicles[i]) {
+			taken[s] = true
+		}
+	}
+	var free []int
+	for lane := range max(e.network.Lanes(x, y, heading), 1) {
+		if !taken[e.slotAt(x, y, heading, lane)] {
+			free = append(free, lane)
+		}
+	}
+	return free
+}
+
+// stepMove is what a vehicle did during the cell moves of one step.
+type stepMove struct {
+	cells        int
+	stopped      bool
+	exited       bool
+	blockedBy    BlockReason
+	fromX        int
+	fromY        int
+	intersection int
+	onYellow     bool
+}
+
+// moveVehicles advances every vehicle by the cells its speed carries it this
+// step. Vehicles move one cell at a time, all together, so the rules for
+// signals, conflicts and platoons apply to every cell; a vehicle that is
+// held back stops short. A vehicle enters at most one intersection per step.
+func (e *Engine) moveVehicles(step int) {
+	plans := make([]motion, len(e.vehicles))
+	e.prepareDynamics()
+	for i := range e.vehicles {
+		plans[i] = e.nextMotion(e.vehicles[i])
+	}
+
+	moves := make([]stepMove, len(e.vehicles))
+	for i, v := range e.vehicles {
+		moves[i] = stepMove{fromX: v.X, fromY: v.Y, intersection: -1}
+	}
+	for moved := 0; ; moved++ {
+		active := make([]bool, len(e.vehicles))
+		moving := false
+		for i := range e.vehicles {
+			m := &moves[i]
+			if m.exited || m.stopped || m.cells != moved || plans[i].cells <= moved {
+				continue
+			}
+			if m.intersection >= 0 && e.entersBox(e.vehicles[i]) {
+				m.stopped = true
+				continue
+			}
+			active[i] = true
+			moving = true
+		}
+		if !moving {
+			break
+		}
+		e.moveCell(step, active, moves)
+	}
+
+	nextVehicles := make([]Vehicle, 0, len(e.vehicles))
+	for i := range e.vehicles {
+		v := e.vehicles[i]
+		m := moves[i]
+		plan := plans[i]
+
+		switch {
+		case plan.cells == 0 && plan.advance > 0:
+			// Still working up to the next cell.
+			v.MovedSteps++
+			v.Speed = plan.speed
+			v.Progress += plan.advance
+			e.emitMove(step+1, Move{Vehicle: v, FromX: v.X, FromY: v.Y})
+		case m.cells > 0 || m.exited:
+			v.MovedSteps++
+			v.Speed = plan.speed
+			v.Progress = max(v.Progress+plan.advance-float64(plan.cells), 0)
+			if m.cells < plan.cells && !m.exited {
+				v.Speed, v.Progress = float64(m.cells), 0
+			}
+			if m.exited {
+				e.emitExit(step+1, v, (step+1)-v.SpawnStep+1, m.cells+1)
+				continue
+			}
+			move := Move{FromX: m.fromX, FromY: m.fromY, Cells: m.cells, Vehicle: v}
+			if m.intersection >= 0 {
+				move.Intersection = e.intersections[m.intersection].id
+				move.OnYellow = m.onYellow
+			}
+			e.emitMove(step+1, move)
+		default:
+			reason := m.blockedBy
+			if plan.cells == 0 {
+				reason = plan.reason
+			}
+			// Unit dynamics start over from the cell boundary, the other
+			// models keep the way a vehicle made towards the next cell.
+			v.Speed = 0
+			if dynamicsModel(e.cfg.Dynamics) == DynamicsUnit {
+				v.Progress = 0
+			}
+			v.WaitSteps++
+			v.BlockedStep = step + 1
+			intersection := ""
+			if idx, _ := e.approaching(v); idx >= 0 {
+				intersection = e.intersections[idx].id
+			}
+			e.emitBlocked(step+1, v, reason, intersection)
+		}
+
+		nextVehicles = append(nextVehicles, v)
+	}
+	e.vehicles = nextVehicles
+}
+
+// ahead returns the cell a vehicle moves to next and its heading there.
+func (e *Engine) ahead(v Vehicle) (int, int, Direction) {
+	heading := v.CurrentHeading()
+	if _, inBox := e.intersectionAt[cell{x: v.X, y: v.Y}]; inBox {
+		heading = turnHeading(heading, v.pendingMovement())
+	}
+	x, y := nextCell(v.X, v.Y, heading)
+	return x, y, heading
+}
+
+// entersBox reports whether the next cell of a vehicle is an intersection.
+func (e *Engine) entersBox(v Vehicle) bool {
+	x, y, _ := e.ahead(v)
+	_, ok := e.intersectionAt[cell{x: x, y: y}]
+	return ok
+}
+
+// moveCell moves the active vehicles by one cell where they can go. The
+// others stay where they are and only act as obstacles.
+func (e *Engine) moveCell(step int, active []bool, moves []stepMove) {
+	type movePlan struct {
+		canMove      bool
+		exitsGrid    bool
+		blockedBy    BlockReason
+		nextX        int
+		nextY        int
+		heading      Direction
+		lane         int
+		intersection int
+		onYellow     bool
+	}
+
+	plans := make([]movePlan, len(e.vehicles))
+	currentSlot := make([]slot, len(e.vehicles))
+	slotToVehicle := map[slot]int{}
+	inBox := make([]int, len(e.vehicles))
+	boxOccupants := map[int][]int{}
+	// Tail segments are indexed by slot and, inside a box, by intersection.
+	type tailRef struct {
+		vehicle int
+		segment int
+	}
+	tailAt := map[slot]tailRef{}
+	boxTails := map[int][]tailRef{}
+	for i := range e.vehicles {
+		v := e.vehicles[i]
+		inBox[i] = -1
+		if moves[i].exited {
+			continue
+		}
+		currentSlot[i] = e.slotOf(v)
+		slotToVehicle[currentSlot[i]] = i
+		if idx, ok := e.intersectionAt[cell{x: v.X, y: v.Y}]; ok {
+			inBox[i] = idx
+			boxOccupants[idx] = append(boxOccupants[idx], i)
+		}
+		for j, s := range v.Tail {
+			ref := tailRef{vehicle: i, segment: j}
+			tailAt[e.slotAt(s.X, s.Y, s.Heading, s.RoadLane)] = ref
+			if idx, ok := e.intersectionAt[cell{x: s.X, y: s.Y}]; ok {
+				boxTails[idx] = append(boxTails[idx], ref)
+			}
+		}
+	}
+	// tailStays reports whether the box cell of a tail segment is still held
+	// after this move.
+	tailStays := func(ref tailRef) bool {
+		return !plans[ref.vehicle].canMove || !e.tailLeaves(e.vehicles[ref.vehicle], ref.segment)
+	}
+
+	for i := range e.vehicles {
+		v := e.vehicles[i]
+		nextX, nextY, heading := e.ahead(v)
+		plan := movePlan{nextX: nextX, nextY: nextY, heading: heading, lane: v.RoadLane, intersection: -1}
+		if !active[i] {
+			plans[i] = plan
+			continue
+		}
+
+		if e.leavesNetwork(v.X, v.Y, nextX, nextY, heading) {
+			plan.canMove = true
+			plan.exitsGrid = true
+			plans[i] = plan
+			continue
+		}
+
+		_, nextIsBox := e.intersectionAt[cell{x: nextX, y: nextY}]
+		if inBox[i] >= 0 {
+			plan.lane = e.exitLane(v, nextX, nextY, heading)
+		} else if !e.laneContinues(v, nextX, nextY, nextIsBox) {
+			// The vehicle has to change lanes before it can go on.
+			plan.blockedBy = BlockedByTraffic
+			plans[i] = plan
+			continue
+		}
+		if e.merging[e.slotAt(nextX, nextY, heading, plan.lane)] {
+			// Hold back for a vehicle waiting to merge into the slot ahead.
+			plan.blockedBy = BlockedByTraffic
+			plans[i] = plan
+			continue
+		}
+
+		if idx, ok := e.intersectionAt[cell{x: nextX, y: nextY}]; ok {
+			plan.intersection = idx
+			light := e.intersections[idx].light
+			if light.yellow(heading) && e.mayEnterOnYellow(v, light, step) {
+				plan.onYellow = true
+			} else if !light.green(heading) {
+				plan.blockedBy = BlockedBySignal
+				plans[i] = plan
+				continue
+			}
+		}
+		if e.pedestriansCrossing(v.X, v.Y, nextX, nextY, heading) {
+			// Vehicles yield to pedestrians on the crosswalk, which holds
+			// back turning vehicles leaving the box in particular.
+			plan.blockedBy = BlockedByPedestrians
+			plans[i] = plan
+			continue
+		}
+
+		plan.canMove = true
+		plans[i] = plan
+	}
+
+	// Left turns yield to opposing through and right-turning traffic that is
+	// inside the intersection or entering it in the same step.
+	for i := range e.vehicles {
+		v := e.vehicles[i]
+		idx := plans[i].intersection
+		if !plans[i].canMove || idx < 0 || v.pendingMovement() != TurnLeft {
+			continue
+		}
+		oncoming := opposite(v.CurrentHeading())
+		for j := range e.vehicles {
+			other := e.vehicles[j]
+			if moves[j].exited || other.CurrentHeading() != oncoming || other.pendingMovement() == TurnLeft {
+				continue
+			}
+			if inBox[j] == idx || (plans[j].canMove && plans[j].intersection == idx) {
+				plans[i].canMove = false
+				plans[i].blockedBy = BlockedByTraffic
+				break
+			}
+		}
+		for _, ref := range boxTails[idx] {
+			other := e.vehicles[ref.vehicle]
+			if other.Tail[ref.segment].Heading == oncoming && segmentMovement(other, ref.segment) != TurnLeft {
+				plans[i].canMove = false
+				plans[i].blockedBy = BlockedByTraffic
+				break
+			}
+		}
+	}
+
+	entering := map[int][]int{}
+	for i := range e.vehicles {
+		if plans[i].canMove && plans[i].intersection >= 0 {
+			entering[plans[i].intersection] = append(entering[plans[i].intersection], i)
+		}
+	}
+	for idx, indices := range entering {
+		conflicted := map[int]bool{}
+		for a := 0; a < len(indices); a++ {
+			for b := a + 1; b < len(indices); b++ {
+				va, vb := e.vehicles[indices[a]], e.vehicles[indices[b]]
+				if movementsConflict(va.CurrentHeading(), va.pendingMovement(), vb.CurrentHeading(), vb.pendingMovement()) {
+					conflicted[indices[a]] = true
+					conflicted[indices[b]] = true
+				}
+			}
+		}
+		if len(conflicted) == 0 {
+			continue
+		}
+		in := e.intersections[idx]
+		conflict := Conflict{Kind: ConflictCollision, Intersection: in.id, X: in.x, Y: in.y}
+		for _, i := range indices {
+			if conflicted[i] {
+				plans[i].canMove = false
+				plans[i].blockedBy = BlockedByTraffic
+				conflict.Vehicles = append(conflict.Vehicles, e.vehicles[i].ID)
+			}
+		}
+		e.emitConflict(step+1, conflict)
+	}
+
+	targets := map[slot][]int{}
+	for i := range e.vehicles {
+		if plans[i].canMove && !plans[i].exitsGrid {
+			target := e.slotAt(plans[i].nextX, plans[i].nextY, plans[i].heading, plans[i].lane)
+			targets[target] = append(targets[target], i)
+		}
+	}
+	for target, indices := range targets {
+		if len(indices) <= 1 {
+			continue
+		}
+		conflict := Conflict{Kind: ConflictCollision, X: target.x, Y: target.y}
+		if idx, ok := e.intersectionAt[cell{x: target.x, y: target.y}]; ok {
+			conflict.Intersection = e.intersections[idx].id
+		}
+		for _, idx := range indices {
+			plans[idx].canMove = false
+			plans[idx].blockedBy = BlockedByTraffic
+			conflict.Vehicles = append(conflict.Vehicles, e.vehicles[idx].ID)
+		}
+		e.emitConflict(step+1, conflict)
+	}
+
+	blockedAhead := func(i int) bool {
+		plan := plans[i]
+		target := e.slotAt(plan.nextX, plan.nextY, plan.heading, plan.lane)
+		if occIdx, occupied := slotToVehicle[target]; occupied && occIdx != i {
+			occPlan := plans[occIdx]
+			occTarget := e.slotAt(occPlan.nextX, occPlan.nextY, occPlan.heading, occPlan.lane)
+			occupantLeaves := occPlan.canMove && (occPlan.exitsGrid || occTarget != target)
+			swapsPositions := occPlan.canMove && !occPlan.exitsGrid && occTarget == currentSlot[i]
+			if !occupantLeaves || swapsPositions {
+				return true
+			}
+		}
+		if ref, held := tailAt[target]; held && ref.vehicle != i && tailStays(ref) {
+			return true
+		}
+
+		// A vehicle may not enter an intersection that is held by a
+		// conflicting movement which cannot clear it this step. A long
+		// vehicle clears the box only once its tail has passed.
+		if plan.intersection >= 0 {
+			v := e.vehicles[i]
+			for _, k := range boxOccupants[plan.intersection] {
+				if k == i || (plans[k].canMove && len(e.vehicles[k].Tail) == 0) {
+					continue
+				}
+				occupant := e.vehicles[k]
+				if movementsConflict(v.CurrentHeading(), v.pendingMovement(), occupant.CurrentHeading(), occupant.pendingMovement()) {
+					return true
+				}
+			}
+			for _, ref := range boxTails[plan.intersection] {
+				if ref.vehicle == i || !tailStays(ref) {
+					continue
+				}
+				occupant := e.vehicles[ref.vehicle]
+				if movementsConflict(v.CurrentHeading(), v.pendingMovement(), occupant.Tail[ref.segment].Heading, segmentMovement(occupant, ref.segment)) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// Resolve dependencies against vehicles occupying target cells. This allows
+	// platoons to move forward in the same step when the lead vehicle vacates.
+	changed := true
+	for changed {
+		changed = false
+		for i := range e.vehicles {
+			if !plans[i].canMove || plans[i].exitsGrid || !blockedAhead(i) {
+				continue
+			}
+			plans[i].canMove = false
+			plans[i].blockedBy = BlockedByTraffic
+			changed = true
+		}
+	}
+
+	moving := make([]bool, len(e.vehicles))
+	for i := range plans {
+		moving[i] = plans[i].canMove
+	}
+
+	for i := range e.vehicles {
+		plan := plans[i]
+		if !active[i] || !plan.canMove || plan.intersection < 0 {
+			continue
+		}
+		if k := e.clearing(i, boxOccupants[plan.intersection], moving); k >= 0 {
+			in := e.intersections[plan.intersection]
+			e.emitConflict(step+1, Conflict{
+				Kind:         ConflictClearance,
+				Intersection: in.id,
+				X:            in.x,
+				Y:            in.y,
+				Vehicles:     []int{e.vehicles[i].ID, e.vehicles[k].ID},
+			})
+		}
+	}
+
+	for i := range e.vehicles {
+		if !active[i] {
+			continue
+		}
+		v := e.vehicles[i]
+		plan := plans[i]
+		m := &moves[i]
+		if !plan.canMove {
+			m.stopped = true
+			if m.cells == 0 {
+				m.blockedBy = plan.blockedBy
+			}
+			continue
+		}
+		if plan.exitsGrid {
+			m.exited = true
+			continue
+		}
+		if plan.intersection >= 0 {
+			m.intersection = plan.intersection
+			m.onYellow = plan.onYellow
+		}
+		v.Tail = e.tailAfterMove(v)
+		v.X, v.Y = plan.nextX, plan.nextY
+		v.Heading = plan.heading
+		v.RoadLane = plan.lane
+		m.cells++
+		e.vehicles[i] = v
+	}
+}
+
+// clearing returns the index of a conflicting vehicle that is still clearing
+// the box vehicle i enters, or -1. Entering while a conflicting vehicle is
+// still in the box means the clearance interval was too short to separate
+// the two movements.
+func (e *Engine) clearing(i int, occupants []int, moving []bool) int {
+	v := e.vehicles[i]
+	for _, k := range occupants {
+		occupant := e.vehicles[k]
+		if k == i || !moving[k] {
+			continue
+		}
+		if movementsConflict(v.CurrentHeading(), v.pendingMovement(), occupant.CurrentHeading(), occupant.pendingMovement()) {
+			return k
+		}
+	}
+	return -1
+}
+
+func (e *Engine) slotAt(x, y int, heading Direction, lane int) slot {
+	if e.network.onRoad(x, y, axisOf(heading)) {
+		return slot{x: x, y: y, heading: heading, lane: lane}
+	}
+	return slot{x: x, y: y}
+}
+
+func (e *Engine) slotOf(v Vehicle) slot {
+	return e.slotAt(v.X, v.Y, v.CurrentHeading(), v.RoadLane)
+}
+
+// leavesNetwork reports whether moving from (x, y) to (nextX, nextY) takes a
+// vehicle off the grid or past the end of the road segment it travels on.
+func (e *Engine) leavesNetwork(x, y, nextX, nextY int, heading Direction) bool {
+	if nextX < 0 || nextX >= e.cfg.Grid.Width || nextY < 0 || nextY >= e.cfg.Grid.Height {
+		return true
+	}
+	axis := axisOf(heading)
+	return e.network.onRoad(x, y, axis) && !e.network.onRoad(nextX, nextY, axis)
+}
+
+// approaching returns the index of the next intersection ahead of a vehicle
+// and the number of cells to its box, or -1 when it will leave the grid
+// without crossing one.
+func (e *Engine) approaching(v Vehicle) (int, int) {
+	x, y := v.X, v.Y
+	heading := v.CurrentHeading()
+	for distance := 1; ; distance++ {
+		x, y = nextCell(x, y, heading)
+		if x < 0 || x >= e.cfg.Grid.Width || y < 0 || y >= e.cfg.Grid.Height {
+			return -1, 0
+		}
+		if idx, ok := e.intersectionAt[cell{x: x, y: y}]; ok {
+			return idx, distance
+		}
+	}
+}
+
+// departing returns the index of the intersection a vehicle last crossed, or
+// is crossing, or -1 when it has not crossed one since entering the grid.
+func (e *Engine) departing(v Vehicle) int {
+	x, y := v.X, v.Y
+	back := opposite(v.CurrentHeading())
+	for x >= 0 && x < e.cfg.Grid.Width && y >= 0 && y < e.cfg.Grid.Height {
+		if idx, ok := e.intersectionAt[cell{x: x, y: y}]; ok {
+			return idx
+		}
+		x, y = nextCell(x, y, back)
+	}
+	return -1
+}
+
+func nextCell(x, y int, d Direction) (int, int) {
+	switch d {
+	case Up:
+		y--
+	case Down:
+		y++
+	case Left:
+		x--
+	case Right:
+		x++
+	}
+	return x, y
+}
+
+// SetController replaces the signal controller of an intersection, which lets
+// callers benchmark strategies that are not built in.
+func (e *Engine) SetController(intersection string, controller SignalController) error {
+	for _, in := range e.intersections {
+		if in.id == intersection {
+			in.controller = controller
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown intersection %q", intersection)
+}
+
+func (e *Engine) updateLights(step int) {
+	approaches := e.approachStates(step)
+	pedestrians := e.waitingPedestrians()
+	for i, in := range e.intersections {
+		in.light.Timer++
+		phase := in.controller.NextPhase(SignalState{
+			Step:         step + 1,
+			Intersection: in.id,
+			Light:        in.light,
+			Approaches:   approaches[i],
+			Pedestrians:  pedestrians[i],
+		})
+		if phase == "" {
+			continue
+		}
+		e.changePhase(step+1, in, phase)
+	}
+}
+
+func (e *Engine) changePhase(step int, in *intersectionState, phase SignalPhase) {
+	from := in.light.Phase
+	in.light.setPhase(phase)
+	if phase != from {
+		e.emitPhaseChange(step, in.id, from, phase)
+	}
+}
+
+// approachStates collects the detector view of every intersection after the
+// vehicles of this step have moved.
+func (e *Engine) approachStates(step int) []map[Direction]ApproachState {
+	approaches := make([]map[Direction]ApproachState, len(e.intersections))
+	for i := range approaches {
+		approaches[i] = map[Direction]ApproachState{}
+	}
+	for _, v := range e.vehicles {
+		heading := v.CurrentHeading()
+		if from := e.departing(v); from >= 0 {
+			approach := approaches[from][heading]
+			approach.Downstream++
+			approaches[from][heading] = approach
+		}
+		idx, distance := e.approaching(v)
+		if idx < 0 {
+			continue
+		}
+		approach := approaches[idx][heading]
+		approach.Vehicles++
+		if v.BlockedStep == step+1 {
+			approach.Queued++
+		}
+		if distance <= detectorCells(e.intersections[idx].signal) {
+			approach.Detected++
+		}
+		approaches[idx][heading] = approach
+	}
+	for _, id := range e.laneOrder {
+		lane := e.laneStates[id]
+		if lane.Queued == 0 {
+			continue
+		}
+		idx, _ := e.approaching(Vehicle{X: lane.EntryX, Y: lane.EntryY, Direction: lane.Direction})
+		if idx < 0 {
+			continue
+		}
+		approach := approaches[idx][lane.Direction]
+		approach.LaneQueue += lane.Queued
+		approaches[idx][lane.Direction] = approach
+	}
+	for i, in := range e.intersections {
+		for _, dir := range []Direction{Up, Down, Left, Right} {
+			approach := approaches[i][dir]
+			if approach.Detected > 0 {
+				in.sinceDetection[dir] = 0
+			} else {
+				in.sinceDetection[dir]++
+			}
+			approach.SinceDetection = in.sinceDetection[dir]
+			approaches[i][dir] = approach
+		}
+	}
+	return approaches
+}
+
+func detectorCells(signal SignalConfig) int {
+	if signal.DetectorCells <= 0 {
+		return 3
+	}
+	return signal.DetectorCells
+}
+
+func (e *Engine) metrics() Metrics {
+	stats := e.stats
+	completed := stats.completed()
+
+	m := Metrics{
+		ScenarioName:         e.cfg.Name,
+		Steps:                e.step,
+		VehiclesSpawned:      len(e.vehicles) + completed,
+		VehiclesCompleted:    completed,
+		ActiveVehicles:       len(e.vehicles),
+		BlockedBySignal:      stats.blockedSignal,
+		BlockedByTraffic:     stats.blockedTraffic,
+		PotentialCollisions:  stats.potentialCrash,
+		YellowEntries:        stats.yellowEntries,
+		ClearanceConflicts:   stats.clearanceCrash,
+		TotalDistance:        stats.totalDistance,
+		MaxQueueOverall:      e.maxQueueOverall(),
+		LaneChanges:          stats.laneChanges,
+		MandatoryLaneChanges: stats.mandatoryChanges,
+		DirectionStats:       map[Direction]DirStats{},
+		ClassStats:           map[string]ClassStats{},
+		IntersectionStats:    map[string]IntersectionStats{},
+	}
+
+	if stats.totalVehicleStep > 0 {
+		m.AverageNetworkSpeed = float64(stats.totalDistance) / float64(stats.totalVehicleStep)
+	}
+	if completed > 0 {
+		m.AverageWaitPerTrip = float64(stats.totalWaitEnded) / float64(completed)
+		m.AverageTripDuration = float64(stats.totalTripEnded) / float64(completed)
+	}
+	if e.step > 0 {
+		m.ThroughputPer100Step = float64(completed) / float64(e.step) * 100
+	}
+	var waits, trips []int
+	for _, dir := range []Direction{Up, Down, Left, Right} {
+		waits = append(waits, stats.dirWaits[dir]...)
+		trips = append(trips, stats.dirTrips[dir]...)
+	}
+	m.WaitDistribution = newDistribution(waits)
+	m.TripDistribution = newDistribution(trips)
+
+	for _, lane := range e.laneStates {
+		dir := lane.Direction
+		stat := m.DirectionStats[dir]
+		stat.Spawned = stats.dirSpawn[dir]
+		stat.Completed = stats.dirDone[dir]
+		if lane.MaxQueueObserved > stat.MaxQueue {
+			stat.MaxQueue = lane.MaxQueueObserved
+		}
+		if stats.dirDone[dir] > 0 {
+			stat.AverageWait = float64(stats.dirWaitEnded[dir]) / float64(stats.dirDone[dir])
+			stat.AverageDuration = float64(stats.dirTripEnded[dir]) / float64(stats.dirDone[dir])
+		}
+		stat.WaitDistribution = newDistribution(stats.dirWaits[dir])
+		stat.TripDistribution = newDistribution(stats.dirTrips[dir])
+		stat.Movements = map[Movement]MovementStats{}
+		for _, movement := range []Movement{Through, TurnRight, TurnLeft} {
+			key := movementKey{dir: dir, movement: movement}
+			if stats.moveSpawn[key] == 0 && stats.moveDone[key] == 0 {
+				continue
+			}
+			ms := MovementStats{Spawned: stats.moveSpawn[key], Completed: stats.moveDone[key]}
+			if stats.moveDone[key] > 0 {
+				ms.AverageWait = float64(stats.moveWaitEnded[key]) / float64(stats.moveDone[key])
+				ms.AverageDuration = float64(stats.moveTripEnded[key]) / float64(stats.moveDone[key])
+			}
+			stat.Movements[movement] = ms
+		}
+		if e.multiLane {
+			stat.RoadLanes = append([]RoadLaneStats(nil), stats.roadLanes[dir]...)
+		}
+		m.DirectionStats[dir] = stat
+	}
+
+	for class, spawned := range stats.classSpawn {
+		cs := ClassStats{Spawned: spawned, Completed: stats.classDone[class]}
+		if cs.Completed > 0 {
+			cs.AverageWait = float64(stats.classWaitEnded[class]) / float64(cs.Completed)
+			cs.AverageDuration = float64(stats.classTripEnded[class]) / float64(cs.Completed)
+		}
+		cs.WaitDistribution = newDistribution(stats.classWaits[class])
+		cs.TripDistribution = newDistribution(stats.classTrips[class])
+		m.ClassStats[class] = cs
+	}
+
+	for _, in := range e.intersections {
+		stat := *stats.intersections[in.id]
+		m.IntersectionStats[in.id] = stat
+		m.PhaseSwitches += stat.PhaseSwitches
+		m.LostTimeSteps += stat.LostTimeSteps
+	}
+	m.Pedestrians = e.pedestrianStats()
+
+	return m
+}
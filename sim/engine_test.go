@@ -161,8 +161,8 @@ func TestPlatoonVehiclesCanAdvanceInSameStep(t *testing.T) {
 	if engine.vehicles[0].X != 2 || engine.vehicles[1].X != 3 {
 		t.Fatalf("expected vehicles to advance to x=2 and x=3, got x=%d and x=%d", engine.vehicles[0].X, engine.vehicles[1].X)
 	}
-	if engine.blockedTraffic != 0 {
-		t.Fatalf("expected no traffic blocking, got %d", engine.blockedTraffic)
+	if engine.stats.blockedTraffic != 0 {
+		t.Fatalf("expected no traffic blocking, got %d", engine.stats.blockedTraffic)
 	}
 }
 
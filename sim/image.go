@@ -0,0 +1,345 @@
+package sim
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	ColorByDirection = "direction"
+	ColorByWait      = "wait"
+)
+
+// ImageOptions controls SVG and GIF export. CellSize is the size of a grid
+// cell in pixels (default 16). ColorBy colors vehicles by their direction
+// (default) or by how many steps they have waited so far.
+type ImageOptions struct {
+	CellSize int    `json:"cell_size"`
+	ColorBy  string `json:"color_by"`
+}
+
+var (
+	imageBackground   = color.RGBA{0x1e, 0x1e, 0x24, 0xff}
+	imageRoad         = color.RGBA{0x4a, 0x4a, 0x52, 0xff}
+	imageBox          = color.RGBA{0x30, 0x30, 0x36, 0xff}
+	imagePanel        = color.RGBA{0x14, 0x14, 0x18, 0xff}
+	imageText         = color.RGBA{0xe8, 0xe8, 0xe8, 0xff}
+	imageSignalGreen  = color.RGBA{0x2e, 0xcc, 0x40, 0xff}
+	imageSignalYellow = color.RGBA{0xff, 0xdc, 0x00, 0xff}
+	imageSignalRed    = color.RGBA{0xff, 0x41, 0x36, 0xff}
+
+	directionColors = map[Direction]color.RGBA{
+		Up:    {0x39, 0xcc, 0xcc, 0xff},
+		Down:  {0x00, 0x74, 0xd9, 0xff},
+		Left:  {0xff, 0x85, 0x1b, 0xff},
+		Right: {0xf0, 0x12, 0xbe, 0xff},
+	}
+	// waitColors go from no wait to ten or more steps of waiting.
+	waitColors = []color.RGBA{
+		{0x2e, 0xcc, 0x40, 0xff},
+		{0xb1, 0xdd, 0x1e, 0xff},
+		{0xff, 0xdc, 0x00, 0xff},
+		{0xff, 0x85, 0x1b, 0xff},
+		{0xff, 0x41, 0x36, 0xff},
+	}
+)
+
+// imagePalette lists every color a frame can use, so GIF frames need no
+// quantization.
+func imagePalette() color.Palette {
+	palette := color.Palette{imageBackground, imageRoad, imageBox, imagePanel, imageText,
+		imageSignalGreen, imageSignalYellow, imageSignalRed}
+	for _, d := range []Direction{Up, Down, Left, Right} {
+		palette = append(palette, directionColors[d])
+	}
+	for _, c := range waitColors {
+		palette = append(palette, c)
+	}
+	return palette
+}
+
+// canvas is the drawing surface shared by the SVG and raster exporters.
+// Text is positioned by the top-left corner of its first character.
+type canvas interface {
+	rect(x, y, w, h int, c color.RGBA)
+	text(x, y int, s string, c color.RGBA)
+}
+
+const (
+	panelPadding    = 6
+	panelLineHeight = 12
+)
+
+type frameLayout struct {
+	cell   int
+	width  int
+	height int
+	gridH  int
+}
+
+func newFrameLayout(cfg Config, opts ImageOptions, lines []string) frameLayout {
+	cell := opts.CellSize
+	if cell <= 0 {
+		cell = 16
+	}
+	layout := frameLayout{
+		cell:  cell,
+		width: cfg.Grid.Width * cell,
+		gridH: cfg.Grid.Height * cell,
+	}
+	for _, line := range lines {
+		if w := len(line)*glyphAdvance + 2*panelPadding; w > layout.width {
+			layout.width = w
+		}
+	}
+	layout.height = layout.gridH + 2*panelPadding + len(lines)*panelLineHeight
+	return layout
+}
+
+func panelLines(stats RenderStats) []string {
+	phase := strings.ReplaceAll(string(stats.Phase), "_", " ")
+	if phase == "" {
+		phase = "horizontal green"
+		if stats.VerticalGreen {
+			phase = "vertical green"
+		}
+	}
+	return []string{
+		fmt.Sprintf("%s  step %d/%d", stats.ScenarioName, stats.Step, stats.TotalSteps),
+		fmt.Sprintf("phase %s", phase),
+		fmt.Sprintf("spawned %d  completed %d  active %d", stats.SpawnedVehicles, stats.CompletedVehicles, stats.ActiveVehicles),
+		fmt.Sprintf("speed %.3f  throughput %.2f/100", stats.AverageNetworkSpeed, stats.ThroughputPer100Step),
+		fmt.Sprintf("blocked signal %d traffic %d  max queue %d", stats.BlockedBySignal, stats.BlockedByTraffic, stats.MaxQueueOverall),
+	}
+}
+
+// drawFrame draws roads, signals, vehicles and the stats panel of one step.
+func drawFrame(cv canvas, layout frameLayout, cfg Config, network NetworkConfig, snap StepSnapshot, lines []string, opts ImageOptions) {
+	c := layout.cell
+	cv.rect(0, 0, layout.width, layout.height, imageBackground)
+
+	for _, road := range network.Roads {
+		if road.Axis == Vertical {
+			cv.rect(road.At*c, road.From*c, c, (road.To-road.From+1)*c, imageRoad)
+		} else {
+			cv.rect(road.From*c, road.At*c, (road.To-road.From+1)*c, c, imageRoad)
+		}
+	}
+
+	lights := snap.Lights
+	if len(lights) == 0 {
+		lights = []TrafficLight{{Phase: snap.Phase, VerticalGreen: snap.LightGreen}}
+	}
+	bar := max(2, c/6)
+	for i, in := range network.Intersections {
+		if i >= len(lights) {
+			break
+		}
+		x, y := in.X*c, in.Y*c
+		cv.rect(x, y, c, c, imageBox)
+		vertical, horizontal := signalColor(lights[i], Up), signalColor(lights[i], Left)
+		cv.rect(x, y, c, bar, vertical)
+		cv.rect(x, y+c-bar, c, bar, vertical)
+		cv.rect(x, y, bar, c, horizontal)
+		cv.rect(x+c-bar, y, bar, c, horizontal)
+	}
+
+	for _, v := range snap.Vehicles {
+		fill := vehicleColor(v, opts)
+		for _, s := range v.Segments() {
+			x, y, w, h := s.X*c, s.Y*c, c, c
+			if lanes := network.Lanes(s.X, s.Y, s.Heading); lanes > 0 && !isIntersection(network, s.X, s.Y) {
+				// Driving on the right: each direction has half the road,
+				// split into its lanes with lane 0 along the curb.
+				size := max(1, c/2/lanes)
+				lane := min(s.RoadLane, lanes-1)
+				switch s.Heading {
+				case Up:
+					x, w = x+c/2+(lanes-1-lane)*size, size
+				case Down:
+					x, w = x+lane*size, size
+				case Left:
+					y, h = y+lane*size, size
+				case Right:
+					y, h = y+c/2+(lanes-1-lane)*size, size
+				}
+			}
+			inset := max(1, c/16)
+			cv.rect(x+inset, y+inset, w-2*inset, h-2*inset, fill)
+		}
+	}
+
+	top := layout.gridH
+	cv.rect(0, top, layout.width, layout.height-top, imagePanel)
+	for i, line := range lines {
+		cv.text(panelPadding, top+panelPadding+i*panelLineHeight, line, imageText)
+	}
+}
+
+func isIntersection(network NetworkConfig, x, y int) bool {
+	for _, in := range network.Intersections {
+		if in.X == x && in.Y == y {
+			return true
+		}
+	}
+	return false
+}
+
+func signalColor(light TrafficLight, d Direction) color.RGBA {
+	switch {
+	case light.green(d):
+		return imageSignalGreen
+	case light.yellow(d):
+		return imageSignalYellow
+	default:
+		return imageSignalRed
+	}
+}
+
+func vehicleColor(v Vehicle, opts ImageOptions) color.RGBA {
+	if opts.ColorBy == ColorByWait {
+		idx := min(v.WaitSteps*(len(waitColors)-1)/10, len(waitColors)-1)
+		if v.WaitSteps > 0 && idx == 0 {
+			idx = 1
+		}
+		return waitColors[idx]
+	}
+	if c, ok := directionColors[v.Direction]; ok {
+		return c
+	}
+	return imageText
+}
+
+func validateImageOptions(opts ImageOptions) error {
+	switch opts.ColorBy {
+	case "", ColorByDirection, ColorByWait:
+		return nil
+	default:
+		return fmt.Errorf("unsupported color_by %q", opts.ColorBy)
+	}
+}
+
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func (s *svgCanvas) rect(x, y, w, h int, c color.RGBA) {
+	fmt.Fprintf(&s.b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", x, y, w, h, hexColor(c))
+}
+
+func (s *svgCanvas) text(x, y int, text string, c color.RGBA) {
+	fmt.Fprintf(&s.b, `<text x="%d" y="%d" fill="%s" font-family="monospace" font-size="10">%s</text>`+"\n",
+		x, y+glyphHeight+1, hexColor(c), html.EscapeString(text))
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// WriteSVG draws one timeline snapshot with its dashboard stats as SVG.
+func WriteSVG(w io.Writer, cfg Config, snap StepSnapshot, stats RenderStats, opts ImageOptions) error {
+	if err := validateImageOptions(opts); err != nil {
+		return err
+	}
+	lines := panelLines(stats)
+	layout := newFrameLayout(cfg, opts, lines)
+	cv := &svgCanvas{}
+	drawFrame(cv, layout, cfg, resolveNetwork(cfg), snap, lines, opts)
+
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n%s</svg>\n",
+		layout.width, layout.height, layout.width, layout.height, cv.b.String())
+	if err != nil {
+		return fmt.Errorf("write svg: %w", err)
+	}
+	return nil
+}
+
+type rasterCanvas struct {
+	img     *image.Paletted
+	indices map[color.RGBA]uint8
+}
+
+func newRasterCanvas(width, height int, palette color.Palette) *rasterCanvas {
+	indices := make(map[color.RGBA]uint8, len(palette))
+	for i, c := range palette {
+		indices[c.(color.RGBA)] = uint8(i)
+	}
+	return &rasterCanvas{
+		img:     image.NewPaletted(image.Rect(0, 0, width, height), palette),
+		indices: indices,
+	}
+}
+
+func (r *rasterCanvas) rect(x, y, w, h int, c color.RGBA) {
+	idx := r.indices[c]
+	area := image.Rect(x, y, x+w, y+h).Intersect(r.img.Rect)
+	for py := area.Min.Y; py < area.Max.Y; py++ {
+		for px := area.Min.X; px < area.Max.X; px++ {
+			r.img.SetColorIndex(px, py, idx)
+		}
+	}
+}
+
+func (r *rasterCanvas) text(x, y int, text string, c color.RGBA) {
+	for i, ch := range strings.ToUpper(text) {
+		glyph, ok := glyphs[ch]
+		if !ok {
+			glyph = glyphs['?']
+		}
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) != 0 {
+					r.rect(x+i*glyphAdvance+col, y+row, 1, 1, c)
+				}
+			}
+		}
+	}
+}
+
+// WriteGIF animates the snapshots of timeline whose steps fall within
+// from..to, both inclusive, showing each frame for delay.
+func WriteGIF(w io.Writer, cfg Config, timeline []StepSnapshot, from, to int, delay time.Duration, opts ImageOptions) error {
+	if err := validateImageOptions(opts); err != nil {
+		return err
+	}
+	network := resolveNetwork(cfg)
+	stats := TimelineStats(cfg, timeline)
+	palette := imagePalette()
+	centis := max(2, int(delay/(10*time.Millisecond)))
+
+	var frames []int
+	width, height := 0, 0
+	for i, snap := range timeline {
+		if snap.Step < from || snap.Step > to {
+			continue
+		}
+		frames = append(frames, i)
+		layout := newFrameLayout(cfg, opts, panelLines(stats[i]))
+		width, height = max(width, layout.width), max(height, layout.height)
+	}
+	if len(frames) == 0 {
+		return fmt.Errorf("timeline has no steps in %d..%d", from, to)
+	}
+
+	anim := &gif.GIF{}
+	for _, i := range frames {
+		lines := panelLines(stats[i])
+		layout := newFrameLayout(cfg, opts, lines)
+		// Frames grow to the widest panel so the animation keeps one size.
+		layout.width, layout.height = width, height
+		cv := newRasterCanvas(width, height, palette)
+		drawFrame(cv, layout, cfg, network, timeline[i], lines, opts)
+		anim.Image = append(anim.Image, cv.img)
+		anim.Delay = append(anim.Delay, centis)
+	}
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return fmt.Errorf("write gif: %w", err)
+	}
+	return nil
+}
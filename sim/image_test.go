@@ -0,0 +1,86 @@
+package sim
+
+import (
+	"bytes"
+	"image/gif"
+	"strings"
+	"testing"
+	"time"
+)
+
+func imageTestTimeline(t *testing.T) (Config, []StepSnapshot) {
+	t.Helper()
+	cfg := stepTestConfig()
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	return cfg, engine.Run(true, boolPtr(false)).Timeline
+}
+
+func TestWriteSVGDrawsVehiclesAndStats(t *testing.T) {
+	cfg, timeline := imageTestTimeline(t)
+	snap := timeline[9]
+	stats := TimelineStats(cfg, timeline)[9]
+
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, cfg, snap, stats, ImageOptions{}); err != nil {
+		t.Fatalf("write svg: %v", err)
+	}
+	svg := buf.String()
+	if !strings.HasPrefix(svg, "<svg ") || !strings.HasSuffix(svg, "</svg>\n") {
+		t.Fatalf("output is not an svg document: %.80s", svg)
+	}
+	for _, v := range snap.Vehicles {
+		if want := hexColor(directionColors[v.Direction]); !strings.Contains(svg, want) {
+			t.Fatalf("svg has no vehicle colored %s", want)
+		}
+	}
+	if !strings.Contains(svg, "step-test  step 10/30") {
+		t.Fatalf("svg is missing the stats panel")
+	}
+
+	if err := WriteSVG(&buf, cfg, snap, stats, ImageOptions{ColorBy: "speed"}); err == nil {
+		t.Fatalf("expected error for unsupported color_by")
+	}
+}
+
+func TestWriteGIFAnimatesStepRange(t *testing.T) {
+	cfg, timeline := imageTestTimeline(t)
+
+	var buf bytes.Buffer
+	opts := ImageOptions{CellSize: 8, ColorBy: ColorByWait}
+	if err := WriteGIF(&buf, cfg, timeline, 5, 14, 50*time.Millisecond, opts); err != nil {
+		t.Fatalf("write gif: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("decode gif: %v", err)
+	}
+	if len(anim.Image) != 10 {
+		t.Fatalf("frames = %d, want 10", len(anim.Image))
+	}
+	if anim.Delay[0] != 5 {
+		t.Fatalf("delay = %d centiseconds, want 5", anim.Delay[0])
+	}
+	bounds := anim.Image[0].Bounds()
+	if bounds.Dx() < cfg.Grid.Width*8 || bounds.Dy() <= cfg.Grid.Height*8 {
+		t.Fatalf("frame size = %v, want grid plus panel", bounds)
+	}
+
+	// The panel text is drawn with the bitmap font.
+	text := false
+	for _, px := range anim.Image[0].Pix[cfg.Grid.Height*8*bounds.Dx():] {
+		if anim.Image[0].Palette[px] == imageText {
+			text = true
+			break
+		}
+	}
+	if !text {
+		t.Fatalf("gif frame has no panel text")
+	}
+
+	if err := WriteGIF(&buf, cfg, timeline, 100, 120, time.Second, opts); err == nil {
+		t.Fatalf("expected error for a range outside the timeline")
+	}
+}
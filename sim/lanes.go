@@ -0,0 +1,353 @@
+package sim
+
+// LaneChange describes a vehicle moving over by one lane. Mandatory changes
+// get a vehicle into a lane its next movement may leave from, or out of a
+// lane that ends; the others pass a queue in the vehicle's own lane.
+type LaneChange struct {
+	Vehicle   Vehicle `json:"vehicle"`
+	FromLane  int     `json:"from_lane"`
+	Mandatory bool    `json:"mandatory,omitempty"`
+}
+
+// RoadLaneStats are the counters of one road lane over all approaches of a
+// direction. Served counts the vehicles that entered an intersection from the
+// lane and MaxQueue the most vehicles standing in it during one step.
+type RoadLaneStats struct {
+	Lane          int `json:"lane"`
+	Served        int `json:"served"`
+	BlockedSteps  int `json:"blocked_steps"`
+	MaxQueue      int `json:"max_queue"`
+	LaneChangesIn int `json:"lane_changes_in"`
+}
+
+// changeLanes lets vehicles on multi-lane roads move over by one lane before
+// they move forward. Vehicles change in turn, each seeing the lanes the ones
+// before it picked, and only into a gap wide enough for gapBehind. A vehicle
+// that cannot go on in its lane merges as soon as the slot beside it is free,
+// and while it waits the vehicle behind that slot holds back for it, like a
+// zipper. Two such vehicles side by side that need each other's lane swap.
+// Long vehicles move over as a whole and only on a straight stretch.
+func (e *Engine) changeLanes(step int) {
+	clear(e.merging)
+	if !e.multiLane {
+		return
+	}
+	if e.merging == nil {
+		e.merging = map[slot]bool{}
+	}
+	taken := make(map[slot]int, len(e.vehicles))
+	for i := range e.vehicles {
+		for _, s := range e.slots(e.vehicles[i]) {
+			taken[s] = i
+		}
+	}
+
+	for i := range e.vehicles {
+		v := e.vehicles[i]
+		to, mandatory, ok := e.laneTarget(v, step, taken)
+		if !ok {
+			continue
+		}
+		beside := e.beside(v, to)
+		if mandatory && e.laneEnds(v) {
+			if k, held := e.held(beside, taken); held {
+				if e.swaps(v, e.vehicles[k], to, step, taken) {
+					e.moveOver(step, k, v.RoadLane, true, taken)
+					e.moveOver(step, i, to, true, taken)
+					continue
+				}
+				e.merging[beside[len(beside)-1]] = true
+				continue
+			}
+		} else if !e.gapAccepted(v, to, taken) {
+			continue
+		}
+		e.moveOver(step, i, to, mandatory, taken)
+	}
+}
+
+// laneTarget returns the adjacent lane a vehicle outside an intersection
+// wants to move over to and whether the change is mandatory.
+func (e *Engine) laneTarget(v Vehicle, step int, taken map[slot]int) (int, bool, bool) {
+	if _, inBox := e.intersectionAt[cell{x: v.X, y: v.Y}]; inBox {
+		return 0, false, false
+	}
+	heading := v.CurrentHeading()
+	lanes := e.network.Lanes(v.X, v.Y, heading)
+	if lanes <= 1 {
+		return 0, false, false
+	}
+	target, mandatory := e.mandatoryLane(v)
+	if !mandatory {
+		var ok bool
+		if target, ok = e.passingLane(v, step, taken); !ok {
+			return 0, false, false
+		}
+	}
+	to := v.RoadLane + 1
+	if target < v.RoadLane {
+		to = v.RoadLane - 1
+	}
+	return to, mandatory, to >= 0 && to < lanes && e.canShift(v, to)
+}
+
+// canShift reports whether the tail of a long vehicle lies straight behind
+// it in its lane, with lane to beside every segment.
+func (e *Engine) canShift(v Vehicle, to int) bool {
+	heading := v.CurrentHeading()
+	for _, s := range v.Tail {
+		if _, inBox := e.intersectionAt[cell{x: s.X, y: s.Y}]; inBox {
+			return false
+		}
+		if s.Heading != heading || s.RoadLane != v.RoadLane || to >= e.network.Lanes(s.X, s.Y, heading) {
+			return false
+		}
+	}
+	return true
+}
+
+// beside returns the slots in lane next to each cell a vehicle occupies,
+// front first.
+func (e *Engine) beside(v Vehicle, lane int) []slot {
+	slots := make([]slot, 0, 1+len(v.Tail))
+	for _, s := range v.Segments() {
+		slots = append(slots, e.slotAt(s.X, s.Y, s.Heading, lane))
+	}
+	return slots
+}
+
+// held returns the first vehicle holding one of slots.
+func (e *Engine) held(slots []slot, taken map[slot]int) (int, bool) {
+	for _, s := range slots {
+		if k, ok := taken[s]; ok {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// swaps reports whether v, merging into lane to, and other, beside it, need
+// each other's lanes. Only single-cell vehicles swap.
+func (e *Engine) swaps(v, other Vehicle, to, step int, taken map[slot]int) bool {
+	if e.length(v) > 1 || e.length(other) > 1 || other.RoadLane != to {
+		return false
+	}
+	back, mandatory, ok := e.laneTarget(other, step, taken)
+	return ok && mandatory && back == v.RoadLane && e.laneEnds(other)
+}
+
+// laneEnds reports whether a vehicle cannot move on in its lane, because the
+// lane ends or does not serve its movement at the intersection ahead.
+func (e *Engine) laneEnds(v Vehicle) bool {
+	x, y := nextCell(v.X, v.Y, v.CurrentHeading())
+	_, entersBox := e.intersectionAt[cell{x: x, y: y}]
+	return !e.laneContinues(v, x, y, entersBox)
+}
+
+func (e *Engine) moveOver(step, i, lane int, mandatory bool, taken map[slot]int) {
+	v := e.vehicles[i]
+	change := LaneChange{FromLane: v.RoadLane, Mandatory: mandatory}
+	for _, s := range e.slots(v) {
+		if taken[s] == i {
+			delete(taken, s)
+		}
+	}
+	v.RoadLane = lane
+	if len(v.Tail) > 0 {
+		tail := make([]Segment, len(v.Tail))
+		for j, s := range v.Tail {
+			s.RoadLane = lane
+			tail[j] = s
+		}
+		v.Tail = tail
+	}
+	for _, s := range e.slots(v) {
+		taken[s] = i
+	}
+	e.vehicles[i] = v
+	change.Vehicle = v
+	e.emitLaneChange(step+1, change)
+}
+
+// mandatoryLane returns the lane a vehicle has to reach: one its movement at
+// the next intersection may leave from, or the last lane that continues past
+// a lane drop just ahead.
+func (e *Engine) mandatoryLane(v Vehicle) (int, bool) {
+	heading := v.CurrentHeading()
+	nextX, nextY := nextCell(v.X, v.Y, heading)
+	if _, nextIsBox := e.intersectionAt[cell{x: nextX, y: nextY}]; !nextIsBox {
+		if lanes := e.network.Lanes(nextX, nextY, heading); lanes > 0 && v.RoadLane >= lanes {
+			return lanes - 1, true
+		}
+	}
+
+	stopX, stopY, ok := e.stopLine(v)
+	if !ok {
+		return 0, false
+	}
+	lo, hi := e.laneRange(v, stopX, stopY)
+	switch {
+	case v.RoadLane < lo:
+		return lo, true
+	case v.RoadLane > hi:
+		return hi, true
+	}
+	return 0, false
+}
+
+// passingLane picks an adjacent lane for a through vehicle that stood behind
+// another one in the previous step: the one with the longer free run ahead,
+// the curb side on a tie. Lanes the vehicle could not go through the next
+// intersection from are left out.
+func (e *Engine) passingLane(v Vehicle, step int, taken map[slot]int) (int, bool) {
+	if v.pendingMovement() != Through || v.BlockedStep == 0 || v.BlockedStep != step {
+		return 0, false
+	}
+	heading := v.CurrentHeading()
+	nextX, nextY := nextCell(v.X, v.Y, heading)
+	if _, nextIsBox := e.intersectionAt[cell{x: nextX, y: nextY}]; nextIsBox {
+		return 0, false
+	}
+	if _, ahead := taken[e.slotAt(nextX, nextY, heading, v.RoadLane)]; !ahead {
+		return 0, false
+	}
+
+	lo, hi := 0, e.network.Lanes(v.X, v.Y, heading)-1
+	if stopX, stopY, ok := e.stopLine(v); ok {
+		lo, hi = e.laneRange(v, stopX, stopY)
+	}
+	best, bestRun := -1, 0
+	for _, lane := range []int{v.RoadLane - 1, v.RoadLane + 1} {
+		if lane < lo || lane > hi {
+			continue
+		}
+		if run := e.freeRun(v, lane, taken); run > bestRun {
+			best, bestRun = lane, run
+		}
+	}
+	return best, best >= 0
+}
+
+// gapAccepted reports whether lane is free beside a vehicle and for
+// gapBehind cells behind it.
+func (e *Engine) gapAccepted(v Vehicle, lane int, taken map[slot]int) bool {
+	heading := v.CurrentHeading()
+	if _, held := e.held(e.beside(v, lane), taken); held {
+		return false
+	}
+	x, y := v.X, v.Y
+	if len(v.Tail) > 0 {
+		x, y = v.Tail[len(v.Tail)-1].X, v.Tail[len(v.Tail)-1].Y
+	}
+	back := opposite(heading)
+	for range gapBehind(e.cfg.LaneChange) {
+		x, y = nextCell(x, y, back)
+		if !e.inGrid(x, y) || !e.network.onRoad(x, y, axisOf(heading)) {
+			break
+		}
+		if _, held := taken[e.slotAt(x, y, heading, lane)]; held {
+			return false
+		}
+	}
+	return true
+}
+
+// freeRun counts the free cells ahead of a vehicle in lane, up to the next
+// intersection or the end of the lane.
+func (e *Engine) freeRun(v Vehicle, lane int, taken map[slot]int) int {
+	heading := v.CurrentHeading()
+	x, y := v.X, v.Y
+	run := 0
+	for {
+		x, y = nextCell(x, y, heading)
+		if !e.inGrid(x, y) || lane >= e.network.Lanes(x, y, heading) {
+			return run
+		}
+		if _, isBox := e.intersectionAt[cell{x: x, y: y}]; isBox {
+			return run
+		}
+		if _, held := taken[e.slotAt(x, y, heading, lane)]; held {
+			return run
+		}
+		run++
+	}
+}
+
+// stopLine returns the cell in front of the next intersection a vehicle
+// reaches on its current road.
+func (e *Engine) stopLine(v Vehicle) (int, int, bool) {
+	idx, distance := e.approaching(v)
+	if idx < 0 {
+		return 0, 0, false
+	}
+	heading := v.CurrentHeading()
+	x, y := v.X, v.Y
+	for range distance - 1 {
+		x, y = nextCell(x, y, heading)
+	}
+	if e.network.Lanes(x, y, heading) == 0 {
+		return 0, 0, false
+	}
+	return x, y, true
+}
+
+// laneRange returns the lanes at the stop line (x, y) a vehicle may enter the
+// intersection from. Right turns leave from the curb lane and left turns from
+// the median lane. Through traffic may use any lane that continues past the
+// intersection, so the extra lane of a turn bay is left to the turns.
+func (e *Engine) laneRange(v Vehicle, x, y int) (int, int) {
+	heading := v.CurrentHeading()
+	lanes := e.network.Lanes(x, y, heading)
+	switch v.pendingMovement() {
+	case TurnRight:
+		return 0, 0
+	case TurnLeft:
+		return lanes - 1, lanes - 1
+	}
+	boxX, boxY := nextCell(x, y, heading)
+	beyondX, beyondY := nextCell(boxX, boxY, heading)
+	if beyond := e.network.Lanes(beyondX, beyondY, heading); beyond > 0 {
+		return 0, min(lanes, beyond) - 1
+	}
+	return 0, lanes - 1
+}
+
+// laneContinues reports whether a vehicle outside an intersection may move
+// on to (x, y) in its lane: the lane has to exist there, and a vehicle
+// entering an intersection has to be in a lane its movement may leave from.
+func (e *Engine) laneContinues(v Vehicle, x, y int, entersBox bool) bool {
+	if entersBox {
+		if e.network.Lanes(v.X, v.Y, v.CurrentHeading()) == 0 {
+			return true
+		}
+		lo, hi := e.laneRange(v, v.X, v.Y)
+		return v.RoadLane >= lo && v.RoadLane <= hi
+	}
+	lanes := e.network.Lanes(x, y, v.CurrentHeading())
+	return lanes == 0 || v.RoadLane < lanes
+}
+
+// exitLane returns the lane a vehicle leaving an intersection towards (x, y)
+// with heading drives into: turns keep to the side they turned from and
+// through traffic keeps its lane.
+func (e *Engine) exitLane(v Vehicle, x, y int, heading Direction) int {
+	lanes := max(e.network.Lanes(x, y, heading), 1)
+	switch v.pendingMovement() {
+	case TurnRight:
+		return 0
+	case TurnLeft:
+		return lanes - 1
+	}
+	return min(v.RoadLane, lanes-1)
+}
+
+func (e *Engine) inGrid(x, y int) bool {
+	return x >= 0 && x < e.cfg.Grid.Width && y >= 0 && y < e.cfg.Grid.Height
+}
+
+func gapBehind(lc LaneChangeConfig) int {
+	if lc.GapBehind <= 0 {
+		return 1
+	}
+	return lc.GapBehind
+}
@@ -0,0 +1,170 @@
+package sim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// laneObserver records the lane vehicles entered the intersection from.
+type laneObserver struct {
+	NopObserver
+	entries map[Movement][]int
+	changes []LaneChange
+}
+
+func (o *laneObserver) OnLaneChange(_ int, change LaneChange) {
+	o.changes = append(o.changes, change)
+}
+
+func (o *laneObserver) OnMove(_ int, move Move) {
+	if move.Intersection != "" {
+		o.entries[move.Vehicle.Movement] = append(o.entries[move.Vehicle.Movement], move.Vehicle.RoadLane)
+	}
+}
+
+// stepChecked runs the engine to the end and fails when two vehicles share
+// a slot or a vehicle drives in a lane its road does not have.
+func stepChecked(t *testing.T, engine *Engine) {
+	t.Helper()
+	for !engine.Done() {
+		if err := engine.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		seen := map[slot]int{}
+		for _, v := range engine.State().Vehicles {
+			for _, s := range engine.slots(v) {
+				if other, ok := seen[s]; ok {
+					t.Fatalf("step %d: vehicles %d and %d share %+v", engine.step, other, v.ID, s)
+				}
+				seen[s] = v.ID
+			}
+			for _, s := range v.Segments() {
+				_, inBox := engine.intersectionAt[cell{x: s.X, y: s.Y}]
+				if lanes := engine.network.Lanes(s.X, s.Y, s.Heading); !inBox && s.RoadLane >= max(lanes, 1) {
+					t.Fatalf("step %d: vehicle %d in lane %d of %d at (%d,%d)", engine.step, v.ID, s.RoadLane, lanes, s.X, s.Y)
+				}
+			}
+		}
+	}
+}
+
+func TestTurnBaySeparatesLeftTurns(t *testing.T) {
+	cfg := Config{
+		Name:  "turn-bay-test",
+		Steps: 80,
+		Grid:  GridConfig{Width: 9, Height: 12},
+		Network: NetworkConfig{
+			Roads: []RoadConfig{
+				{ID: "avenue", Axis: Vertical, At: 4},
+				{ID: "bay", Axis: Vertical, At: 4, From: 4, To: 6, Lanes: 2, Direction: Up},
+				{ID: "street", Axis: Horizontal, At: 3},
+			},
+		},
+		Signal: SignalConfig{VerticalGreenSteps: 6, HorizontalGreenSteps: 6},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Up: {EntryX: 4, EntryY: 11, StepInterval: 2, Turns: TurnRatio{Through: 1, Left: 1}},
+			},
+		},
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	obs := &laneObserver{entries: map[Movement][]int{}}
+	engine.AddObserver(obs)
+	stepChecked(t, engine)
+
+	if len(obs.entries[TurnLeft]) == 0 || len(obs.entries[Through]) == 0 {
+		t.Fatalf("expected left turns and through traffic to be served, got %v", obs.entries)
+	}
+	for movement, want := range map[Movement]int{TurnLeft: 1, Through: 0} {
+		for _, lane := range obs.entries[movement] {
+			if lane != want {
+				t.Fatalf("%s vehicle entered from lane %d, want %d", movement, lane, want)
+			}
+		}
+	}
+	for _, change := range obs.changes {
+		if !change.Mandatory || change.Vehicle.Movement != TurnLeft || change.Vehicle.RoadLane != 1 {
+			t.Fatalf("unexpected lane change %+v", change)
+		}
+	}
+
+	m := engine.Finalize().Metrics
+	if m.MandatoryLaneChanges != len(obs.changes) || m.LaneChanges != len(obs.changes) {
+		t.Fatalf("metrics count %d/%d lane changes, observer saw %d", m.LaneChanges, m.MandatoryLaneChanges, len(obs.changes))
+	}
+	lanes := m.DirectionStats[Up].RoadLanes
+	if len(lanes) != 2 || lanes[1].Served != len(obs.entries[TurnLeft]) || lanes[0].Served != len(obs.entries[Through]) {
+		t.Fatalf("road lane stats = %+v", lanes)
+	}
+}
+
+func TestVehiclesMergeBeforeLaneDrop(t *testing.T) {
+	cfg := Config{
+		Name:  "lane-drop-test",
+		Steps: 40,
+		Grid:  GridConfig{Width: 20, Height: 7},
+		Network: NetworkConfig{
+			Roads: []RoadConfig{
+				{ID: "street", Axis: Horizontal, At: 3},
+				{ID: "widening", Axis: Horizontal, At: 3, From: 0, To: 9, Lanes: 2},
+				{ID: "avenue", Axis: Vertical, At: 15},
+			},
+		},
+		Signal: SignalConfig{VerticalGreenSteps: 4, HorizontalGreenSteps: 8},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Right: {EntryX: 0, EntryY: 3, StepInterval: 1},
+			},
+		},
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	if err := engine.AddArrivals("right", 10); err != nil {
+		t.Fatalf("add arrivals: %v", err)
+	}
+	stepChecked(t, engine)
+
+	m := engine.Finalize().Metrics
+	if m.VehiclesCompleted == 0 || m.MandatoryLaneChanges == 0 {
+		t.Fatalf("expected merges and completed trips, got %+v", m)
+	}
+	if m.PotentialCollisions != 0 {
+		t.Fatalf("merging produced %d potential collisions", m.PotentialCollisions)
+	}
+	if lanes := m.DirectionStats[Right].RoadLanes; len(lanes) != 2 || lanes[1].BlockedSteps == 0 {
+		t.Fatalf("expected vehicles queued in the dropped lane, got %+v", lanes)
+	}
+}
+
+func TestLoadConfigRejectsRoadDirectionAcrossAxis(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "invalid.json")
+	content := `{
+		"grid": { "width": 20, "height": 10 },
+		"network": {
+			"roads": [
+				{ "axis": "vertical", "at": 10 },
+				{ "axis": "horizontal", "at": 5 },
+				{ "id": "bay", "axis": "horizontal", "at": 5, "from": 6, "to": 9, "lanes": 2, "direction": "up" }
+			]
+		},
+		"spawn": {
+			"lanes": { "right": { "entry_x": 0, "entry_y": 5, "step_interval": 2 } }
+		}
+	}`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := LoadConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), "does not run along its horizontal axis") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
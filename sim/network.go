@@ -0,0 +1,262 @@
+package sim
+
+import (
+	"fmt"
+	"sort"
+)
+
+type cell struct {
+	x int
+	y int
+}
+
+// slot is the space a vehicle occupies. Roads carry their own lanes per
+// travel direction, so a cell on a road along the vehicle's axis is keyed by
+// its heading and lane; anywhere else vehicles share the bare cell.
+type slot struct {
+	x       int
+	y       int
+	heading Direction
+	lane    int
+}
+
+func axisOf(d Direction) Axis {
+	if d == Up || d == Down {
+		return Vertical
+	}
+	return Horizontal
+}
+
+// ResolvedNetwork returns the roads and intersections the engine simulates,
+// including the ones derived from the grid when the config omits them.
+func (c Config) ResolvedNetwork() NetworkConfig {
+	return resolveNetwork(c)
+}
+
+// resolveNetwork fills in the derived parts of the network declaration. It is
+// idempotent, so it is safe to call on configs that were already defaulted.
+func resolveNetwork(cfg Config) NetworkConfig {
+	network := NetworkConfig{
+		Roads:         append([]RoadConfig(nil), cfg.Network.Roads...),
+		Intersections: append([]IntersectionConfig(nil), cfg.Network.Intersections...),
+	}
+	if len(network.Roads) == 0 && len(network.Intersections) == 0 {
+		network.Intersections = []IntersectionConfig{{
+			ID: "center",
+			X:  cfg.Grid.Width / 2,
+			Y:  cfg.Grid.Height / 2,
+		}}
+	}
+
+	if len(network.Roads) == 0 {
+		seen := map[RoadConfig]bool{}
+		for _, in := range network.Intersections {
+			for _, road := range []RoadConfig{{Axis: Vertical, At: in.X}, {Axis: Horizontal, At: in.Y}} {
+				if seen[road] {
+					continue
+				}
+				seen[road] = true
+				network.Roads = append(network.Roads, road)
+			}
+		}
+	}
+	for i := range network.Roads {
+		road := &network.Roads[i]
+		if road.To <= 0 {
+			road.To = roadExtent(cfg.Grid, road.Axis) - 1
+		}
+		if road.ID == "" {
+			road.ID = fmt.Sprintf("%s-%d", road.Axis, road.At)
+		}
+	}
+
+	if len(network.Intersections) == 0 {
+		// Overlapping segments, such as a turn bay, cross other roads at the
+		// same cells as the road they widen.
+		crossings := map[cell]bool{}
+		for _, v := range network.Roads {
+			if v.Axis != Vertical {
+				continue
+			}
+			for _, h := range network.Roads {
+				if h.Axis != Horizontal {
+					continue
+				}
+				at := cell{x: v.At, y: h.At}
+				if v.At >= h.From && v.At <= h.To && h.At >= v.From && h.At <= v.To && !crossings[at] {
+					crossings[at] = true
+					network.Intersections = append(network.Intersections, IntersectionConfig{X: v.At, Y: h.At})
+				}
+			}
+		}
+		sort.Slice(network.Intersections, func(i, j int) bool {
+			a, b := network.Intersections[i], network.Intersections[j]
+			if a.Y != b.Y {
+				return a.Y < b.Y
+			}
+			return a.X < b.X
+		})
+	}
+	for i := range network.Intersections {
+		if network.Intersections[i].ID == "" {
+			network.Intersections[i].ID = fmt.Sprintf("x%d-y%d", network.Intersections[i].X, network.Intersections[i].Y)
+		}
+	}
+	return network
+}
+
+func roadExtent(grid GridConfig, axis Axis) int {
+	if axis == Vertical {
+		return grid.Height
+	}
+	return grid.Width
+}
+
+func validateNetwork(grid GridConfig, network NetworkConfig) error {
+	roadIDs := map[string]bool{}
+	for _, road := range network.Roads {
+		if road.Axis != Horizontal && road.Axis != Vertical {
+			return fmt.Errorf("road %q has unsupported axis %q", road.ID, road.Axis)
+		}
+		if roadIDs[road.ID] {
+			return fmt.Errorf("duplicate road id %q", road.ID)
+		}
+		roadIDs[road.ID] = true
+
+		across := grid.Height
+		if road.Axis == Vertical {
+			across = grid.Width
+		}
+		if road.At < 0 || road.At >= across {
+			return fmt.Errorf("road %q is outside grid", road.ID)
+		}
+		if road.From < 0 || road.To >= roadExtent(grid, road.Axis) || road.From > road.To {
+			return fmt.Errorf("road %q segment %d..%d is invalid", road.ID, road.From, road.To)
+		}
+		if road.Lanes < 0 {
+			return fmt.Errorf("road %q lanes must be >= 0", road.ID)
+		}
+		if road.Direction != "" && (!validDirection(road.Direction) || axisOf(road.Direction) != road.Axis) {
+			return fmt.Errorf("road %q direction %q does not run along its %s axis", road.ID, road.Direction, road.Axis)
+		}
+	}
+
+	if len(network.Intersections) == 0 {
+		return fmt.Errorf("network has no intersections")
+	}
+	intersectionIDs := map[string]bool{}
+	for _, in := range network.Intersections {
+		if intersectionIDs[in.ID] {
+			return fmt.Errorf("duplicate intersection id %q", in.ID)
+		}
+		intersectionIDs[in.ID] = true
+		if in.X < 0 || in.X >= grid.Width || in.Y < 0 || in.Y >= grid.Height {
+			return fmt.Errorf("intersection %q is outside grid", in.ID)
+		}
+		if !network.onRoad(in.X, in.Y, Vertical) || !network.onRoad(in.X, in.Y, Horizontal) {
+			return fmt.Errorf("intersection %q must be at a crossing of a vertical and a horizontal road", in.ID)
+		}
+		if in.Offset < 0 {
+			return fmt.Errorf("intersection %q offset must be >= 0", in.ID)
+		}
+		if o := in.Signal; o != nil {
+			if (o.VerticalGreenSteps != nil && *o.VerticalGreenSteps <= 0) || (o.HorizontalGreenSteps != nil && *o.HorizontalGreenSteps <= 0) {
+				return fmt.Errorf("intersection %q signal green durations must be > 0", in.ID)
+			}
+			if (o.YellowSteps != nil && *o.YellowSteps < 0) || (o.AllRedSteps != nil && *o.AllRedSteps < 0) {
+				return fmt.Errorf("intersection %q signal durations must be >= 0", in.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func (n NetworkConfig) onRoad(x, y int, axis Axis) bool {
+	for _, road := range n.Roads {
+		if road.Axis == axis && road.covers(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lanes returns the number of lanes a vehicle heading in heading has at
+// (x, y), or 0 when the cell is not on a road along its axis.
+func (n NetworkConfig) Lanes(x, y int, heading Direction) int {
+	lanes := 0
+	axis := axisOf(heading)
+	for _, road := range n.Roads {
+		if road.Axis != axis || !road.covers(x, y) {
+			continue
+		}
+		if road.Direction != "" && road.Direction != heading {
+			lanes = max(lanes, 1)
+			continue
+		}
+		lanes = max(lanes, road.Lanes, 1)
+	}
+	return lanes
+}
+
+// multiLane reports whether any road has more than one lane.
+func (n NetworkConfig) multiLane() bool {
+	for _, road := range n.Roads {
+		if road.Lanes > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r RoadConfig) covers(x, y int) bool {
+	if r.Axis == Vertical {
+		return x == r.At && y >= r.From && y <= r.To
+	}
+	return y == r.At && x >= r.From && x <= r.To
+}
+
+// intersectionSignal returns the signal plan of an intersection: the global
+// plan with every setting the override sets replaced.
+func intersectionSignal(global SignalConfig, in IntersectionConfig) SignalConfig {
+	signal := global
+	o := in.Signal
+	if o == nil {
+		return signal
+	}
+	if o.Controller != "" {
+		signal.Controller = o.Controller
+	}
+	override := func(dst *int, src *int) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	override(&signal.VerticalGreenSteps, o.VerticalGreenSteps)
+	override(&signal.HorizontalGreenSteps, o.HorizontalGreenSteps)
+	override(&signal.YellowSteps, o.YellowSteps)
+	override(&signal.AllRedSteps, o.AllRedSteps)
+	override(&signal.MinGreenSteps, o.MinGreenSteps)
+	override(&signal.MaxGreenSteps, o.MaxGreenSteps)
+	override(&signal.GapSteps, o.GapSteps)
+	override(&signal.DetectorCells, o.DetectorCells)
+	override(&signal.WalkSteps, o.WalkSteps)
+	override(&signal.FlashingDontWalkSteps, o.FlashingDontWalkSteps)
+	if o.PedestrianPhase != "" {
+		signal.PedestrianPhase = o.PedestrianPhase
+	}
+	return signal
+}
+
+// spawnLanes flattens the per-direction lanes and the extra entries into one
+// list ordered by lane id. Per-direction lanes use the direction as their id.
+func spawnLanes(spawn SpawnConfig) []LaneSpawnConfig {
+	lanes := make([]LaneSpawnConfig, 0, len(spawn.Lanes)+len(spawn.Entries))
+	for dir, lane := range spawn.Lanes {
+		lane.ID = string(dir)
+		lane.Direction = dir
+		lanes = append(lanes, lane)
+	}
+	lanes = append(lanes, spawn.Entries...)
+	sort.SliceStable(lanes, func(i, j int) bool { return lanes[i].ID < lanes[j].ID })
+	return lanes
+}
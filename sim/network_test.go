@@ -0,0 +1,179 @@
+package sim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveNetworkDerivesIntersectionsFromRoads(t *testing.T) {
+	cfg := Config{
+		Grid: GridConfig{Width: 20, Height: 12},
+		Network: NetworkConfig{
+			Roads: []RoadConfig{
+				{Axis: Vertical, At: 5},
+				{Axis: Vertical, At: 14},
+				{Axis: Horizontal, At: 3},
+				{Axis: Horizontal, At: 8, From: 0, To: 9},
+			},
+		},
+	}
+
+	network := resolveNetwork(cfg)
+	got := make([]string, 0, len(network.Intersections))
+	for _, in := range network.Intersections {
+		got = append(got, in.ID)
+	}
+	want := []string{"x5-y3", "x14-y3", "x5-y8"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("intersections = %v, want %v", got, want)
+	}
+	if network.Roads[0].To != 11 || network.Roads[2].To != 19 {
+		t.Fatalf("road extents not defaulted: %+v", network.Roads)
+	}
+}
+
+func TestLoadConfigRejectsIntersectionOffRoad(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "invalid.json")
+	content := `{
+		"grid": { "width": 20, "height": 10 },
+		"network": {
+			"roads": [
+				{ "axis": "vertical", "at": 4 },
+				{ "axis": "horizontal", "at": 5 }
+			],
+			"intersections": [ { "id": "a", "x": 6, "y": 5 } ]
+		},
+		"spawn": {
+			"lanes": { "right": { "entry_x": 0, "entry_y": 5, "step_interval": 2 } }
+		}
+	}`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := LoadConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), "crossing of a vertical and a horizontal road") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestIntersectionSignalOverrideKeepsExplicitZero(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "override.json")
+	content := `{
+		"grid": { "width": 20, "height": 7 },
+		"signal": { "vertical_green_steps": 4, "horizontal_green_steps": 4, "yellow_steps": 2, "all_red_steps": 1 },
+		"network": {
+			"intersections": [
+				{ "id": "west", "x": 5, "y": 3 },
+				{ "id": "east", "x": 12, "y": 3, "signal": { "vertical_green_steps": 6, "yellow_steps": 0, "all_red_steps": 0 } }
+			]
+		},
+		"spawn": {
+			"lanes": { "right": { "entry_x": 0, "entry_y": 3, "step_interval": 2 } }
+		}
+	}`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	network := cfg.ResolvedNetwork()
+	west := intersectionSignal(cfg.Signal, network.Intersections[0])
+	east := intersectionSignal(cfg.Signal, network.Intersections[1])
+	if west.YellowSteps != 2 || west.AllRedSteps != 1 || west.VerticalGreenSteps != 4 {
+		t.Fatalf("west signal = %+v, want the global plan", west)
+	}
+	if east.YellowSteps != 0 || east.AllRedSteps != 0 || east.VerticalGreenSteps != 6 || east.HorizontalGreenSteps != 4 {
+		t.Fatalf("east signal = %+v, want no yellow or all-red, a 6-step vertical green and the global horizontal green", east)
+	}
+
+	zero := 0
+	cfg.Network.Intersections[1].Signal.VerticalGreenSteps = &zero
+	if err := validateConfig(cfg); err == nil || !strings.Contains(err.Error(), "green durations must be > 0") {
+		t.Fatalf("validate error = %v, want a rejected zero green", err)
+	}
+}
+
+func TestCorridorSignalsRunPerIntersection(t *testing.T) {
+	cfg := Config{
+		Name:  "corridor-test",
+		Steps: 40,
+		Grid:  GridConfig{Width: 20, Height: 7},
+		Network: NetworkConfig{
+			Intersections: []IntersectionConfig{
+				{ID: "west", X: 5, Y: 3},
+				{ID: "east", X: 12, Y: 3, Offset: 3},
+			},
+		},
+		Signal: SignalConfig{VerticalGreenSteps: 3, HorizontalGreenSteps: 3},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Right: {EntryX: 0, EntryY: 3, StepInterval: 2},
+			},
+			Entries: []LaneSpawnConfig{
+				{ID: "east-up", Direction: Up, EntryX: 12, EntryY: 6, StepInterval: 3},
+			},
+		},
+	}
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	report := engine.Run(true, boolPtr(false))
+
+	first := report.Timeline[0].Lights
+	if len(first) != 2 || first[0].VerticalGreen == first[1].VerticalGreen {
+		t.Fatalf("expected offset signals to differ at step 1, got %+v", first)
+	}
+	for _, id := range []string{"west", "east"} {
+		stats, ok := report.Metrics.IntersectionStats[id]
+		if !ok {
+			t.Fatalf("missing stats for intersection %q", id)
+		}
+		if stats.VehiclesServed == 0 || stats.BlockedBySignal == 0 {
+			t.Fatalf("intersection %q stats = %+v, want served and signal blocks", id, stats)
+		}
+	}
+	if report.Metrics.DirectionStats[Up].Spawned == 0 {
+		t.Fatalf("expected spawn entry to produce vehicles")
+	}
+}
+
+func TestVehicleLeavesAtEndOfRoadSegment(t *testing.T) {
+	cfg := Config{
+		Name:  "segment-test",
+		Steps: 1,
+		Grid:  GridConfig{Width: 20, Height: 10},
+		Network: NetworkConfig{
+			Roads: []RoadConfig{
+				{Axis: Horizontal, At: 5},
+				{Axis: Vertical, At: 10, From: 3, To: 7},
+			},
+		},
+		Signal: SignalConfig{VerticalGreenSteps: 5, HorizontalGreenSteps: 5},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Up: {EntryX: 10, EntryY: 7},
+			},
+		},
+	}
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	engine.vehicles = []Vehicle{{ID: 1, X: 10, Y: 3, Direction: Up, SpawnStep: 1}}
+
+	engine.moveVehicles(0)
+
+	if len(engine.vehicles) != 0 || engine.stats.dirDone[Up] != 1 {
+		t.Fatalf("expected vehicle to leave at segment end, got %+v", engine.vehicles)
+	}
+}
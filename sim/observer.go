@@ -0,0 +1,370 @@
+package sim
+
+// BlockReason tells why a vehicle could not move in a step.
+type BlockReason string
+
+const (
+	BlockedBySignal      BlockReason = "signal"
+	BlockedByTraffic     BlockReason = "traffic"
+	BlockedByPedestrians BlockReason = "pedestrians"
+)
+
+// ConflictKind classifies a Conflict.
+type ConflictKind string
+
+const (
+	// ConflictCollision is a set of vehicles that tried to enter the same
+	// cell, or the same intersection on crossing paths, and were held back.
+	ConflictCollision ConflictKind = "potential_collision"
+	// ConflictClearance is a vehicle entering an intersection that a
+	// conflicting movement is still clearing.
+	ConflictClearance ConflictKind = "clearance"
+)
+
+// Conflict describes a potential collision. Intersection is empty when the
+// conflict happened outside an intersection box.
+type Conflict struct {
+	Kind         ConflictKind `json:"kind"`
+	Intersection string       `json:"intersection,omitempty"`
+	X            int          `json:"x"`
+	Y            int          `json:"y"`
+	Vehicles     []int        `json:"vehicles"`
+}
+
+// Move describes a vehicle advancing. Cells is the number of cells it
+// covered, which is zero while a vehicle slower than a cell per step is still
+// on its way to the next one. Intersection is set when the move entered an
+// intersection box, and OnYellow when it did so on yellow.
+type Move struct {
+	Vehicle      Vehicle `json:"vehicle"`
+	FromX        int     `json:"from_x"`
+	FromY        int     `json:"from_y"`
+	Cells        int     `json:"cells"`
+	Intersection string  `json:"intersection,omitempty"`
+	OnYellow     bool    `json:"on_yellow,omitempty"`
+}
+
+// Observer receives the events of a simulation as they happen. step is the
+// 1-based number of the step being simulated, matching SignalState.Step and
+// the timeline. Vehicles are passed by value in their state after the event.
+//
+// Within a step the engine spawns vehicles (OnSpawn), lets them change lanes
+// (OnLaneChange), moves pedestrians (OnCrossing), moves vehicles (OnConflict
+// while resolving, then OnMove, OnExit or OnBlocked per vehicle), calls
+// OnStepEnd with the signals the vehicles moved under, and finally lets the
+// controllers pick the next phases (OnPhaseChange). OnExit gets the cells the
+// vehicle covered in its last step, including the one off the grid.
+type Observer interface {
+	OnSpawn(step int, v Vehicle)
+	OnLaneChange(step int, change LaneChange)
+	OnCrossing(step int, crossing PedestrianCrossing)
+	OnMove(step int, move Move)
+	OnBlocked(step int, v Vehicle, reason BlockReason, intersection string)
+	OnExit(step int, v Vehicle, tripSteps, cells int)
+	OnConflict(step int, conflict Conflict)
+	OnPhaseChange(step int, intersection string, from, to SignalPhase)
+	OnStepEnd(step int, lights []TrafficLight)
+}
+
+// NopObserver ignores every event. Embed it to implement only the callbacks
+// you need.
+type NopObserver struct{}
+
+func (NopObserver) OnSpawn(int, Vehicle)                                {}
+func (NopObserver) OnLaneChange(int, LaneChange)                        {}
+func (NopObserver) OnCrossing(int, PedestrianCrossing)                  {}
+func (NopObserver) OnMove(int, Move)                                    {}
+func (NopObserver) OnBlocked(int, Vehicle, BlockReason, string)         {}
+func (NopObserver) OnExit(int, Vehicle, int, int)                       {}
+func (NopObserver) OnConflict(int, Conflict)                            {}
+func (NopObserver) OnPhaseChange(int, string, SignalPhase, SignalPhase) {}
+func (NopObserver) OnStepEnd(int, []TrafficLight)                       {}
+
+// AddObserver registers o to receive events from the next step on. Observers
+// are called in the order they were added, after the built-in metrics.
+func (e *Engine) AddObserver(o Observer) {
+	e.observers = append(e.observers, o)
+}
+
+func (e *Engine) emitSpawn(step int, v Vehicle) {
+	for _, o := range e.observers {
+		o.OnSpawn(step, v)
+	}
+}
+
+func (e *Engine) emitLaneChange(step int, change LaneChange) {
+	for _, o := range e.observers {
+		o.OnLaneChange(step, change)
+	}
+}
+
+func (e *Engine) emitCrossing(step int, crossing PedestrianCrossing) {
+	for _, o := range e.observers {
+		o.OnCrossing(step, crossing)
+	}
+}
+
+func (e *Engine) emitMove(step int, move Move) {
+	for _, o := range e.observers {
+		o.OnMove(step, move)
+	}
+}
+
+func (e *Engine) emitBlocked(step int, v Vehicle, reason BlockReason, intersection string) {
+	for _, o := range e.observers {
+		o.OnBlocked(step, v, reason, intersection)
+	}
+}
+
+func (e *Engine) emitExit(step int, v Vehicle, tripSteps, cells int) {
+	for _, o := range e.observers {
+		o.OnExit(step, v, tripSteps, cells)
+	}
+}
+
+func (e *Engine) emitConflict(step int, conflict Conflict) {
+	for _, o := range e.observers {
+		o.OnConflict(step, conflict)
+	}
+}
+
+func (e *Engine) emitPhaseChange(step int, intersection string, from, to SignalPhase) {
+	for _, o := range e.observers {
+		o.OnPhaseChange(step, intersection, from, to)
+	}
+}
+
+func (e *Engine) emitStepEnd(step int) {
+	if len(e.observers) == 0 {
+		return
+	}
+	lights := e.lights()
+	for _, o := range e.observers {
+		o.OnStepEnd(step, lights)
+	}
+}
+
+// metricsObserver accumulates the counters behind Metrics. Every engine
+// registers one as its first observer.
+type metricsObserver struct {
+	totalVehicleStep   int
+	totalWaitEnded     int
+	totalTripEnded     int
+	dirWaitEnded       map[Direction]int
+	dirTripEnded       map[Direction]int
+	dirDone            map[Direction]int
+	dirSpawn           map[Direction]int
+	dirWaits           map[Direction][]int
+	dirTrips           map[Direction][]int
+	moveWaitEnded      map[movementKey]int
+	moveTripEnded      map[movementKey]int
+	moveDone           map[movementKey]int
+	moveSpawn          map[movementKey]int
+	classWaitEnded     map[string]int
+	classTripEnded     map[string]int
+	classDone          map[string]int
+	classSpawn         map[string]int
+	classWaits         map[string][]int
+	classTrips         map[string][]int
+	blockedSignal      int
+	blockedTraffic     int
+	blockedPedestrians int
+	potentialCrash     int
+	yellowEntries      int
+	clearanceCrash     int
+	totalDistance      int
+	laneChanges        int
+	mandatoryChanges   int
+	roadLanes          map[Direction][]RoadLaneStats
+	laneQueued         map[roadLaneKey]int
+	intersections      map[string]*IntersectionStats
+	lastGreen          map[string]SignalPhase
+	served             map[string]int
+	queued             map[string]int
+	pedestrianWaits    map[string][]int
+}
+
+func newMetricsObserver(intersections []*intersectionState) *metricsObserver {
+	m := &metricsObserver{
+		dirWaitEnded:    map[Direction]int{},
+		dirTripEnded:    map[Direction]int{},
+		dirDone:         map[Direction]int{},
+		dirSpawn:        map[Direction]int{},
+		dirWaits:        map[Direction][]int{},
+		dirTrips:        map[Direction][]int{},
+		moveWaitEnded:   map[movementKey]int{},
+		moveTripEnded:   map[movementKey]int{},
+		moveDone:        map[movementKey]int{},
+		moveSpawn:       map[movementKey]int{},
+		classWaitEnded:  map[string]int{},
+		classTripEnded:  map[string]int{},
+		classDone:       map[string]int{},
+		classSpawn:      map[string]int{},
+		classWaits:      map[string][]int{},
+		classTrips:      map[string][]int{},
+		roadLanes:       map[Direction][]RoadLaneStats{},
+		laneQueued:      map[roadLaneKey]int{},
+		intersections:   make(map[string]*IntersectionStats, len(intersections)),
+		lastGreen:       make(map[string]SignalPhase, len(intersections)),
+		served:          map[string]int{},
+		queued:          map[string]int{},
+		pedestrianWaits: map[string][]int{},
+	}
+	for _, in := range intersections {
+		m.intersections[in.id] = &IntersectionStats{X: in.x, Y: in.y}
+		m.lastGreen[in.id] = in.light.LastGreen
+	}
+	return m
+}
+
+func (m *metricsObserver) completed() int {
+	completed := 0
+	for _, done := range m.dirDone {
+		completed += done
+	}
+	return completed
+}
+
+func (m *metricsObserver) OnSpawn(_ int, v Vehicle) {
+	m.dirSpawn[v.Direction]++
+	m.moveSpawn[movementKey{dir: v.Direction, movement: v.Movement}]++
+	m.classSpawn[classOf(v)]++
+}
+
+// roadLane returns the counters of a lane of dir, adding lanes as needed.
+func (m *metricsObserver) roadLane(dir Direction, lane int) *RoadLaneStats {
+	lanes := m.roadLanes[dir]
+	for len(lanes) <= lane {
+		lanes = append(lanes, RoadLaneStats{Lane: len(lanes)})
+	}
+	m.roadLanes[dir] = lanes
+	return &lanes[lane]
+}
+
+func (m *metricsObserver) OnLaneChange(_ int, change LaneChange) {
+	m.laneChanges++
+	if change.Mandatory {
+		m.mandatoryChanges++
+	}
+	m.roadLane(change.Vehicle.Direction, change.Vehicle.RoadLane).LaneChangesIn++
+}
+
+func (m *metricsObserver) OnCrossing(_ int, crossing PedestrianCrossing) {
+	m.pedestrianWaits[crossing.Crosswalk] = append(m.pedestrianWaits[crossing.Crosswalk], crossing.Wait)
+}
+
+func (m *metricsObserver) OnMove(_ int, move Move) {
+	m.totalVehicleStep++
+	m.totalDistance += move.Cells
+	if move.Intersection == "" {
+		return
+	}
+	m.roadLane(move.Vehicle.Direction, move.Vehicle.RoadLane).Served++
+	m.intersections[move.Intersection].VehiclesServed++
+	m.served[move.Intersection]++
+	if move.OnYellow {
+		m.intersections[move.Intersection].YellowEntries++
+		m.yellowEntries++
+	}
+}
+
+func (m *metricsObserver) OnBlocked(_ int, v Vehicle, reason BlockReason, intersection string) {
+	m.totalVehicleStep++
+	m.roadLane(v.Direction, v.RoadLane).BlockedSteps++
+	m.laneQueued[roadLaneKey{dir: v.Direction, lane: v.RoadLane}]++
+	switch reason {
+	case BlockedBySignal:
+		m.blockedSignal++
+		m.intersections[intersection].BlockedBySignal++
+	case BlockedByTraffic:
+		m.blockedTraffic++
+	case BlockedByPedestrians:
+		m.blockedPedestrians++
+	}
+	if intersection != "" {
+		m.queued[intersection]++
+	}
+}
+
+func (m *metricsObserver) OnExit(_ int, v Vehicle, tripSteps, cells int) {
+	m.totalVehicleStep++
+	m.totalDistance += cells
+	key := movementKey{dir: v.Direction, movement: v.Movement}
+	if key.movement == "" {
+		key.movement = Through
+	}
+	m.totalTripEnded += tripSteps
+	m.totalWaitEnded += v.WaitSteps
+	m.dirWaitEnded[v.Direction] += v.WaitSteps
+	m.dirTripEnded[v.Direction] += tripSteps
+	m.dirDone[v.Direction]++
+	m.dirWaits[v.Direction] = append(m.dirWaits[v.Direction], v.WaitSteps)
+	m.dirTrips[v.Direction] = append(m.dirTrips[v.Direction], tripSteps)
+	m.moveWaitEnded[key] += v.WaitSteps
+	m.moveTripEnded[key] += tripSteps
+	m.moveDone[key]++
+	class := classOf(v)
+	m.classWaitEnded[class] += v.WaitSteps
+	m.classTripEnded[class] += tripSteps
+	m.classDone[class]++
+	m.classWaits[class] = append(m.classWaits[class], v.WaitSteps)
+	m.classTrips[class] = append(m.classTrips[class], tripSteps)
+}
+
+func classOf(v Vehicle) string {
+	if v.Class == "" {
+		return DefaultClass
+	}
+	return v.Class
+}
+
+func (m *metricsObserver) OnConflict(_ int, conflict Conflict) {
+	var stats *IntersectionStats
+	if conflict.Intersection != "" {
+		stats = m.intersections[conflict.Intersection]
+	}
+	switch conflict.Kind {
+	case ConflictCollision:
+		m.potentialCrash++
+		if stats != nil {
+			stats.PotentialCollisions++
+		}
+	case ConflictClearance:
+		m.clearanceCrash++
+		if stats != nil {
+			stats.ClearanceConflicts++
+		}
+	}
+}
+
+func (m *metricsObserver) OnPhaseChange(_ int, intersection string, _, to SignalPhase) {
+	if !to.IsGreen() {
+		return
+	}
+	if to != m.lastGreen[intersection] {
+		m.intersections[intersection].PhaseSwitches++
+	}
+	m.lastGreen[intersection] = to
+}
+
+// OnStepEnd closes the per-step intersection counters. Clearance steps in
+// which nobody used the box are lost time.
+func (m *metricsObserver) OnStepEnd(_ int, lights []TrafficLight) {
+	for _, light := range lights {
+		stats := m.intersections[light.Intersection]
+		if !light.Phase.IsGreen() && m.served[light.Intersection] == 0 {
+			stats.LostTimeSteps++
+		}
+		if queue := m.queued[light.Intersection]; queue > stats.MaxQueue {
+			stats.MaxQueue = queue
+		}
+	}
+	for key, queue := range m.laneQueued {
+		if stats := m.roadLane(key.dir, key.lane); queue > stats.MaxQueue {
+			stats.MaxQueue = queue
+		}
+	}
+	clear(m.served)
+	clear(m.queued)
+	clear(m.laneQueued)
+}
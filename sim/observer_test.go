@@ -0,0 +1,104 @@
+package sim
+
+import "testing"
+
+type countingObserver struct {
+	NopObserver
+	spawns    int
+	moves     int
+	exits     int
+	cells     int
+	blocked   map[BlockReason]int
+	conflicts map[ConflictKind]int
+	greens    int
+	steps     int
+}
+
+func (c *countingObserver) OnSpawn(int, Vehicle) { c.spawns++ }
+func (c *countingObserver) OnMove(_ int, move Move) {
+	c.moves++
+	c.cells += move.Cells
+}
+func (c *countingObserver) OnExit(_ int, _ Vehicle, _, cells int) {
+	c.exits++
+	c.cells += cells
+}
+func (c *countingObserver) OnBlocked(_ int, _ Vehicle, reason BlockReason, _ string) {
+	c.blocked[reason]++
+}
+func (c *countingObserver) OnConflict(_ int, conflict Conflict) {
+	c.conflicts[conflict.Kind]++
+}
+func (c *countingObserver) OnPhaseChange(_ int, _ string, _, to SignalPhase) {
+	if to.IsGreen() {
+		c.greens++
+	}
+}
+func (c *countingObserver) OnStepEnd(int, []TrafficLight) { c.steps++ }
+
+func TestObserverSeesEventsBehindMetrics(t *testing.T) {
+	// Cellular cars move up to three cells per step, so they can leave the
+	// grid after covering more than one cell in their last step.
+	for _, path := range []string{"../configs/turning.json", "../configs/cellular.json"} {
+		t.Run(path, func(t *testing.T) {
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("load config: %v", err)
+			}
+			engine, err := NewEngine(cfg)
+			if err != nil {
+				t.Fatalf("new engine: %v", err)
+			}
+			obs := &countingObserver{blocked: map[BlockReason]int{}, conflicts: map[ConflictKind]int{}}
+			engine.AddObserver(obs)
+			m := engine.Run(false, boolPtr(false)).Metrics
+
+			if obs.steps != cfg.Steps {
+				t.Fatalf("step ends = %d, want %d", obs.steps, cfg.Steps)
+			}
+			if obs.spawns != m.VehiclesSpawned || obs.exits != m.VehiclesCompleted {
+				t.Fatalf("spawns=%d exits=%d, want %d and %d", obs.spawns, obs.exits, m.VehiclesSpawned, m.VehiclesCompleted)
+			}
+			if obs.cells != m.TotalDistance {
+				t.Fatalf("cells moved and exited = %d, want total distance %d", obs.cells, m.TotalDistance)
+			}
+			if obs.blocked[BlockedBySignal] != m.BlockedBySignal || obs.blocked[BlockedByTraffic] != m.BlockedByTraffic {
+				t.Fatalf("blocked = %v, want signal=%d traffic=%d", obs.blocked, m.BlockedBySignal, m.BlockedByTraffic)
+			}
+			if obs.conflicts[ConflictCollision] != m.PotentialCollisions || obs.conflicts[ConflictClearance] != m.ClearanceConflicts {
+				t.Fatalf("conflicts = %v, want collisions=%d clearance=%d", obs.conflicts, m.PotentialCollisions, m.ClearanceConflicts)
+			}
+			if obs.greens < m.PhaseSwitches {
+				t.Fatalf("green phase changes = %d, want at least %d switches", obs.greens, m.PhaseSwitches)
+			}
+		})
+	}
+}
+
+func TestMetricsObserverCountsLostTimeAndQueues(t *testing.T) {
+	in := &intersectionState{id: "a", x: 3, y: 4, light: newTrafficLight("a")}
+	m := newMetricsObserver([]*intersectionState{in})
+
+	m.OnBlocked(1, Vehicle{}, BlockedBySignal, "a")
+	m.OnBlocked(1, Vehicle{}, BlockedByTraffic, "a")
+	m.OnStepEnd(1, []TrafficLight{{Intersection: "a", Phase: PhaseVerticalYellow}})
+	m.OnMove(2, Move{Intersection: "a", OnYellow: true})
+	m.OnStepEnd(2, []TrafficLight{{Intersection: "a", Phase: PhaseVerticalYellow}})
+	m.OnPhaseChange(2, "a", PhaseVerticalYellow, PhaseHorizontalGreen)
+	m.OnStepEnd(3, []TrafficLight{{Intersection: "a", Phase: PhaseHorizontalGreen}})
+
+	got := *m.intersections["a"]
+	want := IntersectionStats{
+		X:               3,
+		Y:               4,
+		VehiclesServed:  1,
+		BlockedBySignal: 1,
+		YellowEntries:   1,
+		PhaseSwitches:   1,
+		LostTimeSteps:   1,
+		MaxQueue:        2,
+	}
+	if got != want {
+		t.Fatalf("intersection stats = %+v, want %+v", got, want)
+	}
+}
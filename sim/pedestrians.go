@@ -0,0 +1,325 @@
+package sim
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Pedestrian phasing.
+const (
+	PedestriansConcurrent = "concurrent"
+	PedestriansExclusive  = "exclusive"
+)
+
+// PedestrianSignal is what a crosswalk shows. Pedestrians only step onto the
+// crosswalk on walk; those already on it finish crossing whatever it shows.
+type PedestrianSignal string
+
+const (
+	Walk             PedestrianSignal = "walk"
+	FlashingDontWalk PedestrianSignal = "flashing_dont_walk"
+	DontWalk         PedestrianSignal = "dont_walk"
+)
+
+// PedestrianConfig adds crosswalks to the intersections. A pedestrian needs
+// StepsPerLane steps, 3 by default, for every lane of the road it crosses.
+type PedestrianConfig struct {
+	Crosswalks   []CrosswalkConfig `json:"crosswalks,omitempty"`
+	StepsPerLane int               `json:"steps_per_lane,omitempty"`
+}
+
+// CrosswalkConfig places crosswalks on the Legs of an intersection, or of
+// every intersection when Intersection is empty. A leg is named after the
+// side of the box it lies on, so the "up" crosswalk crosses the road above
+// the box. Without Legs every leg with a road gets a crosswalk.
+//
+// Pedestrians arrive the way vehicles do on a fixed lane: one every
+// StepInterval steps, or as many as a demand profile gives for the step.
+// The profile column defaults to the leg.
+type CrosswalkConfig struct {
+	Intersection  string      `json:"intersection,omitempty"`
+	Legs          []Direction `json:"legs,omitempty"`
+	StepInterval  int         `json:"step_interval"`
+	ProfileCSV    string      `json:"profile_csv,omitempty"`
+	ProfileColumn string      `json:"profile_column,omitempty"`
+}
+
+// CrosswalkState is a crosswalk during a run. Waiting holds the step each
+// pedestrian waiting at the curb arrived in and Crossing the steps each
+// pedestrian on the crosswalk still needs to reach the other side.
+type CrosswalkState struct {
+	ID            string           `json:"id"`
+	Intersection  string           `json:"intersection"`
+	Leg           Direction        `json:"leg"`
+	Interval      int              `json:"interval"`
+	CrossingSteps int              `json:"crossing_steps"`
+	Signal        PedestrianSignal `json:"signal"`
+	Waiting       []int            `json:"waiting,omitempty"`
+	Crossing      []int            `json:"crossing,omitempty"`
+	Arrived       int              `json:"arrived"`
+	MaxWaiting    int              `json:"max_waiting"`
+	Profile       DemandProfile    `json:"profile,omitempty"`
+	intersection  int
+}
+
+// PedestrianCrossing describes a pedestrian stepping onto a crosswalk after
+// waiting Wait steps at the curb.
+type PedestrianCrossing struct {
+	Crosswalk    string    `json:"crosswalk"`
+	Intersection string    `json:"intersection"`
+	Leg          Direction `json:"leg"`
+	Wait         int       `json:"wait"`
+}
+
+// PedestrianStats are the counters of the crosswalks. A pedestrian's wait
+// runs from its arrival to the step it steps onto the crosswalk, and
+// Crossed counts the pedestrians that did. BlockedVehicles counts the steps
+// vehicles were held back for pedestrians.
+type PedestrianStats struct {
+	Arrived          int                       `json:"arrived"`
+	Crossed          int                       `json:"crossed"`
+	Waiting          int                       `json:"waiting"`
+	AverageWait      float64                   `json:"average_wait"`
+	WaitDistribution Distribution              `json:"wait_distribution"`
+	BlockedVehicles  int                       `json:"blocked_vehicles"`
+	Crosswalks       map[string]CrosswalkStats `json:"crosswalks"`
+}
+
+// CrosswalkStats are the counters of one crosswalk. MaxWaiting is the most
+// pedestrians waiting at its curb at once.
+type CrosswalkStats struct {
+	Arrived          int          `json:"arrived"`
+	Crossed          int          `json:"crossed"`
+	MaxWaiting       int          `json:"max_waiting"`
+	AverageWait      float64      `json:"average_wait"`
+	WaitDistribution Distribution `json:"wait_distribution"`
+}
+
+// legOrder is the order crosswalks of an intersection are listed in.
+var legOrder = []Direction{Up, Right, Down, Left}
+
+// crosswalkSpec is a crosswalk resolved from the config.
+type crosswalkSpec struct {
+	CrosswalkConfig
+	id           string
+	intersection int
+	leg          Direction
+	lanes        int
+}
+
+// resolveCrosswalks expands the crosswalk configs into one crosswalk per
+// intersection and leg.
+func resolveCrosswalks(cfg Config, network NetworkConfig) ([]crosswalkSpec, error) {
+	var specs []crosswalkSpec
+	seen := map[string]bool{}
+	for _, crosswalk := range cfg.Pedestrians.Crosswalks {
+		found := false
+		for i, in := range network.Intersections {
+			if crosswalk.Intersection != "" && crosswalk.Intersection != in.ID {
+				continue
+			}
+			found = true
+			legs := crosswalk.Legs
+			if len(legs) == 0 {
+				legs = legOrder
+			}
+			for _, leg := range legs {
+				if !validDirection(leg) {
+					return nil, fmt.Errorf("crosswalk at %q: unsupported leg %q", in.ID, leg)
+				}
+				x, y := nextCell(in.X, in.Y, leg)
+				onGrid := x >= 0 && x < cfg.Grid.Width && y >= 0 && y < cfg.Grid.Height
+				if !onGrid || !network.onRoad(x, y, axisOf(leg)) {
+					if len(crosswalk.Legs) == 0 {
+						continue
+					}
+					return nil, fmt.Errorf("crosswalk at %q: no road on the %s leg", in.ID, leg)
+				}
+				id := in.ID + "/" + string(leg)
+				if seen[id] {
+					return nil, fmt.Errorf("duplicate crosswalk %q", id)
+				}
+				seen[id] = true
+				lanes := network.Lanes(x, y, leg) + network.Lanes(x, y, opposite(leg))
+				specs = append(specs, crosswalkSpec{CrosswalkConfig: crosswalk, id: id, intersection: i, leg: leg, lanes: lanes})
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("crosswalk at unknown intersection %q", crosswalk.Intersection)
+		}
+	}
+	sort.SliceStable(specs, func(a, b int) bool { return specs[a].id < specs[b].id })
+	return specs, nil
+}
+
+func validatePedestrians(cfg Config, network NetworkConfig) error {
+	if cfg.Pedestrians.StepsPerLane < 0 {
+		return fmt.Errorf("pedestrians steps_per_lane must be >= 0")
+	}
+	for _, crosswalk := range cfg.Pedestrians.Crosswalks {
+		if crosswalk.StepInterval < 0 {
+			return fmt.Errorf("crosswalk step_interval must be >= 0")
+		}
+	}
+	_, err := resolveCrosswalks(cfg, network)
+	return err
+}
+
+// pedestrianIntervals returns the walk and flashing don't walk durations of
+// a signal plan.
+func pedestrianIntervals(signal SignalConfig) (walk, flashing int) {
+	walk, flashing = signal.WalkSteps, signal.FlashingDontWalkSteps
+	if walk <= 0 {
+		walk = 4
+	}
+	if flashing <= 0 {
+		flashing = 4
+	}
+	return walk, flashing
+}
+
+// pedestrianSignal returns what the crosswalk on leg shows under light. A
+// crosswalk runs across the road of its leg, so concurrent phasing serves it
+// with the green of the crossing axis, whose turning vehicles then have to
+// yield to it.
+func pedestrianSignal(light TrafficLight, signal SignalConfig, leg Direction) PedestrianSignal {
+	serving := light.Phase == PhasePedestrian
+	if signal.PedestrianPhase != PedestriansExclusive {
+		serving = light.Phase.IsGreen() && !light.Phase.Serves(leg)
+	}
+	walk, flashing := pedestrianIntervals(signal)
+	switch {
+	case !serving:
+		return DontWalk
+	case light.PhaseSteps < walk:
+		return Walk
+	case light.PhaseSteps < walk+flashing:
+		return FlashingDontWalk
+	}
+	return DontWalk
+}
+
+// copy returns a copy of the crosswalk that shares no pedestrians with it.
+func (c *CrosswalkState) copy() CrosswalkState {
+	crosswalk := *c
+	crosswalk.Waiting = append([]int(nil), c.Waiting...)
+	crosswalk.Crossing = append([]int(nil), c.Crossing...)
+	return crosswalk
+}
+
+func (c *CrosswalkState) arrivalsForStep(step int) int {
+	if len(c.Profile) > 0 {
+		return c.Profile[step+1]
+	}
+	if c.Interval <= 0 || (step+1)%c.Interval != 0 {
+		return 0
+	}
+	return 1
+}
+
+// movePedestrians lets the pedestrians on the crosswalks walk on, adds the
+// new arrivals and sends everyone waiting across when the crosswalk shows
+// walk.
+func (e *Engine) movePedestrians(step int) {
+	for _, c := range e.crosswalks {
+		var crossing []int
+		for _, left := range c.Crossing {
+			if left > 1 {
+				crossing = append(crossing, left-1)
+			}
+		}
+		c.Crossing = crossing
+
+		for range c.arrivalsForStep(step) {
+			c.Waiting = append(c.Waiting, step+1)
+			c.Arrived++
+		}
+		in := e.intersections[c.intersection]
+		c.Signal = pedestrianSignal(in.light, in.signal, c.Leg)
+		if c.Signal == Walk {
+			for _, arrived := range c.Waiting {
+				c.Crossing = append(c.Crossing, c.CrossingSteps)
+				e.emitCrossing(step+1, PedestrianCrossing{Crosswalk: c.ID, Intersection: in.id, Leg: c.Leg, Wait: step + 1 - arrived})
+			}
+			c.Waiting = nil
+		}
+		c.MaxWaiting = max(c.MaxWaiting, len(c.Waiting))
+	}
+}
+
+// pedestriansCrossing reports whether a vehicle moving from (x, y) to
+// (nextX, nextY) would cross a crosswalk with pedestrians on it. Crosswalks
+// lie between the box and the first cell of each leg, so vehicles cross one
+// when they enter or leave an intersection.
+func (e *Engine) pedestriansCrossing(x, y, nextX, nextY int, heading Direction) bool {
+	if len(e.crosswalks) == 0 {
+		return false
+	}
+	key := crosswalkKey{intersection: -1}
+	if idx, ok := e.intersectionAt[cell{x: x, y: y}]; ok {
+		key = crosswalkKey{intersection: idx, leg: heading}
+	} else if idx, ok := e.intersectionAt[cell{x: nextX, y: nextY}]; ok {
+		key = crosswalkKey{intersection: idx, leg: opposite(heading)}
+	}
+	c, ok := e.crosswalkAt[key]
+	return ok && len(c.Crossing) > 0
+}
+
+type crosswalkKey struct {
+	intersection int
+	leg          Direction
+}
+
+// waitingPedestrians counts the pedestrians waiting at the crosswalks of
+// every intersection by leg.
+func (e *Engine) waitingPedestrians() []map[Direction]int {
+	waiting := make([]map[Direction]int, len(e.intersections))
+	for i := range waiting {
+		waiting[i] = map[Direction]int{}
+	}
+	for _, c := range e.crosswalks {
+		waiting[c.intersection][c.Leg] += len(c.Waiting)
+	}
+	return waiting
+}
+
+// pedestrianStats fills the pedestrian metrics, which are left out of
+// scenarios without crosswalks.
+func (e *Engine) pedestrianStats() *PedestrianStats {
+	if len(e.crosswalks) == 0 {
+		return nil
+	}
+	stats := &PedestrianStats{
+		BlockedVehicles: e.stats.blockedPedestrians,
+		Crosswalks:      make(map[string]CrosswalkStats, len(e.crosswalks)),
+	}
+	var waits []int
+	total := 0
+	for _, c := range e.crosswalks {
+		samples := e.stats.pedestrianWaits[c.ID]
+		cs := CrosswalkStats{
+			Arrived:          c.Arrived,
+			Crossed:          len(samples),
+			MaxWaiting:       c.MaxWaiting,
+			WaitDistribution: newDistribution(samples),
+		}
+		sum := 0
+		for _, wait := range samples {
+			sum += wait
+		}
+		if cs.Crossed > 0 {
+			cs.AverageWait = float64(sum) / float64(cs.Crossed)
+		}
+		stats.Crosswalks[c.ID] = cs
+		stats.Arrived += c.Arrived
+		stats.Crossed += cs.Crossed
+		stats.Waiting += len(c.Waiting)
+		total += sum
+		waits = append(waits, samples...)
+	}
+	if stats.Crossed > 0 {
+		stats.AverageWait = float64(total) / float64(stats.Crossed)
+	}
+	stats.WaitDistribution = newDistribution(waits)
+	return stats
+}
@@ -0,0 +1,295 @@
+package sim
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// crossingObserver records pedestrians stepping onto crosswalks together with
+// what the crosswalk showed, and the vehicles held back for pedestrians.
+type crossingObserver struct {
+	NopObserver
+	engine    *Engine
+	crossings []PedestrianCrossing
+	signals   []PedestrianSignal
+	turning   int
+	blocked   int
+}
+
+func (o *crossingObserver) OnCrossing(_ int, crossing PedestrianCrossing) {
+	o.crossings = append(o.crossings, crossing)
+	for _, c := range o.engine.crosswalks {
+		if c.ID == crossing.Crosswalk {
+			o.signals = append(o.signals, c.Signal)
+		}
+	}
+}
+
+func (o *crossingObserver) OnBlocked(_ int, v Vehicle, reason BlockReason, _ string) {
+	if reason != BlockedByPedestrians {
+		return
+	}
+	o.blocked++
+	if _, inBox := o.engine.intersectionAt[cell{x: v.X, y: v.Y}]; inBox && v.Movement != Through {
+		o.turning++
+	}
+}
+
+func pedestrianTestConfig(phasing string) Config {
+	return Config{
+		Name:  "pedestrian-test",
+		Steps: 12,
+		Grid:  GridConfig{Width: 20, Height: 10},
+		Signal: SignalConfig{
+			VerticalGreenSteps:    2,
+			HorizontalGreenSteps:  2,
+			YellowSteps:           1,
+			AllRedSteps:           1,
+			WalkSteps:             2,
+			FlashingDontWalkSteps: 1,
+			PedestrianPhase:       phasing,
+		},
+		Pedestrians: PedestrianConfig{
+			Crosswalks: []CrosswalkConfig{{StepInterval: 1}},
+		},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Up: {EntryX: 10, EntryY: 9, StepInterval: 3},
+			},
+		},
+	}
+}
+
+func TestConcurrentCrosswalksHoldTurningVehicles(t *testing.T) {
+	cfg, err := LoadConfig("../configs/pedestrians.json")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	obs := &crossingObserver{engine: engine}
+	engine.AddObserver(obs)
+	stepChecked(t, engine)
+
+	for i, signal := range obs.signals {
+		if signal != Walk {
+			t.Fatalf("pedestrian %+v stepped onto the crosswalk on %s", obs.crossings[i], signal)
+		}
+	}
+	if obs.turning == 0 {
+		t.Fatal("expected turning vehicles to yield to pedestrians inside the box")
+	}
+
+	m := engine.Finalize().Metrics
+	p := m.Pedestrians
+	if p == nil || len(p.Crosswalks) != 4 {
+		t.Fatalf("pedestrian stats = %+v, want four crosswalks", p)
+	}
+	if p.Arrived != 4*cfg.Steps/12 || p.Crossed != len(obs.crossings) || p.Crossed+p.Waiting != p.Arrived {
+		t.Fatalf("arrived = %d, crossed = %d, waiting = %d, observer saw %d crossings", p.Arrived, p.Crossed, p.Waiting, len(obs.crossings))
+	}
+	total := 0
+	for _, crossing := range obs.crossings {
+		total += crossing.Wait
+	}
+	if want := float64(total) / float64(len(obs.crossings)); p.AverageWait != want {
+		t.Fatalf("average pedestrian wait = %.3f, want %.3f", p.AverageWait, want)
+	}
+	if p.BlockedVehicles != obs.blocked {
+		t.Fatalf("blocked vehicles = %d, observer saw %d", p.BlockedVehicles, obs.blocked)
+	}
+	if m.PotentialCollisions != 0 {
+		t.Fatalf("potential collisions = %d", m.PotentialCollisions)
+	}
+}
+
+func TestPedestrianHoldsAgreeAcrossTripsSeriesAndMetrics(t *testing.T) {
+	cfg, err := LoadConfig("../configs/pedestrians.json")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.Series.Window = 10
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	engine.CaptureTrips()
+	report := engine.Run(false, boolPtr(false))
+
+	trips := 0
+	for _, tr := range report.Trips {
+		if tr.SignalWaitSteps+tr.TrafficWaitSteps+tr.PedestrianWaitSteps != tr.WaitSteps {
+			t.Fatalf("trip %d splits %d wait steps into %d+%d+%d", tr.VehicleID, tr.WaitSteps, tr.SignalWaitSteps, tr.TrafficWaitSteps, tr.PedestrianWaitSteps)
+		}
+		trips += tr.PedestrianWaitSteps
+	}
+	series := 0
+	for _, s := range report.Series {
+		series += s.BlockedByPedestrians
+	}
+	blocked := report.Metrics.Pedestrians.BlockedVehicles
+	if blocked == 0 {
+		t.Fatal("expected vehicles held back for pedestrians")
+	}
+	if trips != blocked || series != blocked {
+		t.Fatalf("pedestrian holds: trips %d, series %d, metrics %d", trips, series, blocked)
+	}
+}
+
+func TestCrosswalkIntervalsFollowThePhasing(t *testing.T) {
+	tests := []struct {
+		phasing string
+		// phases are what the light shows during each step and signals what
+		// the crosswalk on the up leg shows then.
+		phases  []SignalPhase
+		signals []PedestrianSignal
+	}{
+		{
+			phasing: PedestriansConcurrent,
+			phases: []SignalPhase{
+				PhaseVerticalGreen, PhaseVerticalGreen, PhaseVerticalGreen, PhaseVerticalYellow, PhaseAllRed,
+				PhaseHorizontalGreen, PhaseHorizontalGreen, PhaseHorizontalYellow, PhaseAllRed,
+			},
+			signals: []PedestrianSignal{
+				DontWalk, DontWalk, DontWalk, DontWalk, DontWalk,
+				Walk, Walk, DontWalk, DontWalk,
+			},
+		},
+		{
+			phasing: PedestriansExclusive,
+			phases: []SignalPhase{
+				PhaseVerticalGreen, PhaseVerticalGreen, PhaseVerticalGreen, PhaseVerticalYellow, PhaseAllRed,
+				PhaseHorizontalGreen, PhaseHorizontalGreen, PhaseHorizontalYellow, PhaseAllRed,
+				PhasePedestrian, PhasePedestrian, PhasePedestrian, PhaseVerticalGreen,
+			},
+			signals: []PedestrianSignal{
+				DontWalk, DontWalk, DontWalk, DontWalk, DontWalk,
+				DontWalk, DontWalk, DontWalk, DontWalk,
+				Walk, Walk, FlashingDontWalk, DontWalk,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.phasing, func(t *testing.T) {
+			cfg := pedestrianTestConfig(tt.phasing)
+			cfg.Steps = len(tt.phases)
+			engine, err := NewEngine(cfg)
+			if err != nil {
+				t.Fatalf("new engine: %v", err)
+			}
+			for i, want := range tt.phases {
+				if phase := engine.State().Lights[0].Phase; phase != want {
+					t.Fatalf("step %d: phase = %s, want %s", i+1, phase, want)
+				}
+				if err := engine.Step(); err != nil {
+					t.Fatalf("step: %v", err)
+				}
+				for _, c := range engine.State().Crosswalks {
+					if c.Leg == Up && c.Signal != tt.signals[i] {
+						t.Fatalf("step %d: up crosswalk shows %s during %s, want %s", i+1, c.Signal, want, tt.signals[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCrosswalkDemandFollowsProfile(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "pedestrians.csv")
+	if err := os.WriteFile(profile, []byte("step,left\n3,3\n12,1\n"), 0o644); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	cfg := pedestrianTestConfig(PedestriansConcurrent)
+	cfg.Pedestrians.Crosswalks = []CrosswalkConfig{{Legs: []Direction{Left}, ProfileCSV: profile}}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	p := engine.Run(false, boolPtr(false)).Metrics.Pedestrians
+
+	// The left crosswalk walks with the vertical green, so the pedestrians
+	// arriving during the flashing don't walk of step 3 wait for the walk of
+	// step 10 and the one arriving in the yellow of step 12 is still waiting
+	// when the run ends.
+	left := p.Crosswalks["center/left"]
+	if left.Arrived != 4 || left.Crossed != 3 || left.MaxWaiting != 3 || p.Waiting != 1 {
+		t.Fatalf("left crosswalk stats = %+v, waiting = %d", left, p.Waiting)
+	}
+	if p.AverageWait != 7 || p.WaitDistribution.Max != 7 {
+		t.Fatalf("pedestrian stats = %+v, want three crossings after waiting 7 steps", p)
+	}
+}
+
+func TestActuatedControllerServesWaitingPedestrians(t *testing.T) {
+	for _, phasing := range []string{PedestriansConcurrent, PedestriansExclusive} {
+		signal := SignalConfig{Controller: ControllerActuated, YellowSteps: 1, MinGreenSteps: 2, PedestrianPhase: phasing}
+		controller, err := newSignalController(signal, 0)
+		if err != nil {
+			t.Fatalf("new controller: %v", err)
+		}
+		light := newTrafficLight("center")
+		light.PhaseSteps = 4
+		state := SignalState{Light: light, Approaches: map[Direction]ApproachState{Up: {SinceDetection: 9}}}
+		if got := controller.NextPhase(state); got != PhaseVerticalGreen {
+			t.Fatalf("%s: NextPhase without demand = %s, want to rest in vertical green", phasing, got)
+		}
+		state.Pedestrians = map[Direction]int{Down: 1}
+		if got := controller.NextPhase(state); got != PhaseVerticalYellow {
+			t.Fatalf("%s: NextPhase with pedestrians waiting = %s, want vertical yellow", phasing, got)
+		}
+	}
+}
+
+func TestValidateConfigRejectsBadCrosswalks(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{
+			name: "unknown intersection",
+			modify: func(cfg *Config) {
+				cfg.Pedestrians.Crosswalks[0].Intersection = "north"
+			},
+			want: `unknown intersection "north"`,
+		},
+		{
+			name: "leg without road",
+			modify: func(cfg *Config) {
+				cfg.Network.Roads = []RoadConfig{{Axis: Vertical, At: 10}, {Axis: Horizontal, At: 5, From: 0, To: 10}}
+				cfg.Network.Intersections = []IntersectionConfig{{ID: "center", X: 10, Y: 5}}
+				cfg.Pedestrians.Crosswalks[0].Legs = []Direction{Right}
+			},
+			want: "no road on the right leg",
+		},
+		{
+			name: "duplicate crosswalk",
+			modify: func(cfg *Config) {
+				cfg.Pedestrians.Crosswalks = append(cfg.Pedestrians.Crosswalks, CrosswalkConfig{Legs: []Direction{Up}})
+			},
+			want: `duplicate crosswalk "center/up"`,
+		},
+		{
+			name: "pedestrian phase",
+			modify: func(cfg *Config) {
+				cfg.Signal.PedestrianPhase = "scramble"
+			},
+			want: `unsupported pedestrian phase "scramble"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := pedestrianTestConfig(PedestriansConcurrent)
+			tt.modify(&cfg)
+			if err := validateConfig(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("validate error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
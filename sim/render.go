@@ -7,15 +7,16 @@ import (
 )
 
 const (
-	colorReset  = "\033[0m"
-	colorBold   = "\033[1m"
-	colorDim    = "\033[2m"
-	colorRed    = "\033[31m"
-	colorGreen  = "\033[32m"
-	colorYellow = "\033[33m"
-	colorBlue   = "\033[34m"
-	colorCyan   = "\033[36m"
-	colorGray   = "\033[90m"
+	colorReset   = "\033[0m"
+	colorBold    = "\033[1m"
+	colorDim     = "\033[2m"
+	colorRed     = "\033[31m"
+	colorGreen   = "\033[32m"
+	colorYellow  = "\033[33m"
+	colorBlue    = "\033[34m"
+	colorMagenta = "\033[35m"
+	colorCyan    = "\033[36m"
+	colorGray    = "\033[90m"
 )
 
 type RenderStats struct {
@@ -23,6 +24,7 @@ type RenderStats struct {
 	Step                 int
 	TotalSteps           int
 	VerticalGreen        bool
+	Phase                SignalPhase
 	SpawnedVehicles      int
 	CompletedVehicles    int
 	ActiveVehicles       int
@@ -36,7 +38,7 @@ type RenderStats struct {
 	LaneActive           map[Direction]int
 }
 
-func RenderGrid(cfg Config, vehicles []Vehicle, light TrafficLight, stats RenderStats) {
+func RenderGrid(cfg Config, vehicles []Vehicle, lights []TrafficLight, stats RenderStats) {
 	width := cfg.Grid.Width
 	height := cfg.Grid.Height
 
@@ -48,38 +50,58 @@ func RenderGrid(cfg Config, vehicles []Vehicle, light TrafficLight, stats Render
 		}
 	}
 
-	ix := width / 2
-	iy := height / 2
-
-	for y := 0; y < height; y++ {
-		grid[y][ix] = '|'
-	}
-	for x := 0; x < width; x++ {
-		grid[iy][x] = '-'
+	network := resolveNetwork(cfg)
+	for _, road := range network.Roads {
+		for pos := road.From; pos <= road.To; pos++ {
+			x, y := pos, road.At
+			ch := '-'
+			if road.Axis == Vertical {
+				x, y = road.At, pos
+				ch = '|'
+			}
+			if x >= 0 && x < width && y >= 0 && y < height {
+				grid[y][x] = ch
+			}
+		}
 	}
-	if light.VerticalGreen {
-		grid[iy][ix] = 'G'
-	} else {
-		grid[iy][ix] = 'R'
+	for i, in := range network.Intersections {
+		if in.X < 0 || in.X >= width || in.Y < 0 || in.Y >= height || i >= len(lights) {
+			continue
+		}
+		grid[in.Y][in.X] = phaseRune(lights[i].Phase)
 	}
 
 	for i := range vehicles {
-		v := vehicles[i]
-		if v.X >= 0 && v.X < width && v.Y >= 0 && v.Y < height {
-			grid[v.Y][v.X] = directionRune(v.Direction)
+		for _, s := range vehicles[i].Segments() {
+			if s.X >= 0 && s.X < width && s.Y >= 0 && s.Y < height {
+				grid[s.Y][s.X] = directionRune(s.Heading)
+			}
 		}
 	}
 
 	fmt.Print("\033[H\033[2J")
 	printHeader(stats)
-	printRoadFrame(grid, light)
-	printFooter(stats)
+	printRoadFrame(grid)
+	printFooter(stats, lights)
 }
 
 func printHeader(stats RenderStats) {
 	phase := colorRed + "HORIZONTAL GREEN" + colorReset
-	if stats.VerticalGreen {
+	switch stats.Phase {
+	case PhaseVerticalGreen:
 		phase = colorGreen + "VERTICAL GREEN" + colorReset
+	case PhaseVerticalYellow:
+		phase = colorYellow + "VERTICAL YELLOW" + colorReset
+	case PhaseHorizontalYellow:
+		phase = colorYellow + "HORIZONTAL YELLOW" + colorReset
+	case PhaseAllRed:
+		phase = colorRed + "ALL RED" + colorReset
+	case PhasePedestrian:
+		phase = colorMagenta + "PEDESTRIANS" + colorReset
+	case "":
+		if stats.VerticalGreen {
+			phase = colorGreen + "VERTICAL GREEN" + colorReset
+		}
 	}
 	fmt.Printf("%s%sTrafficFlowSimulator Terminal Dashboard%s\n", colorBold, colorCyan, colorReset)
 	fmt.Printf("%sScenario:%s %s | %sStep:%s %d/%d | %sPhase:%s %s\n",
@@ -100,7 +122,7 @@ func printHeader(stats RenderStats) {
 	fmt.Println()
 }
 
-func printRoadFrame(grid [][]rune, light TrafficLight) {
+func printRoadFrame(grid [][]rune) {
 	height := len(grid)
 	if height == 0 {
 		return
@@ -124,8 +146,16 @@ func printRoadFrame(grid [][]rune, light TrafficLight) {
 	fmt.Printf("%s+%s+%s\n\n", colorDim, hLine, colorReset)
 }
 
-func printFooter(stats RenderStats) {
+func printFooter(stats RenderStats, lights []TrafficLight) {
 	dirs := []Direction{Up, Down, Left, Right}
+	if len(lights) > 1 {
+		fmt.Printf("%sSignals%s  ", colorBold, colorReset)
+		for _, light := range lights {
+			fmt.Printf("%s=%s  ", light.Intersection, styleCell(phaseRune(light.Phase)))
+		}
+		fmt.Println()
+	}
+
 	fmt.Printf("%sLane Queues%s  ", colorBold, colorReset)
 	for _, d := range dirs {
 		fmt.Printf("%s=%d  ", d, stats.LaneQueue[d])
@@ -141,6 +171,9 @@ func printFooter(stats RenderStats) {
 	legend := []string{
 		colorGreen + "G" + colorReset + "=vertical green",
 		colorRed + "R" + colorReset + "=horizontal green",
+		colorYellow + "Y" + colorReset + "=yellow",
+		colorRed + "X" + colorReset + "=all red",
+		colorMagenta + "P" + colorReset + "=pedestrian",
 		colorCyan + "^/v" + colorReset + "=vertical cars",
 		colorYellow + "</>" + colorReset + "=horizontal cars",
 		colorGray + "|/-" + colorReset + "=roads",
@@ -153,8 +186,12 @@ func styleCell(ch rune) string {
 	switch ch {
 	case 'G':
 		return colorGreen + "G" + colorReset
-	case 'R':
-		return colorRed + "R" + colorReset
+	case 'R', 'X':
+		return colorRed + string(ch) + colorReset
+	case 'Y':
+		return colorYellow + "Y" + colorReset
+	case 'P':
+		return colorMagenta + "P" + colorReset
 	case '^', 'v':
 		return colorCyan + string(ch) + colorReset
 	case '<', '>':
@@ -168,6 +205,21 @@ func styleCell(ch rune) string {
 	}
 }
 
+func phaseRune(phase SignalPhase) rune {
+	switch phase {
+	case PhaseVerticalGreen:
+		return 'G'
+	case PhaseVerticalYellow, PhaseHorizontalYellow:
+		return 'Y'
+	case PhaseAllRed:
+		return 'X'
+	case PhasePedestrian:
+		return 'P'
+	default:
+		return 'R'
+	}
+}
+
 func directionRune(d Direction) rune {
 	switch d {
 	case Up:
@@ -0,0 +1,65 @@
+package sim
+
+// TimelineStats rebuilds the dashboard counters for every snapshot of a saved
+// timeline, so a replay can render any step directly. Distance, blockers,
+// conflicts and lane queues come from the snapshots and stay zero in timelines
+// saved before they were recorded.
+func TimelineStats(cfg Config, timeline []StepSnapshot) []RenderStats {
+	stats := make([]RenderStats, len(timeline))
+	active := map[int]int{}
+	spawned := 0
+	doneSteps := 0
+
+	for i, snap := range timeline {
+		current := make(map[int]int, len(snap.Vehicles))
+		laneActive := map[Direction]int{Up: 0, Down: 0, Left: 0, Right: 0}
+		vehicleSteps := doneSteps
+		for _, v := range snap.Vehicles {
+			present := v.MovedSteps + v.WaitSteps
+			current[v.ID] = present
+			vehicleSteps += present
+			laneActive[v.Direction]++
+			if v.ID > spawned {
+				spawned = v.ID
+			}
+		}
+		// Vehicles that disappeared spent one last step leaving the grid.
+		for id, present := range active {
+			if _, ok := current[id]; ok {
+				continue
+			}
+			doneSteps += present + 1
+			vehicleSteps += present + 1
+		}
+		active = current
+
+		completed := spawned - len(snap.Vehicles)
+		s := RenderStats{
+			ScenarioName:        cfg.Name,
+			Step:                snap.Step,
+			TotalSteps:          cfg.Steps,
+			VerticalGreen:       snap.LightGreen,
+			Phase:               snap.Phase,
+			SpawnedVehicles:     spawned,
+			CompletedVehicles:   completed,
+			ActiveVehicles:      len(snap.Vehicles),
+			BlockedBySignal:     snap.BlockedBySignal,
+			BlockedByTraffic:    snap.BlockedByTraffic,
+			PotentialCollisions: snap.PotentialCollisions,
+			MaxQueueOverall:     snap.MaxQueue,
+			LaneQueue:           map[Direction]int{Up: 0, Down: 0, Left: 0, Right: 0},
+			LaneActive:          laneActive,
+		}
+		for dir, queue := range snap.LaneQueue {
+			s.LaneQueue[dir] = queue
+		}
+		if snap.Step > 0 {
+			s.ThroughputPer100Step = float64(completed) / float64(snap.Step) * 100
+		}
+		if vehicleSteps > 0 {
+			s.AverageNetworkSpeed = float64(snap.Distance) / float64(vehicleSteps)
+		}
+		stats[i] = s
+	}
+	return stats
+}
@@ -0,0 +1,57 @@
+package sim
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestTimelineStatsMatchFinalMetrics(t *testing.T) {
+	for _, path := range []string{"../configs/turning.json", "../configs/cellular.json"} {
+		t.Run(path, func(t *testing.T) {
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("load config: %v", err)
+			}
+			engine, err := NewEngine(cfg)
+			if err != nil {
+				t.Fatalf("new engine: %v", err)
+			}
+			report := engine.Run(true, boolPtr(false))
+
+			path := filepath.Join(t.TempDir(), "report.json")
+			if err := WriteReport(path, report); err != nil {
+				t.Fatalf("write report: %v", err)
+			}
+			loaded, err := LoadReport(path)
+			if err != nil {
+				t.Fatalf("load report: %v", err)
+			}
+			if loaded.Config == nil || loaded.Config.Grid != cfg.Grid {
+				t.Fatalf("report config = %+v, want the scenario grid", loaded.Config)
+			}
+
+			stats := TimelineStats(*loaded.Config, loaded.Timeline)
+			if len(stats) != cfg.Steps {
+				t.Fatalf("stats length = %d, want %d", len(stats), cfg.Steps)
+			}
+			last, m := stats[len(stats)-1], report.Metrics
+			if last.SpawnedVehicles != m.VehiclesSpawned || last.CompletedVehicles != m.VehiclesCompleted || last.ActiveVehicles != m.ActiveVehicles {
+				t.Fatalf("last frame spawned/completed/active = %d/%d/%d, want %d/%d/%d",
+					last.SpawnedVehicles, last.CompletedVehicles, last.ActiveVehicles,
+					m.VehiclesSpawned, m.VehiclesCompleted, m.ActiveVehicles)
+			}
+			if math.Abs(last.AverageNetworkSpeed-m.AverageNetworkSpeed) > 1e-9 {
+				t.Fatalf("last frame speed = %f, want %f", last.AverageNetworkSpeed, m.AverageNetworkSpeed)
+			}
+			if last.BlockedBySignal != m.BlockedBySignal || last.BlockedByTraffic != m.BlockedByTraffic || last.MaxQueueOverall != m.MaxQueueOverall {
+				t.Fatalf("last frame blocked signal/traffic and max queue = %d/%d/%d, want %d/%d/%d",
+					last.BlockedBySignal, last.BlockedByTraffic, last.MaxQueueOverall,
+					m.BlockedBySignal, m.BlockedByTraffic, m.MaxQueueOverall)
+			}
+			if math.Abs(last.ThroughputPer100Step-m.ThroughputPer100Step) > 1e-9 {
+				t.Fatalf("last frame throughput = %f, want %f", last.ThroughputPer100Step, m.ThroughputPer100Step)
+			}
+		})
+	}
+}
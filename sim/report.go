@@ -25,3 +25,15 @@ func WriteReport(path string, report Report) error {
 	}
 	return nil
 }
+
+func LoadReport(path string) (Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Report{}, fmt.Errorf("read report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Report{}, fmt.Errorf("parse report: %w", err)
+	}
+	return report, nil
+}
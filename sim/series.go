@@ -0,0 +1,93 @@
+package sim
+
+// SeriesSample summarizes one window of steps, ending with Step. Event
+// counts are totals over the window. LaneQueues holds, per lane, the longest
+// queue seen in the window: vehicles of the lane stopped on the grid plus
+// vehicles waiting to enter. ActiveVehicles and Phases are the state at the
+// end of the window.
+type SeriesSample struct {
+	Step                 int                    `json:"step"`
+	Steps                int                    `json:"steps"`
+	ActiveVehicles       int                    `json:"active_vehicles"`
+	Spawned              int                    `json:"spawned"`
+	Completed            int                    `json:"completed"`
+	BlockedBySignal      int                    `json:"blocked_by_signal"`
+	BlockedByTraffic     int                    `json:"blocked_by_traffic"`
+	BlockedByPedestrians int                    `json:"blocked_by_pedestrians"`
+	LaneQueues           map[string]int         `json:"lane_queues"`
+	Phases               map[string]SignalPhase `json:"phases"`
+}
+
+// seriesObserver samples the network every Window steps for Report.Series.
+// It reads lane queues and vehicles from the engine at the end of each step.
+type seriesObserver struct {
+	NopObserver
+	engine  *Engine
+	window  int
+	samples []SeriesSample
+	current SeriesSample
+}
+
+func newSeriesObserver(e *Engine, window int) *seriesObserver {
+	return &seriesObserver{engine: e, window: window}
+}
+
+func (s *seriesObserver) OnSpawn(int, Vehicle) {
+	s.current.Spawned++
+}
+
+func (s *seriesObserver) OnExit(int, Vehicle, int, int) {
+	s.current.Completed++
+}
+
+func (s *seriesObserver) OnBlocked(_ int, _ Vehicle, reason BlockReason, _ string) {
+	switch reason {
+	case BlockedBySignal:
+		s.current.BlockedBySignal++
+	case BlockedByTraffic:
+		s.current.BlockedByTraffic++
+	case BlockedByPedestrians:
+		s.current.BlockedByPedestrians++
+	}
+}
+
+func (s *seriesObserver) OnStepEnd(step int, lights []TrafficLight) {
+	queues := make(map[string]int, len(s.engine.laneOrder))
+	for _, id := range s.engine.laneOrder {
+		queues[id] = s.engine.laneStates[id].Queued
+	}
+	for _, v := range s.engine.vehicles {
+		if v.BlockedStep == step {
+			queues[v.Lane]++
+		}
+	}
+	if s.current.LaneQueues == nil {
+		s.current.LaneQueues = make(map[string]int, len(queues))
+	}
+	for id, queue := range queues {
+		s.current.LaneQueues[id] = max(s.current.LaneQueues[id], queue)
+	}
+
+	s.current.Step = step
+	s.current.Steps++
+	s.current.ActiveVehicles = len(s.engine.vehicles)
+	s.current.Phases = make(map[string]SignalPhase, len(lights))
+	for _, light := range lights {
+		s.current.Phases[light.Intersection] = light.Phase
+	}
+	if s.current.Steps == s.window {
+		s.samples = append(s.samples, s.current)
+		s.current = SeriesSample{}
+	}
+}
+
+// series returns the closed windows plus the one still open, if any.
+func (s *seriesObserver) series() []SeriesSample {
+	series := append([]SeriesSample(nil), s.samples...)
+	if s.current.Steps > 0 {
+		open := s.current
+		open.LaneQueues = copyCounts(open.LaneQueues)
+		series = append(series, open)
+	}
+	return series
+}
@@ -0,0 +1,73 @@
+package sim
+
+import "testing"
+
+func TestSeriesAddsUpToMetrics(t *testing.T) {
+	cfg := stepTestConfig()
+	cfg.Series.Window = 7
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	report := engine.Run(false, boolPtr(false))
+
+	// 30 steps in windows of 7 leave a last window of 2 steps.
+	if len(report.Series) != 5 {
+		t.Fatalf("got %d samples, want 5", len(report.Series))
+	}
+	last := report.Series[4]
+	if last.Step != 30 || last.Steps != 2 {
+		t.Fatalf("last sample covers step %d over %d steps, want 30 over 2", last.Step, last.Steps)
+	}
+
+	var spawned, completed, signal, traffic int
+	for _, s := range report.Series {
+		spawned += s.Spawned
+		completed += s.Completed
+		signal += s.BlockedBySignal
+		traffic += s.BlockedByTraffic
+		if len(s.LaneQueues) != 2 || s.Phases["center"] == "" {
+			t.Fatalf("sample at step %d lacks lane queues or phases: %+v", s.Step, s)
+		}
+	}
+	m := report.Metrics
+	if spawned != m.VehiclesSpawned || completed != m.VehiclesCompleted ||
+		signal != m.BlockedBySignal || traffic != m.BlockedByTraffic {
+		t.Fatalf("series totals %d/%d/%d/%d differ from metrics %d/%d/%d/%d",
+			spawned, completed, signal, traffic,
+			m.VehiclesSpawned, m.VehiclesCompleted, m.BlockedBySignal, m.BlockedByTraffic)
+	}
+	if last.ActiveVehicles != m.ActiveVehicles {
+		t.Fatalf("last sample has %d active vehicles, want %d", last.ActiveVehicles, m.ActiveVehicles)
+	}
+}
+
+func TestSeriesIsOffByDefault(t *testing.T) {
+	engine, err := NewEngine(stepTestConfig())
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	if report := engine.Run(false, boolPtr(false)); report.Series != nil {
+		t.Fatalf("expected no series without a window, got %d samples", len(report.Series))
+	}
+}
+
+func TestSeriesQueuesCountStoppedVehicles(t *testing.T) {
+	cfg := stepTestConfig()
+	cfg.Series.Window = 1
+	cfg.Signal.VerticalGreenSteps = 1
+	cfg.Signal.HorizontalGreenSteps = 20
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	report := engine.Run(false, boolPtr(false))
+
+	peak := 0
+	for _, s := range report.Series {
+		peak = max(peak, s.LaneQueues["up"])
+	}
+	if peak < 3 {
+		t.Fatalf("up lane queue peaked at %d behind a long red, want at least 3", peak)
+	}
+}
@@ -0,0 +1,126 @@
+package sim
+
+type SignalPhase string
+
+const (
+	PhaseVerticalGreen    SignalPhase = "vertical_green"
+	PhaseVerticalYellow   SignalPhase = "vertical_yellow"
+	PhaseHorizontalGreen  SignalPhase = "horizontal_green"
+	PhaseHorizontalYellow SignalPhase = "horizontal_yellow"
+	PhaseAllRed           SignalPhase = "all_red"
+	// PhasePedestrian is the exclusive pedestrian phase: every vehicle stops
+	// and every crosswalk may be used.
+	PhasePedestrian SignalPhase = "pedestrian"
+)
+
+func (p SignalPhase) IsGreen() bool {
+	return p == PhaseVerticalGreen || p == PhaseHorizontalGreen
+}
+
+// Serves reports whether vehicles heading in d have green or yellow.
+func (p SignalPhase) Serves(d Direction) bool {
+	if axisOf(d) == Vertical {
+		return p == PhaseVerticalGreen || p == PhaseVerticalYellow
+	}
+	return p == PhaseHorizontalGreen || p == PhaseHorizontalYellow
+}
+
+type TrafficLight struct {
+	Intersection  string      `json:"intersection,omitempty"`
+	Phase         SignalPhase `json:"phase"`
+	PhaseSteps    int         `json:"phase_steps"`
+	LastGreen     SignalPhase `json:"last_green,omitempty"`
+	VerticalGreen bool        `json:"vertical_green"`
+	Timer         int         `json:"timer"`
+}
+
+func newTrafficLight(intersection string) TrafficLight {
+	return TrafficLight{
+		Intersection:  intersection,
+		Phase:         PhaseVerticalGreen,
+		LastGreen:     PhaseVerticalGreen,
+		VerticalGreen: true,
+	}
+}
+
+func (l *TrafficLight) setPhase(phase SignalPhase) {
+	if phase == l.Phase {
+		l.PhaseSteps++
+	} else {
+		l.Phase = phase
+		l.PhaseSteps = 0
+	}
+	if phase.IsGreen() {
+		l.LastGreen = phase
+	}
+	l.VerticalGreen = phase == PhaseVerticalGreen
+}
+
+func (l TrafficLight) green(d Direction) bool {
+	if axisOf(d) == Vertical {
+		return l.Phase == PhaseVerticalGreen
+	}
+	return l.Phase == PhaseHorizontalGreen
+}
+
+func (l TrafficLight) yellow(d Direction) bool {
+	if axisOf(d) == Vertical {
+		return l.Phase == PhaseVerticalYellow
+	}
+	return l.Phase == PhaseHorizontalYellow
+}
+
+// cycleSteps returns the length of a fixed-time cycle.
+func cycleSteps(signal SignalConfig) int {
+	cycle := signal.VerticalGreenSteps + signal.HorizontalGreenSteps + 2*(signal.YellowSteps+signal.AllRedSteps)
+	if signal.PedestrianPhase == PedestriansExclusive {
+		walk, flashing := pedestrianIntervals(signal)
+		cycle += walk + flashing
+	}
+	return cycle
+}
+
+// fixedTimePhase maps a position in the cycle to a phase. The cycle runs
+// vertical green, yellow, all-red, horizontal green, yellow, all-red and,
+// with exclusive pedestrian phasing, the pedestrian phase.
+func fixedTimePhase(signal SignalConfig, timer int) (SignalPhase, bool) {
+	cycle := cycleSteps(signal)
+	if cycle <= 0 {
+		return "", false
+	}
+	stepInCycle := (timer - 1) % cycle
+	type bound struct {
+		end   int
+		phase SignalPhase
+	}
+	bounds := []bound{
+		{signal.VerticalGreenSteps, PhaseVerticalGreen},
+		{signal.YellowSteps, PhaseVerticalYellow},
+		{signal.AllRedSteps, PhaseAllRed},
+		{signal.HorizontalGreenSteps, PhaseHorizontalGreen},
+		{signal.YellowSteps, PhaseHorizontalYellow},
+		{signal.AllRedSteps, PhaseAllRed},
+	}
+	if signal.PedestrianPhase == PedestriansExclusive {
+		walk, flashing := pedestrianIntervals(signal)
+		bounds = append(bounds, bound{walk + flashing, PhasePedestrian})
+	}
+	end := 0
+	for _, b := range bounds {
+		end += b.end
+		if stepInCycle < end {
+			return b.phase, true
+		}
+	}
+	return PhaseAllRed, true
+}
+
+// committedOnYellow implements the dilemma-zone rule. With unit movement a
+// vehicle needs one cell to stop, so only a vehicle that was still moving
+// when it reached the stop line at yellow onset is unable to stop and
+// proceeds. Vehicles that were already stopped, or that arrive later in the
+// yellow interval, stop.
+func committedOnYellow(v Vehicle, light TrafficLight, step int) bool {
+	stopped := v.BlockedStep > 0 && v.BlockedStep == step
+	return light.PhaseSteps == 0 && !stopped
+}
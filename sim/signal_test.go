@@ -0,0 +1,111 @@
+package sim
+
+import "testing"
+
+func TestFixedTimeCycleIncludesClearanceIntervals(t *testing.T) {
+	cfg := Config{
+		Name:  "clearance-cycle",
+		Steps: 9,
+		Grid:  GridConfig{Width: 20, Height: 10},
+		Signal: SignalConfig{
+			VerticalGreenSteps:   2,
+			HorizontalGreenSteps: 2,
+			YellowSteps:          1,
+			AllRedSteps:          1,
+		},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Up: {EntryX: 10, EntryY: 9},
+			},
+		},
+	}
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	report := engine.Run(true, boolPtr(false))
+
+	want := []SignalPhase{
+		PhaseVerticalGreen, PhaseVerticalGreen, PhaseVerticalYellow, PhaseAllRed,
+		PhaseHorizontalGreen, PhaseHorizontalGreen, PhaseHorizontalYellow, PhaseAllRed,
+		PhaseVerticalGreen,
+	}
+	for i, snap := range report.Timeline {
+		if snap.Phase != want[i] {
+			t.Fatalf("step %d: phase = %s, want %s", i+1, snap.Phase, want[i])
+		}
+		if snap.LightGreen != (want[i] == PhaseVerticalGreen) {
+			t.Fatalf("step %d: light_green_vertical = %v during %s", i+1, snap.LightGreen, snap.Phase)
+		}
+	}
+}
+
+func TestYellowDilemmaZone(t *testing.T) {
+	tests := []struct {
+		name        string
+		blockedStep int
+		phaseSteps  int
+		wantEnter   bool
+	}{
+		{name: "moving at yellow onset proceeds", blockedStep: 0, phaseSteps: 0, wantEnter: true},
+		{name: "stopped at yellow onset holds", blockedStep: 3, phaseSteps: 0, wantEnter: false},
+		{name: "arriving late in yellow stops", blockedStep: 0, phaseSteps: 1, wantEnter: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := turnTestEngine(t, PhaseVerticalYellow)
+			engine.intersections[0].light.PhaseSteps = tt.phaseSteps
+			engine.vehicles = []Vehicle{
+				{ID: 1, X: 10, Y: 6, Direction: Up, Heading: Up, SpawnStep: 1, BlockedStep: tt.blockedStep},
+			}
+
+			engine.moveVehicles(3)
+
+			entered := engine.vehicles[0].Y == 5
+			if entered != tt.wantEnter {
+				t.Fatalf("entered = %v, want %v", entered, tt.wantEnter)
+			}
+			if tt.wantEnter && engine.stats.yellowEntries != 1 {
+				t.Fatalf("yellow entries = %d, want 1", engine.stats.yellowEntries)
+			}
+			if !tt.wantEnter && engine.stats.blockedSignal != 1 {
+				t.Fatalf("blocked by signal = %d, want 1", engine.stats.blockedSignal)
+			}
+		})
+	}
+}
+
+func TestAllRedRemovesClearanceConflicts(t *testing.T) {
+	run := func(allRed int) Metrics {
+		cfg := Config{
+			Name:  "clearance",
+			Steps: 200,
+			Grid:  GridConfig{Width: 21, Height: 11},
+			Signal: SignalConfig{
+				VerticalGreenSteps:   4,
+				HorizontalGreenSteps: 4,
+				AllRedSteps:          allRed,
+			},
+			Spawn: SpawnConfig{
+				Lanes: map[Direction]LaneSpawnConfig{
+					Up:    {EntryX: 10, EntryY: 10, StepInterval: 1},
+					Right: {EntryX: 0, EntryY: 5, StepInterval: 1},
+				},
+			},
+		}
+		engine, err := NewEngine(cfg)
+		if err != nil {
+			t.Fatalf("new engine: %v", err)
+		}
+		return engine.Run(false, boolPtr(false)).Metrics
+	}
+
+	if got := run(0).ClearanceConflicts; got == 0 {
+		t.Fatalf("expected clearance conflicts without all-red interval")
+	}
+	if got := run(1).ClearanceConflicts; got != 0 {
+		t.Fatalf("clearance conflicts with all-red = %d, want 0", got)
+	}
+}
@@ -0,0 +1,156 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDone is returned by Step once the configured number of steps has run.
+var ErrDone = errors.New("simulation finished")
+
+// EngineState is a copy of the engine state between two steps. Step is the
+// number of steps completed so far.
+type EngineState struct {
+	Step       int              `json:"step"`
+	TotalSteps int              `json:"total_steps"`
+	Vehicles   []Vehicle        `json:"vehicles"`
+	Lights     []TrafficLight   `json:"lights"`
+	Lanes      []LaneState      `json:"lanes"`
+	Crosswalks []CrosswalkState `json:"crosswalks,omitempty"`
+}
+
+// Step advances the simulation by one step: spawn, change lanes, move
+// pedestrians and vehicles, then update signals.
+func (e *Engine) Step() error {
+	if e.Done() {
+		return ErrDone
+	}
+	e.advance()
+	return nil
+}
+
+func (e *Engine) advance() {
+	step := e.step
+	e.spawnVehicles(step)
+	e.changeLanes(step)
+	e.movePedestrians(step)
+	e.moveVehicles(step)
+	e.emitStepEnd(step + 1)
+	e.updateLights(step)
+	e.step++
+
+	if e.captureTimeline {
+		e.timeline = append(e.timeline, e.snapshot(step))
+	}
+}
+
+// Done reports whether all configured steps have run.
+func (e *Engine) Done() bool {
+	return e.step >= e.cfg.Steps
+}
+
+// CaptureTimeline records a snapshot after every following step, which
+// Finalize includes in the report.
+func (e *Engine) CaptureTimeline() {
+	if e.timeline == nil {
+		e.timeline = make([]StepSnapshot, 0, e.cfg.Steps-e.step)
+	}
+	e.captureTimeline = true
+}
+
+// CaptureTrips records a trip for every vehicle from the next step on,
+// which Finalize includes in the report.
+func (e *Engine) CaptureTrips() {
+	if e.trips == nil {
+		e.trips = newTripObserver()
+		e.observers = append(e.observers, e.trips)
+	}
+}
+
+// State returns a copy of the current vehicles, signals and lane queues.
+// Changing the copy does not affect the engine.
+func (e *Engine) State() EngineState {
+	state := EngineState{
+		Step:       e.step,
+		TotalSteps: e.cfg.Steps,
+		Vehicles:   append([]Vehicle(nil), e.vehicles...),
+		Lights:     e.lights(),
+		Lanes:      make([]LaneState, 0, len(e.laneOrder)),
+	}
+	for _, id := range e.laneOrder {
+		lane := *e.laneStates[id]
+		lane.MovementCounts = copyCounts(lane.MovementCounts)
+		lane.Profile = nil
+		lane.source, lane.rng = nil, nil
+		state.Lanes = append(state.Lanes, lane)
+	}
+	for _, c := range e.crosswalks {
+		crosswalk := c.copy()
+		crosswalk.Profile = nil
+		state.Crosswalks = append(state.Crosswalks, crosswalk)
+	}
+	return state
+}
+
+// Finalize returns the report for the steps run so far. It can be called
+// before Done to inspect a partial run.
+func (e *Engine) Finalize() Report {
+	report := Report{
+		ConfigName: e.cfg.Name,
+		Seed:       e.cfg.Seed,
+		Generated:  time.Now().UTC(),
+		Metrics:    e.metrics(),
+		Timeline:   e.timeline,
+	}
+	if e.series != nil {
+		report.Series = e.series.series()
+	}
+	if e.trips != nil {
+		report.Trips = e.trips.report(e.vehicles, e.step)
+	}
+	if len(e.timeline) > 0 {
+		cfg := e.cfg
+		report.Config = &cfg
+	}
+	return report
+}
+
+// AddArrivals queues count extra vehicles on a lane. They enter the grid
+// from the next step on, as space at the lane entry allows.
+func (e *Engine) AddArrivals(laneID string, count int) error {
+	lane, ok := e.laneStates[laneID]
+	if !ok {
+		return fmt.Errorf("unknown lane %q", laneID)
+	}
+	if count < 0 {
+		return fmt.Errorf("arrival count must be >= 0")
+	}
+	e.enqueue(lane, count)
+	return nil
+}
+
+// SetPhase forces the signal of an intersection into phase. Controllers
+// continue from the forced phase as if it had just started. Observers see
+// the change as a phase change of the last completed step.
+func (e *Engine) SetPhase(intersection string, phase SignalPhase) error {
+	switch phase {
+	case PhaseVerticalGreen, PhaseVerticalYellow, PhaseHorizontalGreen, PhaseHorizontalYellow, PhaseAllRed, PhasePedestrian:
+	default:
+		return fmt.Errorf("unsupported signal phase %q", phase)
+	}
+	for _, in := range e.intersections {
+		if in.id != intersection {
+			continue
+		}
+		if phase != in.light.Phase {
+			e.changePhase(e.step, in, phase)
+		}
+		in.light.PhaseSteps = 0
+		if aligner, ok := in.controller.(phaseAligner); ok {
+			aligner.align(&in.light)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown intersection %q", intersection)
+}
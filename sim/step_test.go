@@ -0,0 +1,122 @@
+package sim
+
+import (
+	"errors"
+	"testing"
+)
+
+func stepTestConfig() Config {
+	return Config{
+		Name:  "step-test",
+		Steps: 30,
+		Grid: GridConfig{
+			Width:  20,
+			Height: 10,
+		},
+		Signal: SignalConfig{
+			VerticalGreenSteps:   4,
+			HorizontalGreenSteps: 3,
+			YellowSteps:          1,
+		},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Up:    {EntryX: 10, EntryY: 9, StepInterval: 2},
+				Right: {EntryX: 0, EntryY: 5, StepInterval: 3},
+			},
+		},
+	}
+}
+
+func TestStepMatchesRun(t *testing.T) {
+	cfg := stepTestConfig()
+	ran, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	want := ran.Run(true, boolPtr(false))
+
+	stepped, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	stepped.CaptureTimeline()
+	steps := 0
+	for !stepped.Done() {
+		if err := stepped.Step(); err != nil {
+			t.Fatalf("step %d: %v", steps, err)
+		}
+		steps++
+	}
+	if steps != cfg.Steps {
+		t.Fatalf("stepped %d times, want %d", steps, cfg.Steps)
+	}
+	if err := stepped.Step(); !errors.Is(err, ErrDone) {
+		t.Fatalf("step after done = %v, want ErrDone", err)
+	}
+
+	got := stepped.Finalize()
+	if got.Metrics.VehiclesCompleted != want.Metrics.VehiclesCompleted ||
+		got.Metrics.BlockedBySignal != want.Metrics.BlockedBySignal ||
+		got.Metrics.Steps != want.Metrics.Steps {
+		t.Fatalf("stepped metrics %+v differ from run metrics %+v", got.Metrics, want.Metrics)
+	}
+	if len(got.Timeline) != len(want.Timeline) {
+		t.Fatalf("timeline length = %d, want %d", len(got.Timeline), len(want.Timeline))
+	}
+}
+
+func TestStateIsACopy(t *testing.T) {
+	engine, err := NewEngine(stepTestConfig())
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := engine.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	state := engine.State()
+	if state.Step != 5 || state.TotalSteps != 30 {
+		t.Fatalf("state step = %d/%d, want 5/30", state.Step, state.TotalSteps)
+	}
+	if len(state.Vehicles) == 0 || len(state.Lights) != 1 || len(state.Lanes) != 2 {
+		t.Fatalf("state has %d vehicles, %d lights, %d lanes", len(state.Vehicles), len(state.Lights), len(state.Lanes))
+	}
+
+	state.Vehicles[0].X = -1
+	state.Lanes[0].Queued = 99
+	again := engine.State()
+	if again.Vehicles[0].X == -1 || again.Lanes[0].Queued == 99 {
+		t.Fatalf("changing the returned state changed the engine")
+	}
+}
+
+func TestInjectArrivalsAndPhase(t *testing.T) {
+	engine, err := NewEngine(stepTestConfig())
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	if err := engine.AddArrivals("up", 3); err != nil {
+		t.Fatalf("add arrivals: %v", err)
+	}
+	if err := engine.AddArrivals("nowhere", 1); err == nil {
+		t.Fatalf("expected error for unknown lane")
+	}
+	if got := engine.State().Lanes[1].Queued; got != 3 {
+		t.Fatalf("up lane queued = %d, want 3", got)
+	}
+
+	if err := engine.SetPhase("center", PhaseHorizontalGreen); err != nil {
+		t.Fatalf("set phase: %v", err)
+	}
+	if err := engine.SetPhase("elsewhere", PhaseAllRed); err == nil {
+		t.Fatalf("expected error for unknown intersection")
+	}
+	if err := engine.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+	if got := engine.State().Lights[0].Phase; got != PhaseHorizontalGreen {
+		t.Fatalf("phase after forcing = %q, want %q", got, PhaseHorizontalGreen)
+	}
+}
@@ -0,0 +1,202 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Trip is the record of one vehicle. Vehicles still on the grid when the
+// report is made have Completed false and no ExitStep. WaitSteps splits into
+// SignalWaitSteps, TrafficWaitSteps and PedestrianWaitSteps; Stops counts how
+// often the vehicle came to a halt after moving or entering.
+type Trip struct {
+	VehicleID           int       `json:"vehicle_id"`
+	Lane                string    `json:"lane"`
+	Direction           Direction `json:"direction"`
+	Movement            Movement  `json:"movement"`
+	Class               string    `json:"class"`
+	SpawnStep           int       `json:"spawn_step"`
+	ExitStep            int       `json:"exit_step"`
+	Completed           bool      `json:"completed"`
+	TripSteps           int       `json:"trip_steps"`
+	WaitSteps           int       `json:"wait_steps"`
+	MovedSteps          int       `json:"moved_steps"`
+	Stops               int       `json:"stops"`
+	SignalWaitSteps     int       `json:"signal_wait_steps"`
+	TrafficWaitSteps    int       `json:"traffic_wait_steps"`
+	PedestrianWaitSteps int       `json:"pedestrian_wait_steps"`
+}
+
+// tripProgress is what a vehicle's record needs beyond the Vehicle itself.
+type tripProgress struct {
+	Stops       int  `json:"stops"`
+	Signal      int  `json:"signal"`
+	Traffic     int  `json:"traffic"`
+	Pedestrians int  `json:"pedestrians"`
+	Halted      bool `json:"halted"`
+}
+
+// tripObserver keeps one record per vehicle for Report.Trips.
+type tripObserver struct {
+	NopObserver
+	trips    []Trip
+	progress map[int]*tripProgress
+}
+
+func newTripObserver() *tripObserver {
+	return &tripObserver{progress: map[int]*tripProgress{}}
+}
+
+func (t *tripObserver) vehicle(id int) *tripProgress {
+	p, ok := t.progress[id]
+	if !ok {
+		p = &tripProgress{}
+		t.progress[id] = p
+	}
+	return p
+}
+
+func (t *tripObserver) OnSpawn(_ int, v Vehicle) {
+	t.vehicle(v.ID)
+}
+
+func (t *tripObserver) OnMove(_ int, move Move) {
+	t.vehicle(move.Vehicle.ID).Halted = false
+}
+
+func (t *tripObserver) OnBlocked(_ int, v Vehicle, reason BlockReason, _ string) {
+	p := t.vehicle(v.ID)
+	if !p.Halted {
+		p.Stops++
+		p.Halted = true
+	}
+	switch reason {
+	case BlockedBySignal:
+		p.Signal++
+	case BlockedByPedestrians:
+		p.Pedestrians++
+	default:
+		p.Traffic++
+	}
+}
+
+func (t *tripObserver) OnExit(step int, v Vehicle, tripSteps, _ int) {
+	trip := newTrip(v, t.vehicle(v.ID))
+	trip.ExitStep = step
+	trip.Completed = true
+	trip.TripSteps = tripSteps
+	t.trips = append(t.trips, trip)
+	delete(t.progress, v.ID)
+}
+
+func newTrip(v Vehicle, p *tripProgress) Trip {
+	movement := v.Movement
+	if movement == "" {
+		movement = Through
+	}
+	return Trip{
+		VehicleID:           v.ID,
+		Lane:                v.Lane,
+		Direction:           v.Direction,
+		Movement:            movement,
+		Class:               classOf(v),
+		SpawnStep:           v.SpawnStep,
+		WaitSteps:           v.WaitSteps,
+		MovedSteps:          v.MovedSteps,
+		Stops:               p.Stops,
+		SignalWaitSteps:     p.Signal,
+		TrafficWaitSteps:    p.Traffic,
+		PedestrianWaitSteps: p.Pedestrians,
+	}
+}
+
+// report returns the completed trips in exit order followed by the vehicles
+// still on the grid after step steps, by ID.
+func (t *tripObserver) report(vehicles []Vehicle, step int) []Trip {
+	trips := append([]Trip(nil), t.trips...)
+	active := append([]Vehicle(nil), vehicles...)
+	sort.Slice(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+	for _, v := range active {
+		p, ok := t.progress[v.ID]
+		if !ok {
+			p = &tripProgress{}
+		}
+		trip := newTrip(v, p)
+		trip.TripSteps = step - v.SpawnStep + 1
+		trips = append(trips, trip)
+	}
+	return trips
+}
+
+var tripColumns = []string{
+	"vehicle_id", "lane", "direction", "movement", "class", "spawn_step", "exit_step", "completed",
+	"trip_steps", "wait_steps", "moved_steps", "stops", "signal_wait_steps", "traffic_wait_steps",
+	"pedestrian_wait_steps",
+}
+
+// WriteTripsCSV writes trips as CSV with a header row.
+func WriteTripsCSV(w io.Writer, trips []Trip) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(tripColumns); err != nil {
+		return fmt.Errorf("write trips csv: %w", err)
+	}
+	for _, trip := range trips {
+		record := []string{
+			strconv.Itoa(trip.VehicleID), trip.Lane, string(trip.Direction), string(trip.Movement), trip.Class,
+			strconv.Itoa(trip.SpawnStep), strconv.Itoa(trip.ExitStep), strconv.FormatBool(trip.Completed),
+			strconv.Itoa(trip.TripSteps), strconv.Itoa(trip.WaitSteps), strconv.Itoa(trip.MovedSteps),
+			strconv.Itoa(trip.Stops), strconv.Itoa(trip.SignalWaitSteps), strconv.Itoa(trip.TrafficWaitSteps),
+			strconv.Itoa(trip.PedestrianWaitSteps),
+		}
+		if err := out.Write(record); err != nil {
+			return fmt.Errorf("write trips csv: %w", err)
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return fmt.Errorf("write trips csv: %w", err)
+	}
+	return nil
+}
+
+// WriteTripsJSONL writes one JSON object per trip and line.
+func WriteTripsJSONL(w io.Writer, trips []Trip) error {
+	enc := json.NewEncoder(w)
+	for _, trip := range trips {
+		if err := enc.Encode(trip); err != nil {
+			return fmt.Errorf("write trips jsonl: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteTrips writes trips to path as CSV when it ends in .csv and as JSONL
+// otherwise.
+func WriteTrips(path string, trips []Trip) error {
+	if path == "" {
+		return fmt.Errorf("trip log path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create trip log directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create trip log: %w", err)
+	}
+	write := WriteTripsJSONL
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		write = WriteTripsCSV
+	}
+	if err := write(f, trips); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,96 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTripsAddUpToMetrics(t *testing.T) {
+	cfg, err := LoadConfig("../configs/turning.json")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	engine.CaptureTrips()
+	report := engine.Run(false, boolPtr(false))
+	m := report.Metrics
+
+	if len(report.Trips) != m.VehiclesSpawned {
+		t.Fatalf("got %d trips, want one per spawned vehicle (%d)", len(report.Trips), m.VehiclesSpawned)
+	}
+	completed, wait, trip, signal := 0, 0, 0, 0
+	for _, tr := range report.Trips {
+		if tr.SignalWaitSteps+tr.TrafficWaitSteps+tr.PedestrianWaitSteps != tr.WaitSteps {
+			t.Fatalf("trip %d splits %d wait steps into %d+%d+%d", tr.VehicleID, tr.WaitSteps, tr.SignalWaitSteps, tr.TrafficWaitSteps, tr.PedestrianWaitSteps)
+		}
+		if tr.Stops > tr.WaitSteps || (tr.WaitSteps > 0 && tr.Stops == 0) {
+			t.Fatalf("trip %d has %d stops for %d wait steps", tr.VehicleID, tr.Stops, tr.WaitSteps)
+		}
+		signal += tr.SignalWaitSteps
+		if !tr.Completed {
+			continue
+		}
+		completed++
+		wait += tr.WaitSteps
+		trip += tr.TripSteps
+		if tr.ExitStep-tr.SpawnStep+1 != tr.TripSteps {
+			t.Fatalf("trip %d: spawn %d, exit %d, %d steps", tr.VehicleID, tr.SpawnStep, tr.ExitStep, tr.TripSteps)
+		}
+	}
+	if completed != m.VehiclesCompleted {
+		t.Fatalf("completed trips = %d, want %d", completed, m.VehiclesCompleted)
+	}
+	if got := float64(wait) / float64(completed); got != m.AverageWaitPerTrip {
+		t.Fatalf("mean trip wait = %.3f, want %.3f", got, m.AverageWaitPerTrip)
+	}
+	if got := float64(trip) / float64(completed); got != m.AverageTripDuration {
+		t.Fatalf("mean trip duration = %.3f, want %.3f", got, m.AverageTripDuration)
+	}
+	if signal != m.BlockedBySignal {
+		t.Fatalf("signal wait steps = %d, want %d", signal, m.BlockedBySignal)
+	}
+}
+
+func TestWriteTrips(t *testing.T) {
+	trips := []Trip{
+		{VehicleID: 1, Lane: "up", Direction: Up, Movement: Through, Class: "car", SpawnStep: 1, ExitStep: 9, Completed: true, TripSteps: 9, WaitSteps: 2, MovedSteps: 7, Stops: 1, SignalWaitSteps: 2},
+		{VehicleID: 2, Lane: "right", Direction: Right, Movement: TurnLeft, Class: "truck", SpawnStep: 5, TripSteps: 4, WaitSteps: 1, MovedSteps: 3, Stops: 1, TrafficWaitSteps: 1},
+	}
+
+	var b bytes.Buffer
+	if err := WriteTripsCSV(&b, trips); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	rows, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "vehicle_id" || len(rows[0]) != len(rows[1]) {
+		t.Fatalf("unexpected csv rows: %v", rows)
+	}
+	if got := strings.Join(rows[2], ","); got != "2,right,right,left,truck,5,0,false,4,1,3,1,0,1,0" {
+		t.Fatalf("csv row = %q", got)
+	}
+
+	b.Reset()
+	if err := WriteTripsJSONL(&b, trips); err != nil {
+		t.Fatalf("write jsonl: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d jsonl lines, want 2", len(lines))
+	}
+	var decoded Trip
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+		t.Fatalf("parse jsonl line: %v", err)
+	}
+	if decoded != trips[0] {
+		t.Fatalf("decoded %+v, want %+v", decoded, trips[0])
+	}
+}
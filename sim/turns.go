@@ -0,0 +1,99 @@
+package sim
+
+func opposite(d Direction) Direction {
+	switch d {
+	case Up:
+		return Down
+	case Down:
+		return Up
+	case Left:
+		return Right
+	case Right:
+		return Left
+	}
+	return d
+}
+
+// turnHeading returns the heading after performing a movement. The grid's y
+// axis grows downwards, so a right turn from Up heads Right.
+func turnHeading(d Direction, m Movement) Direction {
+	switch m {
+	case TurnRight:
+		switch d {
+		case Up:
+			return Right
+		case Right:
+			return Down
+		case Down:
+			return Left
+		case Left:
+			return Up
+		}
+	case TurnLeft:
+		switch d {
+		case Up:
+			return Left
+		case Left:
+			return Down
+		case Down:
+			return Right
+		case Right:
+			return Up
+		}
+	}
+	return d
+}
+
+// movementsConflict reports whether two movements through the same
+// intersection cross paths. Approaches are given by the heading the vehicles
+// entered with. Opposing left turns pass each other, as do opposing through
+// and right movements; a left turn crosses opposing through and right traffic.
+// Movements from crossing approaches always conflict.
+func movementsConflict(a Direction, am Movement, b Direction, bm Movement) bool {
+	if a == b {
+		return false
+	}
+	if a == opposite(b) {
+		return (am == TurnLeft) != (bm == TurnLeft)
+	}
+	return true
+}
+
+// nextMovement assigns the movement of the next vehicle leaving a lane. It
+// picks the movement furthest behind its configured share, which follows the
+// ratios exactly over time without needing a random source.
+func (lane *LaneState) nextMovement() Movement {
+	ratios := map[Movement]float64{
+		Through:   lane.Turns.Through,
+		TurnRight: lane.Turns.Right,
+		TurnLeft:  lane.Turns.Left,
+	}
+	// Summed in a fixed order so rounding, and with it tie-breaking, is the
+	// same on every run.
+	total := lane.Turns.Through + lane.Turns.Right + lane.Turns.Left
+	if total <= 0 {
+		return Through
+	}
+	if lane.MovementCounts == nil {
+		lane.MovementCounts = map[Movement]int{}
+	}
+
+	assigned := 0
+	for _, count := range lane.MovementCounts {
+		assigned += count
+	}
+	best := Movement("")
+	bestDeficit := 0.0
+	for _, m := range []Movement{Through, TurnRight, TurnLeft} {
+		if ratios[m] <= 0 {
+			continue
+		}
+		deficit := ratios[m]/total*float64(assigned+1) - float64(lane.MovementCounts[m])
+		if best == "" || deficit > bestDeficit {
+			best = m
+			bestDeficit = deficit
+		}
+	}
+	lane.MovementCounts[best]++
+	return best
+}
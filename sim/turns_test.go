@@ -0,0 +1,143 @@
+package sim
+
+import "testing"
+
+func turnTestEngine(t *testing.T, phase SignalPhase) *Engine {
+	t.Helper()
+	cfg := Config{
+		Name:   "turn-test",
+		Steps:  1,
+		Grid:   GridConfig{Width: 20, Height: 10},
+		Signal: SignalConfig{VerticalGreenSteps: 5, HorizontalGreenSteps: 5},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Up: {EntryX: 10, EntryY: 9},
+			},
+		},
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	engine.intersections[0].light.setPhase(phase)
+	return engine
+}
+
+func TestNextMovementFollowsTurnRatios(t *testing.T) {
+	lane := &LaneState{Turns: TurnRatio{Through: 0.6, Right: 0.25, Left: 0.15}}
+	counts := map[Movement]int{}
+	for i := 0; i < 20; i++ {
+		counts[lane.nextMovement()]++
+	}
+	if counts[Through] != 12 || counts[TurnRight] != 5 || counts[TurnLeft] != 3 {
+		t.Fatalf("movement counts = %v, want through=12 right=5 left=3", counts)
+	}
+
+	straight := &LaneState{}
+	if got := straight.nextMovement(); got != Through {
+		t.Fatalf("lane without ratios assigned %q, want through", got)
+	}
+}
+
+func TestVehicleTurnsInsideIntersection(t *testing.T) {
+	engine := turnTestEngine(t, PhaseVerticalGreen)
+	engine.vehicles = []Vehicle{
+		{ID: 1, X: 10, Y: 5, Direction: Up, Heading: Up, Movement: TurnRight, SpawnStep: 1},
+	}
+
+	engine.moveVehicles(0)
+
+	v := engine.vehicles[0]
+	if v.X != 11 || v.Y != 5 || v.CurrentHeading() != Right {
+		t.Fatalf("vehicle at (%d,%d) heading %s, want (11,5) heading right", v.X, v.Y, v.CurrentHeading())
+	}
+	if v.pendingMovement() != Through {
+		t.Fatalf("turned vehicle still has pending movement %q", v.pendingMovement())
+	}
+}
+
+func TestLeftTurnYieldsToOpposingThrough(t *testing.T) {
+	engine := turnTestEngine(t, PhaseVerticalGreen)
+	engine.vehicles = []Vehicle{
+		{ID: 1, X: 10, Y: 6, Direction: Up, Heading: Up, Movement: TurnLeft, SpawnStep: 1},
+		{ID: 2, X: 10, Y: 4, Direction: Down, Heading: Down, Movement: Through, SpawnStep: 1},
+	}
+
+	engine.moveVehicles(0)
+
+	if engine.vehicles[0].Y != 6 {
+		t.Fatalf("left turner entered intersection ahead of opposing through vehicle")
+	}
+	if engine.vehicles[1].Y != 5 {
+		t.Fatalf("opposing through vehicle did not enter intersection")
+	}
+	if engine.stats.potentialCrash != 0 {
+		t.Fatalf("yielding should not count as a potential collision")
+	}
+}
+
+func TestOpposingLeftTurnsShareIntersection(t *testing.T) {
+	engine := turnTestEngine(t, PhaseVerticalGreen)
+	engine.vehicles = []Vehicle{
+		{ID: 1, X: 10, Y: 6, Direction: Up, Heading: Up, Movement: TurnLeft, SpawnStep: 1},
+		{ID: 2, X: 10, Y: 4, Direction: Down, Heading: Down, Movement: TurnLeft, SpawnStep: 1},
+	}
+
+	engine.moveVehicles(0)
+	engine.moveVehicles(1)
+
+	first, second := engine.vehicles[0], engine.vehicles[1]
+	if first.X != 9 || first.CurrentHeading() != Left {
+		t.Fatalf("northbound left turn ended at (%d,%d) heading %s", first.X, first.Y, first.CurrentHeading())
+	}
+	if second.X != 11 || second.CurrentHeading() != Right {
+		t.Fatalf("southbound left turn ended at (%d,%d) heading %s", second.X, second.Y, second.CurrentHeading())
+	}
+	if engine.stats.blockedTraffic != 0 || engine.stats.potentialCrash != 0 {
+		t.Fatalf("opposing left turns blocked=%d conflicts=%d, want none", engine.stats.blockedTraffic, engine.stats.potentialCrash)
+	}
+}
+
+func TestCrossingVehicleWaitsForOccupiedIntersection(t *testing.T) {
+	cfg := Config{
+		Name:  "box-test",
+		Steps: 1,
+		Grid:  GridConfig{Width: 20, Height: 10},
+		Network: NetworkConfig{
+			Roads: []RoadConfig{
+				{Axis: Vertical, At: 10},
+				{Axis: Horizontal, At: 3},
+				{Axis: Horizontal, At: 5},
+			},
+		},
+		Signal: SignalConfig{VerticalGreenSteps: 5, HorizontalGreenSteps: 5},
+		Spawn: SpawnConfig{
+			Lanes: map[Direction]LaneSpawnConfig{
+				Right: {EntryX: 0, EntryY: 5},
+			},
+		},
+	}
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	for _, in := range engine.intersections {
+		in.light.setPhase(PhaseHorizontalGreen)
+	}
+	// The northbound vehicle ahead is held by the red signal at y=3, which
+	// leaves the second one stuck inside the box at y=5.
+	engine.vehicles = []Vehicle{
+		{ID: 1, X: 10, Y: 4, Direction: Up, Heading: Up, SpawnStep: 1},
+		{ID: 2, X: 10, Y: 5, Direction: Up, Heading: Up, SpawnStep: 1},
+		{ID: 3, X: 9, Y: 5, Direction: Right, Heading: Right, SpawnStep: 1},
+	}
+
+	engine.moveVehicles(0)
+
+	if engine.vehicles[2].X != 9 {
+		t.Fatalf("crossing vehicle entered an intersection held by a stopped vehicle")
+	}
+	if engine.stats.blockedSignal != 1 || engine.stats.blockedTraffic != 2 {
+		t.Fatalf("blocked signal=%d traffic=%d, want 1 and 2", engine.stats.blockedSignal, engine.stats.blockedTraffic)
+	}
+}